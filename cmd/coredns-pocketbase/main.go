@@ -0,0 +1,34 @@
+// Command coredns-pocketbase runs the DNS management commands of the pocketbase plugin, i.e. import-zone,
+// export-zone and import-legacy, against the data directory of a CoreDNS server running the plugin:
+//
+//	coredns-pocketbase --data-dir /var/lib/coredns/pb_data import-zone --zone example.com. example.com.zone
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+
+	"github.com/tinkernels/coredns-pocketbase/handler/pocketbase"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		os.Exit(1)
+	}
+}
+
+// run runs the command of the arguments against the data directory given with --data-dir, relative data directories
+// are relative to the working directory.
+func run(args []string) error {
+	flags := flag.NewFlagSet("coredns-pocketbase", flag.ContinueOnError)
+	dataDir := flags.String("data-dir", "pb_data", "data directory of the plugin, the data_dir of the Corefile")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	dir, err := filepath.Abs(*dataDir)
+	if err != nil {
+		return err
+	}
+	return pocketbase.NewWithDataDir(dir).RunCommand(flags.Args()...)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	dataDir := filepath.Join(dir, "pb_data")
+	zoneFile := filepath.Join(dir, "example.com.zone")
+	require.NoError(t, os.WriteFile(zoneFile, []byte(`$ORIGIN example.com.
+@   3600 IN SOA ns1 hostmaster 1 7200 3600 1209600 300
+@   3600 IN NS  ns1
+ns1 3600 IN A   192.0.2.53
+www  300 IN A   192.0.2.80
+`), 0o644))
+
+	require.NoError(t, run([]string{"--data-dir", dataDir, "import-zone", "--zone", "example.com.", zoneFile}))
+
+	exported := filepath.Join(dir, "export.zone")
+	require.NoError(t, run([]string{"--data-dir", dataDir, "export-zone", "--zone", "example.com.", "--output", exported}))
+	data, err := os.ReadFile(exported)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "www.example.com.\t300\tIN\tA\t192.0.2.80")
+
+	assert.Error(t, run([]string{"--data-dir", dataDir, "export-zone", "--zone", "example.org."}))
+	assert.Error(t, run([]string{"--data-dir"}))
+}
@@ -6,6 +6,11 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
 )
 
 // Default configuration values
@@ -22,8 +27,32 @@ const (
 	defaultCacheCapacity = 0
 	// DefaultDefaultTtl is the default TTL (Time To Live) in seconds for DNS records
 	defaultDefaultTtl = 30
+	// defaultDisabledZones is the default way queries of disabled zones are answered
+	defaultDisabledZones = DisabledZonesRefuse
+	// defaultGitopsInterval is the default interval the GitOps directory is reconciled at
+	defaultGitopsInterval = 30 * time.Second
+	// defaultExportDelay is the default delay the changes of a zone are exported to its zone file after
+	defaultExportDelay = time.Second
+)
+
+// Ways queries of disabled zones are answered
+const (
+	// DisabledZonesRefuse answers queries of disabled zones with REFUSED
+	DisabledZonesRefuse = "refuse"
+	// DisabledZonesFallthrough passes queries of disabled zones to the next plugin
+	DisabledZonesFallthrough = "fallthrough"
 )
 
+// Seed is the records of a zone, or the seed file of a zone, inserted at startup
+type Seed struct {
+	// Zone is the zone of the records, created if it does not exist
+	Zone string
+	// Records are records in master file format, relative names are within the zone
+	Records []string
+	// File is a zone file, or an octoDNS YAML file with the .yaml or .yml extension
+	File string
+}
+
 // Config represents the configuration for the CoreDNS PocketBase integration.
 // It contains settings for the service's network interface, data storage,
 // authentication, and caching behavior.
@@ -40,17 +69,50 @@ type Config struct {
 	CacheCapacity int
 	// DefaultTtl is the default TTL (Time To Live) in seconds for DNS records
 	DefaultTtl int
+	// MinTtl is the minimum TTL in seconds of served records (0 means no minimum)
+	MinTtl int
+	// MaxTtl is the maximum TTL in seconds of served records (0 means no maximum)
+	MaxTtl int
+	// DisabledZones is the way queries of disabled zones are answered, DisabledZonesRefuse or DisabledZonesFallthrough
+	DisabledZones string
+	// AcmeDnsZone is the zone acme-dns accounts are created in (empty disables the acme-dns API)
+	AcmeDnsZone string
+	// ExternalDns enables the external-dns webhook provider API
+	ExternalDns bool
+	// ExternalDnsAllowFrom restricts the external-dns webhook provider API to these CIDRs (empty allows loopback only)
+	ExternalDnsAllowFrom []string
+	// IntegrityOverride disables the zone integrity checks, e.g. while migrating inconsistent data
+	IntegrityOverride bool
+	// ClaimResolver is the DNS server zone claims are verified with (empty uses the system resolver)
+	ClaimResolver string
+	// GitopsDir is the directory of zone definitions reconciled into the database (empty disables GitOps)
+	GitopsDir string
+	// GitopsInterval is the interval the GitOps directory is reconciled at
+	GitopsInterval time.Duration
+	// GitopsPrune deletes the records of the managed zones which are not in their zone definitions
+	GitopsPrune bool
+	// ExportDir is the directory the zone files of the zones are continuously exported to (empty disables the exports)
+	ExportDir string
+	// ExportDelay is the delay the changes of a zone are exported to its zone file after, debouncing bursts of changes
+	ExportDelay time.Duration
+	// Seeds are the records of zones inserted at startup
+	Seeds []Seed
+	// SeedOverwrite replaces the existing RRsets differing from the seeds (seeded RRsets are only inserted if absent otherwise)
+	SeedOverwrite bool
 }
 
 // NewConfig creates a new Config instance with default values
 func NewConfig() *Config {
 	return &Config{
-		Listen:        defaultListen,
-		DataDir:       defaultDataDir,
-		SuEmail:       defaultSuEmail,
-		SuPassword:    defaultSuPassword,
-		CacheCapacity: defaultCacheCapacity,
-		DefaultTtl:    defaultDefaultTtl,
+		Listen:         defaultListen,
+		DataDir:        defaultDataDir,
+		SuEmail:        defaultSuEmail,
+		SuPassword:     defaultSuPassword,
+		CacheCapacity:  defaultCacheCapacity,
+		DefaultTtl:     defaultDefaultTtl,
+		DisabledZones:  defaultDisabledZones,
+		GitopsInterval: defaultGitopsInterval,
+		ExportDelay:    defaultExportDelay,
 	}
 }
 
@@ -94,6 +156,104 @@ func (c *Config) WithDefaultTtl(defaultTtl int) *Config {
 	return c
 }
 
+// WithMinTtl sets the minimum TTL and returns the modified Config
+func (c *Config) WithMinTtl(minTtl int) *Config {
+	c.MinTtl = minTtl
+	return c
+}
+
+// WithMaxTtl sets the maximum TTL and returns the modified Config
+func (c *Config) WithMaxTtl(maxTtl int) *Config {
+	c.MaxTtl = maxTtl
+	return c
+}
+
+// WithDisabledZones sets the way queries of disabled zones are answered and returns the modified Config
+func (c *Config) WithDisabledZones(disabledZones string) *Config {
+	c.DisabledZones = disabledZones
+	return c
+}
+
+// WithAcmeDnsZone sets the acme-dns zone and returns the modified Config
+func (c *Config) WithAcmeDnsZone(acmeDnsZone string) *Config {
+	c.AcmeDnsZone = acmeDnsZone
+	return c
+}
+
+// WithExternalDns enables the external-dns webhook provider restricted to the given CIDRs, or to loopback clients
+// and returns the modified Config
+func (c *Config) WithExternalDns(allowFrom ...string) *Config {
+	c.ExternalDns = true
+	c.ExternalDnsAllowFrom = allowFrom
+	return c
+}
+
+// WithIntegrityOverride sets whether the zone integrity checks are disabled and returns the modified Config
+func (c *Config) WithIntegrityOverride(integrityOverride bool) *Config {
+	c.IntegrityOverride = integrityOverride
+	return c
+}
+
+// WithClaimResolver sets the DNS server zone claims are verified with and returns the modified Config
+func (c *Config) WithClaimResolver(claimResolver string) *Config {
+	c.ClaimResolver = claimResolver
+	return c
+}
+
+// WithGitopsDir sets the GitOps directory and returns the modified Config
+func (c *Config) WithGitopsDir(gitopsDir string) *Config {
+	c.GitopsDir = gitopsDir
+	return c
+}
+
+// WithGitopsInterval sets the polling interval of the GitOps directory and returns the modified Config
+func (c *Config) WithGitopsInterval(gitopsInterval time.Duration) *Config {
+	c.GitopsInterval = gitopsInterval
+	return c
+}
+
+// WithGitopsPrune sets whether records missing from the GitOps directory are deleted and returns the modified Config
+func (c *Config) WithGitopsPrune(gitopsPrune bool) *Config {
+	c.GitopsPrune = gitopsPrune
+	return c
+}
+
+// WithExportDir sets the zone file export directory and returns the modified Config
+func (c *Config) WithExportDir(exportDir string) *Config {
+	c.ExportDir = exportDir
+	return c
+}
+
+// WithExportDelay sets the delay of the zone file exports and returns the modified Config
+func (c *Config) WithExportDelay(exportDelay time.Duration) *Config {
+	c.ExportDelay = exportDelay
+	return c
+}
+
+// WithRecord adds a record of a zone to seed and returns the modified Config
+func (c *Config) WithRecord(zone string, record string) *Config {
+	for i := range c.Seeds {
+		if c.Seeds[i].Zone == zone && c.Seeds[i].File == "" {
+			c.Seeds[i].Records = append(c.Seeds[i].Records, record)
+			return c
+		}
+	}
+	c.Seeds = append(c.Seeds, Seed{Zone: zone, Records: []string{record}})
+	return c
+}
+
+// WithSeedFile adds the seed file of a zone and returns the modified Config
+func (c *Config) WithSeedFile(zone string, file string) *Config {
+	c.Seeds = append(c.Seeds, Seed{Zone: zone, File: file})
+	return c
+}
+
+// WithSeedOverwrite sets whether the seeds replace differing existing records and returns the modified Config
+func (c *Config) WithSeedOverwrite(seedOverwrite bool) *Config {
+	c.SeedOverwrite = seedOverwrite
+	return c
+}
+
 func (c *Config) MixWithEnv() *Config {
 	if suUserName := os.Getenv("COREDNS_PB_SUPERUSER_EMAIL"); suUserName != "" {
 		c.SuEmail = suUserName
@@ -101,6 +261,9 @@ func (c *Config) MixWithEnv() *Config {
 	if suPassword := os.Getenv("COREDNS_PB_SUPERUSER_PWD"); suPassword != "" {
 		c.SuPassword = suPassword
 	}
+	if integrityOverride, err := strconv.ParseBool(os.Getenv("COREDNS_PB_INTEGRITY_OVERRIDE")); err == nil {
+		c.IntegrityOverride = integrityOverride
+	}
 	return c
 }
 
@@ -128,5 +291,68 @@ func (c *Config) Validate() error {
 	if c.DefaultTtl < 0 {
 		return fmt.Errorf("default_ttl must be greater than or equal to 0")
 	}
+	if c.MinTtl < 0 {
+		return fmt.Errorf("min_ttl must be greater than or equal to 0")
+	}
+	if c.MaxTtl < 0 {
+		return fmt.Errorf("max_ttl must be greater than or equal to 0")
+	}
+	if c.MaxTtl > 0 && c.MinTtl > c.MaxTtl {
+		return fmt.Errorf("min_ttl must be less than or equal to max_ttl")
+	}
+	if c.DisabledZones != DisabledZonesRefuse && c.DisabledZones != DisabledZonesFallthrough {
+		return fmt.Errorf("disabled_zones must be %s or %s", DisabledZonesRefuse, DisabledZonesFallthrough)
+	}
+	if c.AcmeDnsZone != "" {
+		if _, ok := dns.IsDomainName(c.AcmeDnsZone); !ok {
+			return fmt.Errorf("invalid acme_dns_zone: %s", c.AcmeDnsZone)
+		}
+	}
+	for _, cidr := range c.ExternalDnsAllowFrom {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid external_dns CIDR %s: %v", cidr, err)
+		}
+	}
+	if c.ClaimResolver != "" {
+		host := c.ClaimResolver
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if _, ok := dns.IsDomainName(host); net.ParseIP(host) == nil && (!ok || strings.Contains(host, ":")) {
+			return fmt.Errorf("invalid claim_resolver: %s", c.ClaimResolver)
+		}
+	}
+	if c.GitopsDir != "" {
+		if info, err := os.Stat(c.GitopsDir); err != nil || !info.IsDir() {
+			return fmt.Errorf("gitops_dir is not a directory: %s", c.GitopsDir)
+		}
+		if c.GitopsInterval <= 0 {
+			return fmt.Errorf("gitops_interval must be greater than 0")
+		}
+	}
+	if c.ExportDir != "" {
+		if info, err := os.Stat(c.ExportDir); err != nil || !info.IsDir() {
+			return fmt.Errorf("export_dir is not a directory: %s", c.ExportDir)
+		}
+	}
+	if c.ExportDelay < 0 {
+		return fmt.Errorf("export_delay must be greater than or equal to 0")
+	}
+	for _, seed := range c.Seeds {
+		if _, ok := dns.IsDomainName(seed.Zone); !ok || seed.Zone == "" {
+			return fmt.Errorf("invalid seed zone: %s", seed.Zone)
+		}
+		if seed.File != "" {
+			if info, err := os.Stat(seed.File); err != nil || info.IsDir() {
+				return fmt.Errorf("seed_file is not a file: %s", seed.File)
+			}
+		}
+		for _, record := range seed.Records {
+			zp := dns.NewZoneParser(strings.NewReader(record), dns.Fqdn(seed.Zone), "")
+			if _, ok := zp.Next(); !ok || zp.Err() != nil {
+				return fmt.Errorf("invalid record of zone %s: %s: %v", seed.Zone, record, zp.Err())
+			}
+		}
+	}
 	return nil
 }
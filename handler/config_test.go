@@ -1,7 +1,9 @@
 package handler
 
 import (
+	"os"
 	"testing"
+	"time"
 )
 
 func TestNewConfig(t *testing.T) {
@@ -25,6 +27,9 @@ func TestNewConfig(t *testing.T) {
 	if config.DefaultTtl != defaultDefaultTtl {
 		t.Errorf("expected DefaultTtl to be %d, got %d", defaultDefaultTtl, config.DefaultTtl)
 	}
+	if config.DisabledZones != defaultDisabledZones {
+		t.Errorf("expected DisabledZones to be %s, got %s", defaultDisabledZones, config.DisabledZones)
+	}
 }
 
 func TestConfigValidation(t *testing.T) {
@@ -78,6 +83,111 @@ func TestConfigValidation(t *testing.T) {
 			config:  NewConfig().WithDefaultTtl(-1),
 			wantErr: true,
 		},
+		{
+			name:    "valid acme-dns zone",
+			config:  NewConfig().WithAcmeDnsZone("acme.example.com."),
+			wantErr: false,
+		},
+		{
+			name:    "valid external-dns CIDRs",
+			config:  NewConfig().WithExternalDns("10.0.0.0/8", "fd00::/8"),
+			wantErr: false,
+		},
+		{
+			name:    "invalid external-dns CIDR",
+			config:  NewConfig().WithExternalDns("10.0.0.0"),
+			wantErr: true,
+		},
+		{
+			name:    "valid ttl limits",
+			config:  NewConfig().WithMinTtl(60).WithMaxTtl(86400),
+			wantErr: false,
+		},
+		{
+			name:    "negative min ttl",
+			config:  NewConfig().WithMinTtl(-1),
+			wantErr: true,
+		},
+		{
+			name:    "min ttl above max ttl",
+			config:  NewConfig().WithMinTtl(3600).WithMaxTtl(60),
+			wantErr: true,
+		},
+		{
+			name:    "fallthrough disabled zones",
+			config:  NewConfig().WithDisabledZones(DisabledZonesFallthrough),
+			wantErr: false,
+		},
+		{
+			name:    "invalid disabled zones",
+			config:  NewConfig().WithDisabledZones("drop"),
+			wantErr: true,
+		},
+		{
+			name:    "valid claim resolvers",
+			config:  NewConfig().WithClaimResolver("[2001:db8::53]:53"),
+			wantErr: false,
+		},
+		{
+			name:    "claim resolver without port",
+			config:  NewConfig().WithClaimResolver("192.0.2.53"),
+			wantErr: false,
+		},
+		{
+			name:    "invalid claim resolver",
+			config:  NewConfig().WithClaimResolver("resolver:53:53"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid acme-dns zone",
+			config:  NewConfig().WithAcmeDnsZone("acme..example.com"),
+			wantErr: true,
+		},
+		{
+			name:    "valid gitops dir",
+			config:  NewConfig().WithGitopsDir(os.TempDir()).WithGitopsPrune(true),
+			wantErr: false,
+		},
+		{
+			name:    "missing gitops dir",
+			config:  NewConfig().WithGitopsDir("/nonexistent/zones"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid gitops interval",
+			config:  NewConfig().WithGitopsDir(os.TempDir()).WithGitopsInterval(0),
+			wantErr: true,
+		},
+		{
+			name:    "valid export dir",
+			config:  NewConfig().WithExportDir(os.TempDir()).WithExportDelay(0),
+			wantErr: false,
+		},
+		{
+			name:    "missing export dir",
+			config:  NewConfig().WithExportDir("/nonexistent/exports"),
+			wantErr: true,
+		},
+		{
+			name:    "valid seed records",
+			config:  NewConfig().WithRecord("example.com.", "www 300 IN A 192.0.2.10").WithRecord("example.com.", "@ IN NS ns1"),
+			wantErr: false,
+		},
+		{
+			name:    "invalid seed record",
+			config:  NewConfig().WithRecord("example.com.", "www IN A not-an-ip"),
+			wantErr: true,
+		},
+		{
+			name:    "missing seed file",
+			config:  NewConfig().WithSeedFile("example.com.", "/nonexistent/example.com.zone"),
+			wantErr: true,
+		},
+		{
+			name:    "negative export delay",
+			config:  NewConfig().WithExportDelay(-time.Second),
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -174,3 +284,19 @@ func TestConfigMixWithEnv(t *testing.T) {
 		t.Errorf("expected SuPassword to be %s (default) when env var empty, got %s", defaultSuPassword, config.SuPassword)
 	}
 }
+
+func TestConfigMixWithEnvIntegrityOverride(t *testing.T) {
+	if NewConfig().MixWithEnv().IntegrityOverride {
+		t.Errorf("expected IntegrityOverride to be false when no env var set")
+	}
+
+	t.Setenv("COREDNS_PB_INTEGRITY_OVERRIDE", "true")
+	if !NewConfig().MixWithEnv().IntegrityOverride {
+		t.Errorf("expected IntegrityOverride to be true from env var")
+	}
+
+	t.Setenv("COREDNS_PB_INTEGRITY_OVERRIDE", "invalid")
+	if !NewConfig().WithIntegrityOverride(true).MixWithEnv().IntegrityOverride {
+		t.Errorf("expected IntegrityOverride to be kept when env var is invalid")
+	}
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"errors"
 	"fmt"
+	"net"
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/pkg/log"
@@ -15,6 +16,8 @@ import (
 
 const (
 	pluginName = "pocketbase"
+	// transferBatchSize is the number of records of a zone transfer sent in one message
+	transferBatchSize = 100
 )
 
 type ErrUnsupportedRecordType struct {
@@ -28,7 +31,8 @@ func (e *ErrUnsupportedRecordType) Error() string {
 type PocketBaseHandler struct {
 	Next plugin.Handler
 	// internal
-	pbInst *pb.Instance
+	pbInst        *pb.Instance
+	disabledZones string
 }
 
 func (handler *PocketBaseHandler) WarmUp() {
@@ -45,7 +49,8 @@ func (handler *PocketBaseHandler) WarmUp() {
 // It processes DNS queries by:
 // 1. Fetching available zones
 // 2. Matching the query against available zones
-// 3. Fetching and composing appropriate DNS records
+// 3. Refusing or passing on queries of disabled zones
+// 4. Fetching and composing appropriate DNS records
 // Returns DNS response code and any error encountered
 func (handler *PocketBaseHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
 	state := request.Request{W: w, Req: r}
@@ -68,6 +73,22 @@ func (handler *PocketBaseHandler) processQuery(ctx context.Context, state reques
 		return plugin.NextOrFailure(handler.Name(), handler.Next, ctx, state.W, state.Req)
 	}
 
+	zone, err := handler.pbInst.FetchZone(qZone)
+	if err != nil {
+		return handler.errorResponse(state, dns.RcodeServerFailure, err)
+	}
+	if zone != nil && zone.IsDisabled() {
+		log.Debugf("Zone is disabled, zone: %s, answering with: %s", qZone, handler.disabledZones)
+		if handler.disabledZones == DisabledZonesFallthrough {
+			return plugin.NextOrFailure(handler.Name(), handler.Next, ctx, state.W, state.Req)
+		}
+		return handler.errorResponse(state, dns.RcodeRefused, nil)
+	}
+
+	if qType == "AXFR" {
+		return handler.transferZone(state, qZone, zone)
+	}
+
 	records, err := handler.pbInst.FetchRecords(qZone, qName, qType)
 	if err != nil {
 		return handler.errorResponse(state, dns.RcodeServerFailure, err)
@@ -92,11 +113,9 @@ func (handler *PocketBaseHandler) processQuery(ctx context.Context, state reques
 		records = append(records, recsNs...)
 	}
 
-	if qType == "AXFR" {
-		return handler.errorResponse(state, dns.RcodeNotImplemented, nil)
-	}
-
-	answers, extras, err := handler.composeResponseMsgs(records)
+	// the settings of the queried zone are fetched once for all the composed records
+	composer := pb.NewQueryComposer(handler.pbInst, qZone, zone)
+	answers, extras, err := handler.composeResponseMsgs(composer, records)
 	// handle error type
 	if err != nil {
 		var errUnsupportedRecordType *ErrUnsupportedRecordType
@@ -124,7 +143,37 @@ func (handler *PocketBaseHandler) processQuery(ctx context.Context, state reques
 	return dns.RcodeSuccess, state.W.WriteMsg(rMsg)
 }
 
-func (handler *PocketBaseHandler) composeResponseMsgs(records []*model.Record) (answers []dns.RR, extras []dns.RR, err error) {
+// transferZone answers a zone transfer (AXFR) of the zone with all its served records, SOA first and last,
+// in as many messages as needed. Only the apex of the zone can be transferred, over TCP,
+// by the addresses allowed by the transfer ACL of the zone.
+func (handler *PocketBaseHandler) transferZone(state request.Request, qZone string, zone *model.Zone) (int, error) {
+	if state.Name() != qZone {
+		return handler.errorResponse(state, dns.RcodeNotAuth, nil)
+	}
+	if state.Proto() != "tcp" || zone == nil || !zone.TransferAllowed(net.ParseIP(state.IP())) {
+		log.Debugf("Zone transfer refused, zone: %s, client: %s, proto: %s", qZone, state.IP(), state.Proto())
+		return handler.errorResponse(state, dns.RcodeRefused, nil)
+	}
+
+	rrs, err := handler.pbInst.TransferZone(qZone)
+	if err != nil {
+		return handler.errorResponse(state, dns.RcodeServerFailure, err)
+	}
+	for start := 0; start < len(rrs); start += transferBatchSize {
+		rMsg := new(dns.Msg)
+		rMsg.SetReply(state.Req)
+		rMsg.Authoritative = true
+		rMsg.Compress = true
+		rMsg.Answer = rrs[start:min(start+transferBatchSize, len(rrs))]
+		if err = state.W.WriteMsg(rMsg); err != nil {
+			return dns.RcodeServerFailure, err
+		}
+	}
+	log.Infof("Transferred zone, zone: %s, client: %s, records: %d", qZone, state.IP(), len(rrs))
+	return dns.RcodeSuccess, nil
+}
+
+func (handler *PocketBaseHandler) composeResponseMsgs(composer *pb.Composer, records []*model.Record) (answers []dns.RR, extras []dns.RR, err error) {
 	answers = make([]dns.RR, 0, 10)
 	extras = make([]dns.RR, 0, 10)
 
@@ -133,23 +182,23 @@ func (handler *PocketBaseHandler) composeResponseMsgs(records []*model.Record) (
 		var err error
 		switch record.RecordType {
 		case "A":
-			answer, extras, err = handler.pbInst.ComposeARecord(record)
+			answer, extras, err = composer.ComposeARecord(record)
 		case "AAAA":
-			answer, extras, err = handler.pbInst.ComposeAAAARecord(record)
+			answer, extras, err = composer.ComposeAAAARecord(record)
 		case "CNAME":
-			answer, extras, err = handler.pbInst.ComposeCNAMERecord(record)
+			answer, extras, err = composer.ComposeCNAMERecord(record)
 		case "SOA":
-			answer, extras, err = handler.pbInst.ComposeSOARecord(record)
+			answer, extras, err = composer.ComposeSOARecord(record)
 		case "SRV":
-			answer, extras, err = handler.pbInst.ComposeSRVRecord(record)
+			answer, extras, err = composer.ComposeSRVRecord(record)
 		case "NS":
-			answer, extras, err = handler.pbInst.ComposeNSRecord(record)
+			answer, extras, err = composer.ComposeNSRecord(record)
 		case "MX":
-			answer, extras, err = handler.pbInst.ComposeMXRecord(record)
+			answer, extras, err = composer.ComposeMXRecord(record)
 		case "TXT":
-			answer, extras, err = handler.pbInst.ComposeTXTRecord(record)
+			answer, extras, err = composer.ComposeTXTRecord(record)
 		case "CAA":
-			answer, extras, err = handler.pbInst.ComposeCAARecord(record)
+			answer, extras, err = composer.ComposeCAARecord(record)
 		default:
 			return nil, nil, &ErrUnsupportedRecordType{RecordType: record.RecordType}
 		}
@@ -174,7 +223,8 @@ func NewWithConfig(config *Config) (handler *PocketBaseHandler, err error) {
 	}
 
 	handler = &PocketBaseHandler{
-		pbInst: nil,
+		pbInst:        nil,
+		disabledZones: finalConfig.DisabledZones,
 	}
 
 	pbInstance := pb.NewWithDataDir(finalConfig.DataDir).
@@ -182,7 +232,20 @@ func NewWithConfig(config *Config) (handler *PocketBaseHandler, err error) {
 		WithSuPassword(finalConfig.SuPassword).
 		WithListen(finalConfig.Listen).
 		WithDefaultTtl(finalConfig.DefaultTtl).
-		WithCacheCapacity(finalConfig.CacheCapacity)
+		WithTtlLimits(finalConfig.MinTtl, finalConfig.MaxTtl).
+		WithCacheCapacity(finalConfig.CacheCapacity).
+		WithAcmeDnsZone(finalConfig.AcmeDnsZone).
+		WithIntegrityOverride(finalConfig.IntegrityOverride).
+		WithClaimResolver(finalConfig.ClaimResolver).
+		WithGitops(finalConfig.GitopsDir, finalConfig.GitopsInterval, finalConfig.GitopsPrune).
+		WithZoneFileExports(finalConfig.ExportDir, finalConfig.ExportDelay).
+		WithSeedOverwrite(finalConfig.SeedOverwrite)
+	if finalConfig.ExternalDns {
+		pbInstance = pbInstance.WithExternalDns(finalConfig.ExternalDnsAllowFrom...)
+	}
+	for _, seed := range finalConfig.Seeds {
+		pbInstance = pbInstance.WithSeed(seed.Zone, seed.Records, seed.File)
+	}
 
 	handler.pbInst = pbInstance
 
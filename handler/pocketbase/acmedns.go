@@ -0,0 +1,209 @@
+package pocketbase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/google/uuid"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+const (
+	acmeAccountCollectionName = "coredns_acme_accounts"
+	// AcmeDnsRoutePrefix is the path prefix of the acme-dns compatible API,
+	// clients should use http://<listen>/acme-dns as the acme-dns server URL.
+	AcmeDnsRoutePrefix = "/acme-dns"
+	// acmeChallengeLabel is the label the challenge TXT records are published under.
+	acmeChallengeLabel = "_acme-challenge"
+	// acmeTxtLength is the length of a base64url encoded SHA-256 key authorization digest.
+	acmeTxtLength = 43
+	// acmeTxtKeep is the number of TXT values kept per account, two are needed
+	// to validate a wildcard and its apex within the same order.
+	acmeTxtKeep = 2
+	// acmeTxtTtl is the TTL of the challenge TXT records.
+	acmeTxtTtl = 1
+	// acmeAccountPasswordLength is the length of the generated account passwords.
+	acmeAccountPasswordLength = 40
+)
+
+var acmeTxtPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// acmeDnsAccount is the account representation returned by the register endpoint.
+type acmeDnsAccount struct {
+	Username   string   `json:"username"`
+	Password   string   `json:"password,omitempty"`
+	FullDomain string   `json:"fulldomain"`
+	SubDomain  string   `json:"subdomain"`
+	AllowFrom  []string `json:"allowfrom"`
+}
+
+// acmeDnsRegisterRequest is the optional body of the register endpoint.
+type acmeDnsRegisterRequest struct {
+	AllowFrom []string `json:"allowfrom"`
+}
+
+// acmeDnsUpdateRequest is the body of the update endpoint.
+type acmeDnsUpdateRequest struct {
+	SubDomain string `json:"subdomain"`
+	Txt       string `json:"txt"`
+}
+
+// bindAcmeDnsRoutes registers the acme-dns compatible routes,
+// it does nothing if no acme-dns zone is configured.
+func (inst *Instance) bindAcmeDnsRoutes(se *core.ServeEvent) {
+	if inst.acmeDnsZone == "" {
+		log.Debug("acme-dns zone is not configured, skipping acme-dns routes...")
+		return
+	}
+	log.Infof("Bind acme-dns routes, prefix: %s, zone: %s", AcmeDnsRoutePrefix, inst.acmeDnsZone)
+
+	se.Router.POST(AcmeDnsRoutePrefix+"/register", inst.handleAcmeDnsRegister)
+	se.Router.POST(AcmeDnsRoutePrefix+"/update", inst.handleAcmeDnsUpdate)
+	se.Router.GET(AcmeDnsRoutePrefix+"/health", func(e *core.RequestEvent) error {
+		return e.NoContent(http.StatusOK)
+	})
+}
+
+// handleAcmeDnsRegister creates a new acme-dns account with random credentials.
+func (inst *Instance) handleAcmeDnsRegister(e *core.RequestEvent) error {
+	var req acmeDnsRegisterRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return acmeDnsError(e, http.StatusBadRequest, "malformed_json_payload")
+	}
+	for _, cidr := range req.AllowFrom {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return acmeDnsError(e, http.StatusBadRequest, "invalid_allowfrom_cidr")
+		}
+	}
+	if req.AllowFrom == nil {
+		req.AllowFrom = []string{}
+	}
+
+	coll, err := inst.pb.FindCollectionByNameOrId(acmeAccountCollectionName)
+	if err != nil {
+		log.Errorf("Failed fetching collection [%s], err: %+v", acmeAccountCollectionName, err)
+		return acmeDnsError(e, http.StatusInternalServerError, "db_error")
+	}
+
+	account := &acmeDnsAccount{
+		Username:  uuid.NewString(),
+		Password:  security.RandomString(acmeAccountPasswordLength),
+		SubDomain: uuid.NewString(),
+		AllowFrom: req.AllowFrom,
+	}
+	account.FullDomain = inst.acmeDnsFullDomain(account.SubDomain)
+
+	rec := core.NewRecord(coll)
+	rec.Set("username", account.Username)
+	rec.Set("password", account.Password)
+	rec.Set("subdomain", account.SubDomain)
+	rec.Set("allow_from", account.AllowFrom)
+	if err = inst.pb.Save(rec); err != nil {
+		log.Errorf("Failed to save acme-dns account, err: %+v", err)
+		return acmeDnsError(e, http.StatusInternalServerError, "db_error")
+	}
+
+	log.Infof("Registered acme-dns account, username: %s, fulldomain: %s", account.Username, account.FullDomain)
+	return e.JSON(http.StatusCreated, account)
+}
+
+// handleAcmeDnsUpdate authenticates the account and publishes the challenge TXT value.
+func (inst *Instance) handleAcmeDnsUpdate(e *core.RequestEvent) error {
+	account, err := inst.pb.FindFirstRecordByData(acmeAccountCollectionName, "username", e.Request.Header.Get("X-Api-User"))
+	if err != nil || !account.ValidatePassword(e.Request.Header.Get("X-Api-Key")) {
+		return acmeDnsError(e, http.StatusUnauthorized, "forbidden")
+	}
+
+	var allowFrom []string
+	if err = account.UnmarshalJSONField("allow_from", &allowFrom); err != nil {
+		log.Errorf("Failed to unmarshal acme-dns allowfrom, username: %s, err: %+v", account.GetString("username"), err)
+	}
+	if !ipAllowed(e.RealIP(), allowFrom) {
+		return acmeDnsError(e, http.StatusUnauthorized, "forbidden")
+	}
+
+	var req acmeDnsUpdateRequest
+	if err = json.NewDecoder(e.Request.Body).Decode(&req); err != nil {
+		return acmeDnsError(e, http.StatusBadRequest, "malformed_json_payload")
+	}
+	if req.SubDomain != account.GetString("subdomain") {
+		return acmeDnsError(e, http.StatusUnauthorized, "forbidden")
+	}
+	if len(req.Txt) != acmeTxtLength || !acmeTxtPattern.MatchString(req.Txt) {
+		return acmeDnsError(e, http.StatusBadRequest, "bad_txt")
+	}
+
+	ctx := WithActor(e.Request.Context(), Actor{Type: ActorAcmeDns, Name: account.GetString("username")})
+	if err = inst.publishAcmeChallenge(ctx, inst.acmeDnsFullDomain(req.SubDomain), req.Txt); err != nil {
+		log.Errorf("Failed to publish acme challenge, subdomain: %s, err: %+v", req.SubDomain, err)
+		return acmeDnsError(e, http.StatusInternalServerError, "db_error")
+	}
+	return e.JSON(http.StatusOK, map[string]string{"txt": req.Txt})
+}
+
+// publishAcmeChallenge writes the TXT value at name, keeping at most acmeTxtKeep values
+// by overwriting the least recently updated one.
+func (inst *Instance) publishAcmeChallenge(ctx context.Context, name string, txt string) error {
+	coll, err := inst.pb.FindCollectionByNameOrId(recordCollectionName)
+	if err != nil {
+		return err
+	}
+	existing, err := inst.pb.FindRecordsByFilter(coll,
+		"zone = {:zone} && name = {:name} && record_type = 'TXT'", "updated", 0, 0,
+		dbx.Params{"zone": inst.acmeDnsZone, "name": name})
+	if err != nil {
+		return err
+	}
+
+	content := m.TXTRecord{Text: txt}
+	var rec *core.Record
+	if len(existing) < acmeTxtKeep {
+		rec, err = newRecord(coll, inst.acmeDnsZone, name, "TXT", acmeTxtTtl, content)
+		if err != nil {
+			return err
+		}
+	} else {
+		rec = existing[0]
+		if err = setRecordContent(rec, acmeTxtTtl, content); err != nil {
+			return err
+		}
+	}
+	log.Debugf("Publishing acme challenge, name: %s, txt: %s", name, txt)
+	return inst.pb.SaveWithContext(ctx, rec)
+}
+
+// acmeDnsFullDomain returns the name the challenge TXT records of a subdomain are published under.
+func (inst *Instance) acmeDnsFullDomain(subDomain string) string {
+	return acmeChallengeLabel + "." + subDomain + "." + inst.acmeDnsZone
+}
+
+// ipAllowed reports whether ip is within one of the CIDRs, an empty list allows any address.
+func ipAllowed(ip string, cidrs []string) bool {
+	if len(cidrs) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err == nil && ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func acmeDnsError(e *core.RequestEvent, status int, reason string) error {
+	return e.JSON(status, map[string]string{"error": reason})
+}
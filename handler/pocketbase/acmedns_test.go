@@ -0,0 +1,33 @@
+package pocketbase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIpAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		ip       string
+		cidrs    []string
+		expected bool
+	}{
+		{name: "no restriction", ip: "192.0.2.1", cidrs: nil, expected: true},
+		{name: "within cidr", ip: "192.0.2.1", cidrs: []string{"192.0.2.0/24"}, expected: true},
+		{name: "outside cidr", ip: "198.51.100.1", cidrs: []string{"192.0.2.0/24"}, expected: false},
+		{name: "ipv6 within cidr", ip: "2001:db8::1", cidrs: []string{"192.0.2.0/24", "2001:db8::/32"}, expected: true},
+		{name: "invalid ip", ip: "invalid", cidrs: []string{"192.0.2.0/24"}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ipAllowed(tt.ip, tt.cidrs))
+		})
+	}
+}
+
+func TestAcmeDnsFullDomain(t *testing.T) {
+	inst := NewWithDataDir(t.TempDir()).WithAcmeDnsZone("acme.example.com")
+	assert.Equal(t, "_acme-challenge.sub.acme.example.com.", inst.acmeDnsFullDomain("sub"))
+}
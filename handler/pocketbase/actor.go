@@ -0,0 +1,91 @@
+package pocketbase
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Actor types recorded in the record history.
+const (
+	ActorSuperuser   = "superuser"
+	ActorAuth        = "auth"
+	ActorToken       = "token"
+	ActorDdns        = "ddns"
+	ActorAcmeDns     = "acme-dns"
+	ActorExternalDns = "external-dns"
+	ActorSystem      = "system"
+)
+
+// Actor identifies who changed a record.
+type Actor struct {
+	Type string // The kind of actor, see Actor* constants
+	Name string // The identity of the actor, e.g. an email, a DDNS username or a token name
+}
+
+// systemActor is the actor of changes made by the plugin itself, e.g. migrations and cascades.
+var systemActor = Actor{Type: ActorSystem}
+
+type actorCtxKey struct{}
+
+// WithActor returns a context which attributes the saves and deletes made with it to the actor.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorCtxKey{}, actor)
+}
+
+// actorFromContext returns the actor attributed by the context, if any.
+func actorFromContext(ctx context.Context) (Actor, bool) {
+	if ctx == nil {
+		return Actor{}, false
+	}
+	actor, ok := ctx.Value(actorCtxKey{}).(Actor)
+	return actor, ok
+}
+
+// requestActor returns the actor of an API request, from its API token or its authenticated record.
+func requestActor(e *core.RequestEvent) Actor {
+	if token, ok := requestApiToken(e); ok {
+		return Actor{Type: ActorToken, Name: token.GetString("name")}
+	}
+	switch {
+	case e.Auth == nil:
+		return Actor{Type: ActorAuth, Name: "guest"}
+	case e.Auth.IsSuperuser():
+		return Actor{Type: ActorSuperuser, Name: e.Auth.Email()}
+	case e.Auth.Email() != "":
+		return Actor{Type: ActorAuth, Name: e.Auth.Email()}
+	default:
+		return Actor{Type: ActorAuth, Name: e.Auth.Collection().Name + "/" + e.Auth.Id}
+	}
+}
+
+// requestActors keeps the actors of the records being changed through the PocketBase record API,
+// as the API does not pass the request context to the saves and deletes it makes.
+type requestActors struct {
+	actors sync.Map // *core.Record -> Actor
+}
+
+// bind attributes the changes of the records of the collection made through the record API to the request actor.
+func (ra *requestActors) bind(app core.App, collection string) {
+	bindFunc := func(e *core.RecordRequestEvent) error {
+		ra.actors.Store(e.Record, requestActor(e.RequestEvent))
+		defer ra.actors.Delete(e.Record)
+		return e.Next()
+	}
+	app.OnRecordCreateRequest(collection).BindFunc(bindFunc)
+	app.OnRecordUpdateRequest(collection).BindFunc(bindFunc)
+	app.OnRecordDeleteRequest(collection).BindFunc(bindFunc)
+}
+
+// actorOf returns the actor of a record change, from the context of the change,
+// the record API request changing the record, or the system actor.
+func (ra *requestActors) actorOf(ctx context.Context, rec *core.Record) Actor {
+	if actor, ok := actorFromContext(ctx); ok {
+		return actor
+	}
+	if actor, ok := ra.actors.Load(rec); ok {
+		return actor.(Actor)
+	}
+	return systemActor
+}
@@ -0,0 +1,41 @@
+package pocketbase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActorOf(t *testing.T) {
+	var actors requestActors
+	rec := core.NewRecord(core.NewBaseCollection(recordCollectionName))
+	ddns := Actor{Type: ActorDdns, Name: "home"}
+	superuser := Actor{Type: ActorSuperuser, Name: "su@example.com"}
+
+	assert.Equal(t, systemActor, actors.actorOf(context.Background(), rec))
+
+	actors.actors.Store(rec, superuser)
+	assert.Equal(t, superuser, actors.actorOf(context.Background(), rec))
+	assert.Equal(t, ddns, actors.actorOf(WithActor(context.Background(), ddns), rec))
+
+	actors.actors.Delete(rec)
+	assert.Equal(t, systemActor, actors.actorOf(context.TODO(), rec))
+}
+
+func TestRecordSnapshot(t *testing.T) {
+	assert.Nil(t, recordSnapshot(nil))
+
+	coll := core.NewBaseCollection(recordCollectionName)
+	coll.Fields.Add(&core.TextField{Name: "zone"}, &core.TextField{Name: "name"}, &core.NumberField{Name: "ttl"})
+	rec := core.NewRecord(coll)
+	rec.Set("zone", "example.com.")
+	rec.Set("name", "www.example.com.")
+	rec.Set("ttl", 300)
+
+	snapshot := recordSnapshot(rec)
+	assert.Len(t, snapshot, len(historyFields))
+	assert.Equal(t, "www.example.com.", snapshot["name"])
+	assert.Equal(t, float64(300), snapshot["ttl"])
+}
@@ -0,0 +1,26 @@
+package pocketbase
+
+import (
+	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// CoreDnsApiPrefix is the path prefix of the DNS management API routes.
+const CoreDnsApiPrefix = "/api/coredns"
+
+// bindApiRoutes registers the DNS management API routes, each route requires its own authorization.
+func (inst *Instance) bindApiRoutes(se *core.ServeEvent) {
+	log.Infof("Bind DNS management API routes, prefix: %s", CoreDnsApiPrefix)
+
+	g := se.Router.Group(CoreDnsApiPrefix)
+	inst.bindHistoryRoutes(g)
+	inst.bindSnapshotRoutes(g)
+	inst.bindChangeSetRoutes(g)
+	inst.bindApiTokenRoutes(g)
+	inst.bindZoneClaimRoutes(g)
+	inst.bindRRsetRoutes(g)
+	inst.bindBulkRoutes(g)
+	inst.bindZoneImportRoutes(g)
+	inst.bindZoneExportRoutes(g)
+	inst.bindGitopsRoutes(g)
+}
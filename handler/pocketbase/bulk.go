@@ -0,0 +1,225 @@
+package pocketbase
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/miekg/dns"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+// Bulk RRset operations.
+const (
+	BulkCreate  = "create"
+	BulkReplace = "replace"
+	BulkDelete  = "delete"
+)
+
+// bulkMaxOperations limits the operations of a bulk request, larger batches should be split.
+const bulkMaxOperations = 1000
+
+// bulkOperation creates, replaces or deletes a RRset of a zone.
+type bulkOperation struct {
+	Op   string `json:"op"`
+	Zone string `json:"zone"`
+	rrset
+}
+
+// bulkRequest is the payload of the bulk route.
+type bulkRequest struct {
+	Operations []*bulkOperation `json:"operations"`
+}
+
+// bulkZone is a zone changed by a bulk request, with its serial once the changes are applied.
+type bulkZone struct {
+	Name   string `json:"name"`
+	Serial uint32 `json:"serial"`
+}
+
+// bulkResult answers a bulk request.
+type bulkResult struct {
+	Applied int         `json:"applied"`
+	Zones   []*bulkZone `json:"zones"`
+}
+
+// bindBulkRoutes registers the bulk route.
+func (inst *Instance) bindBulkRoutes(g *router.RouterGroup[*core.RequestEvent]) {
+	g.POST("/bulk", inst.handleBulk).Bind(apis.RequireAuth())
+}
+
+// handleBulk applies a batch of RRset operations on one or more zones within one transaction,
+// after taking an automatic snapshot of every zone being changed. The batch is validated as a whole,
+// the integrity of each zone is checked, its serial is bumped and the caches are invalidated once.
+// Any failing operation rolls back the whole batch, answering with the errors of all operations keyed by index.
+func (inst *Instance) handleBulk(e *core.RequestEvent) error {
+	body := &bulkRequest{}
+	if err := e.BindBody(body); err != nil {
+		return e.BadRequestError("Failed to decode bulk operations.", err)
+	}
+	if len(body.Operations) == 0 {
+		return e.BadRequestError("No bulk operations.", nil)
+	}
+	if len(body.Operations) > bulkMaxOperations {
+		return e.BadRequestError(fmt.Sprintf("Too many bulk operations, at most %d are allowed.", bulkMaxOperations), nil)
+	}
+	errs := validateBulkOperations(body.Operations)
+	zones := make([]string, 0)
+	for i, op := range body.Operations {
+		if _, ok := errs[strconv.Itoa(i)]; ok || slices.Contains(zones, op.Zone) {
+			continue
+		}
+		if _, err := inst.pb.FindFirstRecordByData(zoneCollectionName, "name", op.Zone); err != nil {
+			errs[strconv.Itoa(i)] = validation.NewError("validation_invalid_zone", "zone "+op.Zone+" not found")
+			continue
+		}
+		zones = append(zones, op.Zone)
+	}
+	if len(errs) > 0 {
+		return e.BadRequestError("Invalid bulk operations.", errs)
+	}
+	for _, op := range body.Operations {
+		// creating and replacing both need the rights to create and update, whether the RRset exists is known later
+		if err := inst.requireBulkAccess(e, op); err != nil {
+			return err
+		}
+	}
+
+	ctx := deferCacheInvalidation(withoutSerialBumps(WithActor(e.Request.Context(), requestActor(e))))
+	err := inst.pb.RunInTransaction(func(txApp core.App) error {
+		for _, zone := range zones {
+			if _, err := inst.createZoneSnapshot(ctx, txApp, zone, SnapshotAuto, "before bulk changes"); err != nil {
+				return err
+			}
+		}
+		// a single operation may leave its name inconsistent until a later one, only the zones are checked at the end
+		opCtx := WithoutIntegrityChecks(ctx)
+		errs := validation.Errors{}
+		for i, op := range body.Operations {
+			existing, err := findRRSet(txApp, op.Zone, op.Name, op.Type)
+			if err != nil {
+				return err
+			}
+			switch {
+			case op.Op == BulkCreate && len(existing) > 0:
+				err = fmt.Errorf("RRset %s %s already exists", op.Name, op.Type)
+			case op.Op == BulkDelete && len(existing) == 0:
+				err = fmt.Errorf("RRset %s %s not found", op.Name, op.Type)
+			case op.Op == BulkDelete:
+				err = inst.deleteRRSet(opCtx, txApp, op.Zone, op.Name, op.Type)
+			default:
+				_, err = inst.saveRRset(opCtx, txApp, op.Zone, op.Name, op.Type, &op.rrset, existing)
+			}
+			if err != nil {
+				errs[strconv.Itoa(i)] = validation.NewError("validation_invalid_operation", err.Error())
+			}
+		}
+		for _, zone := range zones {
+			if err := inst.checkZoneIntegrity(txApp, zone); err != nil {
+				errs[zone] = validation.NewError("validation_invalid_zone", err.Error())
+			}
+		}
+		if len(errs) > 0 {
+			return errs
+		}
+		for _, zone := range zones {
+			if err := bumpZoneSerial(txApp, zone); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Failed to apply bulk operations, err: %+v", err)
+		return firstApiError(e, err, "Failed to apply bulk operations.")
+	}
+
+	result := &bulkResult{Applied: len(body.Operations), Zones: make([]*bulkZone, 0, len(zones))}
+	for _, op := range body.Operations {
+		inst.invalidateRecordCaches(op.Zone, op.Name, op.Type)
+	}
+	for _, zone := range zones {
+		inst.invalidateZoneCaches(zone)
+		bz := &bulkZone{Name: zone}
+		if rec, err := inst.pb.FindFirstRecordByData(zoneCollectionName, "name", zone); err == nil {
+			bz.Serial = uint32(rec.GetInt("serial"))
+		}
+		result.Zones = append(result.Zones, bz)
+	}
+	log.Infof("Applied bulk operations, operations: %d, zones: %v", len(body.Operations), zones)
+	return e.JSON(http.StatusOK, result)
+}
+
+// requireBulkAccess returns an error unless the request may apply the operation.
+func (inst *Instance) requireBulkAccess(e *core.RequestEvent, op *bulkOperation) error {
+	ops := []string{TokenDelete}
+	if op.Op != BulkDelete {
+		ops = []string{TokenCreate, TokenUpdate}
+	}
+	for _, tokenOp := range ops {
+		if err := inst.requireRRsetAccess(e, op.Zone, op.Name, op.Type, tokenOp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateBulkOperations normalizes the zones, names and types of the operations in place
+// and validates their records, returning the errors keyed by the index of the operation.
+func validateBulkOperations(ops []*bulkOperation) validation.Errors {
+	errs := validation.Errors{}
+	for i, op := range ops {
+		if err := validateBulkOperation(op); err != nil {
+			errs[strconv.Itoa(i)] = validation.NewError("validation_invalid_operation", err.Error())
+		}
+	}
+	return errs
+}
+
+// validateBulkOperation normalizes and validates one bulk operation.
+func validateBulkOperation(op *bulkOperation) error {
+	if op == nil {
+		return fmt.Errorf("the operation is empty")
+	}
+	switch op.Op {
+	case BulkCreate, BulkReplace, BulkDelete:
+	default:
+		return fmt.Errorf("unknown operation %q, expected one of %s, %s, %s", op.Op, BulkCreate, BulkReplace, BulkDelete)
+	}
+	op.Zone = strings.ToLower(dns.Fqdn(op.Zone))
+	if err := m.ValidateZoneName(op.Zone); err != nil {
+		return err
+	}
+	name, err := rrsetName(op.Zone, op.Name)
+	if err != nil {
+		return err
+	}
+	op.Name = name
+	op.Type = strings.ToUpper(op.Type)
+	if !m.IsSupportedRecordType(op.Type) {
+		return fmt.Errorf("unsupported record type %s", op.Type)
+	}
+	if op.Op == BulkDelete {
+		return nil
+	}
+	if len(op.Records) == 0 {
+		return fmt.Errorf("the RRset %s %s has no records, delete it instead", op.Name, op.Type)
+	}
+	for _, rec := range op.Records {
+		if rec == nil {
+			return fmt.Errorf("the RRset %s %s has an empty record", op.Name, op.Type)
+		}
+		err = m.ValidateRecord(&m.Record{Zone: op.Zone, Name: op.Name, RecordType: op.Type, Ttl: op.Ttl, Content: string(rec.Content)})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package pocketbase
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/tools/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateBulkOperations(t *testing.T) {
+	record := func(content string) []*rrsetRecord {
+		return []*rrsetRecord{{Content: json.RawMessage(content)}}
+	}
+	tests := []struct {
+		name     string
+		op       *bulkOperation
+		wantErr  bool
+		wantName string
+	}{
+		{name: "create", op: &bulkOperation{Op: BulkCreate, Zone: "Example.com",
+			rrset: rrset{Name: "www", Type: "a", Ttl: 300, Records: record(`{"ip": "192.0.2.1"}`)}}, wantName: "www.example.com."},
+		{name: "replace apex", op: &bulkOperation{Op: BulkReplace, Zone: "example.com.",
+			rrset: rrset{Name: "@", Type: "TXT", Ttl: 300, Records: record(`{"text": "hello"}`)}}, wantName: "example.com."},
+		{name: "delete without records", op: &bulkOperation{Op: BulkDelete, Zone: "example.com.",
+			rrset: rrset{Name: "old", Type: "CNAME"}}, wantName: "old.example.com."},
+		{name: "unknown operation", op: &bulkOperation{Op: "upsert", Zone: "example.com.",
+			rrset: rrset{Name: "www", Type: "A"}}, wantErr: true},
+		{name: "outside zone", op: &bulkOperation{Op: BulkDelete, Zone: "example.com.",
+			rrset: rrset{Name: "www.example.org.", Type: "A"}}, wantErr: true},
+		{name: "unsupported type", op: &bulkOperation{Op: BulkDelete, Zone: "example.com.",
+			rrset: rrset{Name: "www", Type: "NAPTR"}}, wantErr: true},
+		{name: "no records", op: &bulkOperation{Op: BulkCreate, Zone: "example.com.",
+			rrset: rrset{Name: "www", Type: "A", Ttl: 300}}, wantErr: true},
+		{name: "invalid content", op: &bulkOperation{Op: BulkReplace, Zone: "example.com.",
+			rrset: rrset{Name: "www", Type: "A", Ttl: 300, Records: record(`{"ip": "nope"}`)}}, wantErr: true},
+		{name: "empty", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := validateBulkOperations([]*bulkOperation{tt.op})
+			if tt.wantErr {
+				assert.Contains(t, errs, "0")
+				return
+			}
+			assert.Empty(t, errs)
+			assert.Equal(t, tt.wantName, tt.op.Name)
+		})
+	}
+}
+
+func TestHandleBulkOperationOrder(t *testing.T) {
+	swapToA := map[string]any{"op": BulkReplace, "zone": "example.com.", "name": "www", "type": "A", "ttl": 300,
+		"records": []map[string]any{{"content": map[string]string{"ip": "192.0.2.80"}}}}
+	deleteCname := map[string]any{"op": BulkDelete, "zone": "example.com.", "name": "www", "type": "CNAME"}
+	tests := []struct {
+		name       string
+		operations []map[string]any
+		wantStatus int
+	}{
+		{name: "replace before delete", operations: []map[string]any{swapToA, deleteCname}, wantStatus: http.StatusOK},
+		{name: "delete before replace", operations: []map[string]any{deleteCname, swapToA}, wantStatus: http.StatusOK},
+		{name: "replace without delete", operations: []map[string]any{swapToA}, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inst := newMigratedInstance(t)
+			importTestZone(t, inst, "example.com.", `$ORIGIN example.com.
+@   3600 IN SOA ns1 hostmaster 1 7200 3600 1209600 300
+@   3600 IN NS  ns1
+ns1 3600 IN A   192.0.2.53
+www  300 IN CNAME ns1
+`)
+			body, err := json.Marshal(map[string]any{"operations": tt.operations})
+			require.NoError(t, err)
+			e := superuserEvent(t, inst)
+			e.Request = httptest.NewRequest(http.MethodPost, CoreDnsApiPrefix+"/bulk", bytes.NewReader(body))
+			e.Request.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			e.Response = rec
+
+			err = inst.handleBulk(e)
+			if tt.wantStatus != http.StatusOK {
+				var apiErr *router.ApiError
+				require.ErrorAs(t, err, &apiErr)
+				assert.Equal(t, tt.wantStatus, apiErr.Status)
+				cname, err := findRRSet(inst.pb, "example.com.", "www.example.com.", "CNAME")
+				require.NoError(t, err)
+				assert.Len(t, cname, 1, "the batch is rolled back")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			a, err := findRRSet(inst.pb, "example.com.", "www.example.com.", "A")
+			require.NoError(t, err)
+			assert.Len(t, a, 1)
+			cname, err := findRRSet(inst.pb, "example.com.", "www.example.com.", "CNAME")
+			require.NoError(t, err)
+			assert.Empty(t, cname)
+		})
+	}
+}
@@ -58,3 +58,19 @@ func TestRecordsCache(t *testing.T) {
 	_, ok = cache.Get("non_existent")
 	assert.False(t, ok)
 }
+
+func TestZoneSettingsCache(t *testing.T) {
+	cache, err := NewZoneSettingsCache()
+	assert.NoError(t, err)
+
+	zone := &m.Zone{Name: "example.com.", Serial: 2025010101, State: m.ZoneStateActive}
+	cache.Set(zone.Name, zone)
+	cache.cacheInst.Wait()
+	cached, ok := cache.Get(zone.Name)
+	assert.True(t, ok)
+	assert.Equal(t, zone, cached)
+
+	cache.Delete(zone.Name)
+	_, ok = cache.Get(zone.Name)
+	assert.False(t, ok)
+}
@@ -0,0 +1,44 @@
+// Package cache provides caching functionality for DNS records and zones.
+package cache
+
+import (
+	"github.com/dgraph-io/ristretto/v2"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+// ZoneSettingsCache provides caching for zone settings using Ristretto cache.
+// It stores a mapping of zone names to their settings.
+type ZoneSettingsCache struct {
+	cacheInst *ristretto.Cache[string, *m.Zone]
+}
+
+// NewZoneSettingsCache creates a new ZoneSettingsCache instance with default configuration.
+// Returns the cache instance and any error encountered during initialization.
+func NewZoneSettingsCache() (*ZoneSettingsCache, error) {
+	cacheInst, err := ristretto.NewCache(&ristretto.Config[string, *m.Zone]{
+		NumCounters: 1 << 16,
+		MaxCost:     1 << 16,
+		BufferItems: 64,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ZoneSettingsCache{
+		cacheInst: cacheInst,
+	}, nil
+}
+
+// Get retrieves the settings of a zone from the cache.
+// Returns the settings and a boolean indicating if the zone was found.
+func (c *ZoneSettingsCache) Get(zone string) (*m.Zone, bool) {
+	return c.cacheInst.Get(zone)
+}
+
+// Set stores the settings of a zone in the cache.
+func (c *ZoneSettingsCache) Set(zone string, value *m.Zone) {
+	c.cacheInst.Set(zone, value, 1)
+}
+
+func (c *ZoneSettingsCache) Delete(zone string) {
+	c.cacheInst.Del(zone)
+}
@@ -0,0 +1,398 @@
+package pocketbase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+	"github.com/pocketbase/pocketbase/tools/types"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+const (
+	changeSetCollectionName    = "coredns_change_sets"
+	stagedChangeCollectionName = "coredns_staged_changes"
+)
+
+// Change set states, a change set is edited as draft, submitted for review as pending,
+// approved or rejected by a second user and finally published.
+const (
+	ChangeSetDraft     = "draft"
+	ChangeSetPending   = "pending"
+	ChangeSetApproved  = "approved"
+	ChangeSetRejected  = "rejected"
+	ChangeSetPublished = "published"
+)
+
+// Staged change operations.
+const (
+	StagedCreate = "create"
+	StagedUpdate = "update"
+	StagedDelete = "delete"
+)
+
+// errPreviewRollback rolls back the transaction a change set is previewed in.
+var errPreviewRollback = errors.New("preview rollback")
+
+// rrsetAnswers is the answers served for a RRset before and after a change set is published.
+type rrsetAnswers struct {
+	Name       string   `json:"name"`
+	RecordType string   `json:"record_type"`
+	Before     []string `json:"before"`
+	After      []string `json:"after"`
+}
+
+// changeSetPreview is the outcome of publishing a change set, computed without publishing it.
+type changeSetPreview struct {
+	Zone    string          `json:"zone"`
+	Valid   bool            `json:"valid"`
+	Errors  []string        `json:"errors"`
+	Diff    *zoneDiff       `json:"diff"`
+	Answers []*rrsetAnswers `json:"answers"`
+}
+
+// bindChangeSetEvents keeps change sets consistent when edited through the PocketBase API and UI:
+//   - change sets are created as drafts of the requesting user, their state is changed through the change set routes only,
+//   - staged changes can only be added to, changed in and removed from drafts,
+//   - staged records are validated like the records of the records collection.
+func (inst *Instance) bindChangeSetEvents() {
+	log.Debug("Bind change set events...")
+
+	inst.pb.OnRecordCreateRequest(changeSetCollectionName).BindFunc(func(e *core.RecordRequestEvent) error {
+		if err := m.ValidateZoneName(e.Record.GetString("zone")); err != nil {
+			return e.BadRequestError("Invalid change set, "+err.Error()+".", toValidationErrors(err))
+		}
+		e.Record.Set("state", ChangeSetDraft)
+		e.Record.Set("author", requestActor(e.RequestEvent).Name)
+		for _, field := range []string{"approver", "publisher", "snapshot", "published"} {
+			e.Record.Set(field, nil)
+		}
+		return e.Next()
+	})
+	inst.pb.OnRecordUpdateRequest(changeSetCollectionName).BindFunc(func(e *core.RecordRequestEvent) error {
+		original := e.Record.Original()
+		if original.GetString("state") != ChangeSetDraft {
+			return e.BadRequestError("Only draft change sets can be changed.", nil)
+		}
+		for _, field := range []string{"zone", "state", "author", "approver", "publisher", "snapshot", "published"} {
+			e.Record.Set(field, original.Get(field))
+		}
+		return e.Next()
+	})
+
+	stagedFunc := func(e *core.RecordRequestEvent) error {
+		changeSet, err := e.App.FindRecordById(changeSetCollectionName, e.Record.GetString("change_set"))
+		if err != nil {
+			return e.BadRequestError("Change set not found.", err)
+		}
+		if original := e.Record.Original(); !e.Record.IsNew() && original.GetString("change_set") != changeSet.Id {
+			return e.BadRequestError("Staged changes can not be moved to another change set.", nil)
+		}
+		if changeSet.GetString("state") != ChangeSetDraft {
+			return e.BadRequestError("Only the staged changes of draft change sets can be changed.", nil)
+		}
+		if e.Request.Method != http.MethodDelete {
+			if err = validateStagedChange(e.App, changeSet.GetString("zone"), e.Record); err != nil {
+				return e.BadRequestError("Invalid staged change, "+err.Error()+".", toValidationErrors(err))
+			}
+		}
+		return e.Next()
+	}
+	inst.pb.OnRecordCreateRequest(stagedChangeCollectionName).BindFunc(stagedFunc)
+	inst.pb.OnRecordUpdateRequest(stagedChangeCollectionName).BindFunc(stagedFunc)
+	inst.pb.OnRecordDeleteRequest(stagedChangeCollectionName).BindFunc(stagedFunc)
+}
+
+// validateStagedChange checks the record of an update or delete exists in the zone,
+// and the staged record of a create or update like a record of the records collection.
+func validateStagedChange(app core.App, zone string, change *core.Record) error {
+	operation := change.GetString("operation")
+	if operation == StagedUpdate || operation == StagedDelete {
+		rec, err := app.FindRecordById(recordCollectionName, change.GetString("record"))
+		if err != nil || rec.GetString("zone") != zone {
+			return &m.ValidationError{Field: "record", Message: fmt.Sprintf("record not found in zone %s", zone)}
+		}
+	}
+	if operation == StagedDelete {
+		return nil
+	}
+	return m.ValidateRecord(&m.Record{
+		Zone:       zone,
+		Name:       change.GetString("name"),
+		RecordType: change.GetString("record_type"),
+		Ttl:        uint32(change.GetInt("ttl")),
+		Content:    change.GetString("content"),
+	})
+}
+
+// bindChangeSetRoutes registers the change set review and publish routes.
+func (inst *Instance) bindChangeSetRoutes(g *router.RouterGroup[*core.RequestEvent]) {
+	g.GET("/changesets/{id}/preview", inst.handlePreviewChangeSet).Bind(apis.RequireAuth())
+	g.POST("/changesets/{id}/submit", inst.handleChangeSetTransition(ChangeSetPending)).Bind(apis.RequireAuth())
+	g.POST("/changesets/{id}/approve", inst.handleChangeSetTransition(ChangeSetApproved)).Bind(apis.RequireAuth())
+	g.POST("/changesets/{id}/reject", inst.handleChangeSetTransition(ChangeSetRejected)).Bind(apis.RequireAuth())
+	g.POST("/changesets/{id}/publish", inst.handlePublishChangeSet).Bind(apis.RequireAuth())
+}
+
+// handlePreviewChangeSet returns the diff, the answers and the integrity of the zone the change set would publish.
+func (inst *Instance) handlePreviewChangeSet(e *core.RequestEvent) error {
+	changeSet, err := inst.pb.FindRecordById(changeSetCollectionName, e.Request.PathValue("id"))
+	if err != nil {
+		return e.NotFoundError("Change set not found.", err)
+	}
+	if err = inst.requireZoneRole(e, changeSet.GetString("zone"), zoneReaders); err != nil {
+		return err
+	}
+	if state := changeSet.GetString("state"); state == ChangeSetPublished || state == ChangeSetRejected {
+		return e.BadRequestError("Change set is "+state+".", nil)
+	}
+	preview, err := inst.previewChangeSet(WithActor(e.Request.Context(), requestActor(e)), changeSet)
+	if err != nil {
+		log.Errorf("Failed to preview change set, id: %s, err: %+v", changeSet.Id, err)
+		return e.InternalServerError("Failed to preview change set.", err)
+	}
+	return e.JSON(http.StatusOK, preview)
+}
+
+// handleChangeSetTransition returns a handler moving a change set to the given review state:
+//   - drafts are submitted as pending,
+//   - pending change sets are approved or rejected by another user than their author.
+func (inst *Instance) handleChangeSetTransition(state string) func(e *core.RequestEvent) error {
+	from := map[string]string{
+		ChangeSetPending:  ChangeSetDraft,
+		ChangeSetApproved: ChangeSetPending,
+		ChangeSetRejected: ChangeSetPending,
+	}[state]
+
+	return func(e *core.RequestEvent) error {
+		changeSet, err := inst.pb.FindRecordById(changeSetCollectionName, e.Request.PathValue("id"))
+		if err != nil {
+			return e.NotFoundError("Change set not found.", err)
+		}
+		if err = inst.requireZoneRole(e, changeSet.GetString("zone"), zoneEditors); err != nil {
+			return err
+		}
+		if changeSet.GetString("state") != from {
+			return e.BadRequestError(fmt.Sprintf("Change set is %s, not %s.", changeSet.GetString("state"), from), nil)
+		}
+		actor := requestActor(e)
+		if state != ChangeSetPending {
+			if _, ok := requestApiToken(e); ok {
+				return e.ForbiddenError("Change sets must be reviewed by a user, not an API token.", nil)
+			}
+			if actor.Name == changeSet.GetString("author") {
+				return e.ForbiddenError("Change sets must be reviewed by another user than their author.", nil)
+			}
+			changeSet.Set("approver", actor.Name)
+		}
+		changeSet.Set("state", state)
+		if err = inst.pb.Save(changeSet); err != nil {
+			return e.InternalServerError("Failed to save change set.", err)
+		}
+		log.Infof("Change set %s, id: %s, zone: %s, by: %s", state, changeSet.Id, changeSet.GetString("zone"), actor.Name)
+		return e.JSON(http.StatusOK, changeSet)
+	}
+}
+
+// handlePublishChangeSet applies the staged changes of a change set to its zone within one transaction,
+// after taking an automatic snapshot of the zone. Change sets requiring approval must be approved first.
+func (inst *Instance) handlePublishChangeSet(e *core.RequestEvent) error {
+	changeSet, err := inst.pb.FindRecordById(changeSetCollectionName, e.Request.PathValue("id"))
+	if err != nil {
+		return e.NotFoundError("Change set not found.", err)
+	}
+	if err = inst.requireZoneRole(e, changeSet.GetString("zone"), zoneEditors); err != nil {
+		return err
+	}
+	switch state := changeSet.GetString("state"); {
+	case state == ChangeSetApproved:
+	case state == ChangeSetPublished || state == ChangeSetRejected:
+		return e.BadRequestError("Change set is "+state+".", nil)
+	case changeSet.GetBool("requires_approval"):
+		return e.ForbiddenError("Change set requires approval.", nil)
+	}
+
+	actor := requestActor(e)
+	err = inst.pb.RunInTransaction(func(txApp core.App) error {
+		// re-read within the transaction, so a concurrent publish cannot apply the changes twice
+		current, err := txApp.FindRecordById(changeSetCollectionName, changeSet.Id)
+		if err != nil {
+			return err
+		}
+		if state := current.GetString("state"); state == ChangeSetPublished || state == ChangeSetRejected {
+			return fmt.Errorf("change set is %s", state)
+		}
+		changeSet = current
+		snapshot, err := inst.createZoneSnapshot(WithActor(e.Request.Context(), actor), txApp, changeSet.GetString("zone"),
+			SnapshotAuto, "before publish of change set "+changeSet.Id)
+		if err != nil {
+			return err
+		}
+		if err = inst.publishChangeSet(WithActor(e.Request.Context(), actor), txApp, changeSet); err != nil {
+			return err
+		}
+		changeSet.Set("state", ChangeSetPublished)
+		changeSet.Set("publisher", actor.Name)
+		changeSet.Set("snapshot", snapshot.Id)
+		changeSet.Set("published", types.NowDateTime())
+		return txApp.Save(changeSet)
+	})
+	if err != nil {
+		log.Errorf("Failed to publish change set, id: %s, err: %+v", changeSet.Id, err)
+		return e.BadRequestError("Failed to publish change set: "+err.Error(), err)
+	}
+	log.Infof("Published change set, id: %s, zone: %s, by: %s", changeSet.Id, changeSet.GetString("zone"), actor.Name)
+	return e.JSON(http.StatusOK, changeSet)
+}
+
+// publishChangeSet applies the staged changes of the change set, checks the integrity of the zone
+// and bumps its serial once, so it should be called within a transaction.
+func (inst *Instance) publishChangeSet(ctx context.Context, app core.App, changeSet *core.Record) error {
+	zone := changeSet.GetString("zone")
+	if err := inst.applyStagedChanges(ctx, app, changeSet); err != nil {
+		return err
+	}
+	if err := inst.checkZoneIntegrity(app, zone); err != nil {
+		return err
+	}
+	return bumpZoneSerial(app, zone)
+}
+
+// previewChangeSet applies the staged changes of the change set within a transaction which is rolled back,
+// returning the resulting diff and answers of the zone and the problems found.
+func (inst *Instance) previewChangeSet(ctx context.Context, changeSet *core.Record) (*changeSetPreview, error) {
+	zone := changeSet.GetString("zone")
+	before, err := zoneSnapshotRecords(inst.pb, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &changeSetPreview{Zone: zone, Errors: []string{}, Answers: []*rrsetAnswers{}}
+	after := before
+	err = inst.pb.RunInTransaction(func(txApp core.App) error {
+		if err := inst.applyStagedChanges(ctx, txApp, changeSet); err != nil {
+			preview.Errors = append(preview.Errors, err.Error())
+			return errPreviewRollback
+		}
+		if err := inst.checkZoneIntegrity(txApp, zone); err != nil {
+			preview.Errors = append(preview.Errors, err.Error())
+		}
+		var err error
+		if after, err = zoneSnapshotRecords(txApp, zone); err != nil {
+			return err
+		}
+		return errPreviewRollback
+	})
+	if err != nil && !errors.Is(err, errPreviewRollback) {
+		return nil, err
+	}
+
+	preview.Valid = len(preview.Errors) == 0
+	preview.Diff = diffZoneRecords(before, after)
+	preview.Diff.Zone, preview.Diff.From, preview.Diff.To = zone, snapshotLive, changeSet.Id
+	for _, set := range preview.Diff.Added {
+		preview.Answers = append(preview.Answers, inst.rrsetAnswers(set.Name, set.RecordType, nil, set.Records))
+	}
+	for _, set := range preview.Diff.Removed {
+		preview.Answers = append(preview.Answers, inst.rrsetAnswers(set.Name, set.RecordType, set.Records, nil))
+	}
+	for _, change := range preview.Diff.Changed {
+		preview.Answers = append(preview.Answers, inst.rrsetAnswers(change.Name, change.RecordType, change.Before, change.After))
+	}
+	return preview, nil
+}
+
+// applyStagedChanges saves and deletes the records of the staged changes of the change set in their creation order.
+// The zone integrity checks and the serial bumps of the single changes are skipped.
+func (inst *Instance) applyStagedChanges(ctx context.Context, app core.App, changeSet *core.Record) error {
+	zone := changeSet.GetString("zone")
+	changes, err := app.FindRecordsByFilter(stagedChangeCollectionName, "change_set = {:id}", "created,id", 0, 0,
+		dbx.Params{"id": changeSet.Id})
+	if err != nil {
+		return err
+	}
+	coll, err := app.FindCollectionByNameOrId(recordCollectionName)
+	if err != nil {
+		return err
+	}
+
+	ctx = withoutSerialBumps(WithoutIntegrityChecks(ctx))
+	for _, change := range changes {
+		if err = validateStagedChange(app, zone, change); err != nil {
+			return fmt.Errorf("staged change %s: %w", change.Id, err)
+		}
+		operation := change.GetString("operation")
+		var rec *core.Record
+		if operation == StagedCreate {
+			rec = core.NewRecord(coll)
+			rec.Set("zone", zone)
+		} else if rec, err = app.FindRecordById(recordCollectionName, change.GetString("record")); err != nil {
+			return fmt.Errorf("staged change %s: %w", change.Id, err)
+		}
+		if operation == StagedDelete {
+			err = app.DeleteWithContext(ctx, rec)
+		} else {
+			for _, field := range []string{"name", "record_type", "ttl", "content", "disabled", "comment", "owner"} {
+				rec.Set(field, change.Get(field))
+			}
+			err = app.SaveWithContext(ctx, rec)
+		}
+		if err != nil {
+			return fmt.Errorf("staged change %s: %w", change.Id, err)
+		}
+	}
+	return nil
+}
+
+// rrsetAnswers composes the answers served for the enabled records of a RRset before and after a change.
+func (inst *Instance) rrsetAnswers(name string, recordType string, before, after []map[string]any) *rrsetAnswers {
+	return &rrsetAnswers{
+		Name:       name,
+		RecordType: recordType,
+		Before:     inst.composeAnswers(before),
+		After:      inst.composeAnswers(after),
+	}
+}
+
+// composeAnswers composes the enabled records in presentation format, records failing to compose are skipped.
+func (inst *Instance) composeAnswers(recs []map[string]any) []string {
+	answers := make([]string, 0, len(recs))
+	for _, rec := range recs {
+		mRec, ok := snapshotModelRecord(rec)
+		if !ok {
+			continue
+		}
+		rr, _, err := inst.ComposeRecord(mRec)
+		if err != nil || rr == nil {
+			continue
+		}
+		answers = append(answers, rr.String())
+	}
+	return answers
+}
+
+// snapshotModelRecord converts a record snapshot into its model, it reports false for disabled records.
+func snapshotModelRecord(rec map[string]any) (*m.Record, bool) {
+	if disabled, _ := rec["disabled"].(bool); disabled {
+		return nil, false
+	}
+	content, err := json.Marshal(rec["content"])
+	if err != nil {
+		return nil, false
+	}
+	mRec := &m.Record{Content: string(content)}
+	mRec.Zone, _ = rec["zone"].(string)
+	mRec.Name, _ = rec["name"].(string)
+	mRec.RecordType, _ = rec["record_type"].(string)
+	if ttl, ok := rec["ttl"].(float64); ok {
+		mRec.Ttl = uint32(ttl)
+	}
+	return mRec, true
+}
@@ -0,0 +1,26 @@
+package pocketbase
+
+import (
+	"testing"
+
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/stretchr/testify/assert"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+func TestSnapshotModelRecord(t *testing.T) {
+	rec, ok := snapshotModelRecord(map[string]any{"zone": "example.com.", "name": "www.example.com.",
+		"record_type": "A", "ttl": float64(300), "content": types.JSONRaw(`{"ip":"192.0.2.1"}`), "disabled": false})
+	assert.True(t, ok)
+	assert.Equal(t, &m.Record{Zone: "example.com.", Name: "www.example.com.", RecordType: "A", Ttl: 300,
+		Content: `{"ip":"192.0.2.1"}`}, rec)
+
+	rec, ok = snapshotModelRecord(map[string]any{"zone": "example.com.", "name": "www.example.com.",
+		"record_type": "TXT", "ttl": float64(300), "content": map[string]any{"text": "hello"}, "disabled": false})
+	assert.True(t, ok)
+	assert.Equal(t, `{"text":"hello"}`, rec.Content)
+
+	_, ok = snapshotModelRecord(map[string]any{"zone": "example.com.", "name": "www.example.com.",
+		"record_type": "A", "content": types.JSONRaw(`{"ip":"192.0.2.1"}`), "disabled": true})
+	assert.False(t, ok)
+}
@@ -0,0 +1,294 @@
+package pocketbase
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/miekg/dns"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+	"github.com/pocketbase/pocketbase/tools/types"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+const (
+	zoneClaimCollectionName = "coredns_zone_claims"
+	// claimRecordLabel is the label prepended to a claimed zone to name the TXT record proving the claim.
+	claimRecordLabel = "_coredns-claim"
+	// claimTokenPrefix starts the values of the TXT records proving claims.
+	claimTokenPrefix = "coredns-claim="
+	// claimValidity is how long a claim can be verified after it is made.
+	claimValidity = 7 * 24 * time.Hour
+	// claimLookupTimeout limits the lookups of the TXT records proving claims.
+	claimLookupTimeout = 5 * time.Second
+)
+
+// Zone claim states.
+const (
+	ClaimPending  = "pending"
+	ClaimVerified = "verified"
+)
+
+// zoneClaimProof is the TXT record a claimant publishes to prove a claim.
+type zoneClaimProof struct {
+	Name       string `json:"name"`
+	RecordType string `json:"record_type"`
+	Text       string `json:"text"`
+}
+
+// bindZoneClaimRoutes registers the zone claim routes.
+func (inst *Instance) bindZoneClaimRoutes(g *router.RouterGroup[*core.RequestEvent]) {
+	g.POST("/claims", inst.handleCreateZoneClaim).Bind(apis.RequireAuth("users"))
+	g.POST("/claims/{id}/verify", inst.handleVerifyZoneClaim).Bind(apis.RequireAuth())
+}
+
+// handleCreateZoneClaim starts a claim of a zone for the tenant of the requesting user,
+// answering with the TXT record to publish at the parent zone to prove it.
+func (inst *Instance) handleCreateZoneClaim(e *core.RequestEvent) error {
+	if _, ok := requestApiToken(e); ok {
+		return e.ForbiddenError("API tokens can not claim zones.", nil)
+	}
+	tenant := e.Auth.GetString("tenant")
+	if tenant == "" {
+		return e.ForbiddenError("Only users of a tenant can claim zones.", nil)
+	}
+	body := struct {
+		Zone string `json:"zone"`
+	}{}
+	if err := e.BindBody(&body); err != nil {
+		return e.BadRequestError("Failed to decode zone claim.", err)
+	}
+	zone := strings.ToLower(dns.Fqdn(body.Zone))
+	if err := m.ValidateZoneName(zone); err != nil {
+		return e.BadRequestError("Invalid zone claim, "+err.Error()+".", toValidationErrors(err))
+	}
+	if dns.CountLabel(zone) < 2 {
+		return e.BadRequestError("Top level domains can not be claimed.", nil)
+	}
+	if err := checkZoneClaimable(inst.pb, zone, tenant); err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	claim, err := inst.pb.FindFirstRecordByFilter(zoneClaimCollectionName,
+		"zone = {:zone} && tenant = {:tenant} && user = {:user} && state = {:state}",
+		dbx.Params{"zone": zone, "tenant": tenant, "user": e.Auth.Id, "state": ClaimPending})
+	if errors.Is(err, sql.ErrNoRows) {
+		claim, err = newZoneClaim(inst.pb, zone, tenant, e.Auth.Id)
+	}
+	if err != nil {
+		return e.InternalServerError("Failed to save zone claim.", err)
+	}
+	log.Infof("Zone claim made, zone: %s, tenant: %s, user: %s", zone, tenant, e.Auth.Id)
+	return e.JSON(http.StatusOK, map[string]any{
+		"claim": claim,
+		"proof": zoneClaimProof{Name: claimRecordName(zone), RecordType: "TXT", Text: claim.GetString("token")},
+	})
+}
+
+// handleVerifyZoneClaim looks up the proof of a claim and binds the zone to the tenant of the claim once proven,
+// creating the zone if it does not exist yet. The claimant becomes an owner of the zone.
+func (inst *Instance) handleVerifyZoneClaim(e *core.RequestEvent) error {
+	if _, ok := requestApiToken(e); ok {
+		return e.ForbiddenError("API tokens can not claim zones.", nil)
+	}
+	claim, err := inst.pb.FindRecordById(zoneClaimCollectionName, e.Request.PathValue("id"))
+	if err != nil || !e.HasSuperuserAuth() && claim.GetString("user") != e.Auth.Id {
+		return e.NotFoundError("Zone claim not found.", err)
+	}
+	if claim.GetString("state") != ClaimPending {
+		return e.BadRequestError("The zone claim is already verified.", nil)
+	}
+	if time.Since(claim.GetDateTime("created").Time()) > claimValidity {
+		return e.BadRequestError("The zone claim expired, make a new claim.", nil)
+	}
+	zone := claim.GetString("zone")
+
+	ctx, cancel := context.WithTimeout(e.Request.Context(), claimLookupTimeout)
+	defer cancel()
+	texts, source, err := inst.lookupClaimProof(ctx, zone)
+	checked, _ := types.ParseDateTime(time.Now())
+	claim.Set("checked", checked)
+	if err == nil && !slices.Contains(texts, claim.GetString("token")) {
+		err = fmt.Errorf("TXT record %s at %s does not hold %s", claimRecordName(zone), source, claim.GetString("token"))
+	}
+	if err != nil {
+		claim.Set("error", err.Error())
+		if saveErr := inst.pb.Save(claim); saveErr != nil {
+			log.Errorf("Failed to save zone claim, id: %s, err: %+v", claim.Id, saveErr)
+		}
+		return e.BadRequestError("The zone claim is not proven, "+err.Error()+".", nil)
+	}
+
+	var zoneRec *core.Record
+	err = inst.pb.RunInTransaction(func(txApp core.App) error {
+		if err := checkZoneClaimable(txApp, zone, claim.GetString("tenant")); err != nil {
+			return err
+		}
+		rec, err := bindZoneToTenant(txApp, zone, claim.GetString("tenant"), claim.GetString("user"))
+		if err != nil {
+			return err
+		}
+		zoneRec = rec
+		claim.Set("state", ClaimVerified)
+		claim.Set("verified", checked)
+		claim.Set("error", "")
+		return txApp.Save(claim)
+	})
+	if err != nil {
+		return e.BadRequestError("Failed to bind the claimed zone, "+err.Error()+".", nil)
+	}
+	log.Infof("Zone claim verified, zone: %s, tenant: %s, proof: %s", zone, claim.GetString("tenant"), source)
+	return e.JSON(http.StatusOK, zoneRec)
+}
+
+// newZoneClaim saves a pending claim of the zone with a random token.
+func newZoneClaim(app core.App, zone string, tenant string, user string) (*core.Record, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	coll, err := app.FindCollectionByNameOrId(zoneClaimCollectionName)
+	if err != nil {
+		return nil, err
+	}
+	claim := core.NewRecord(coll)
+	claim.Set("zone", zone)
+	claim.Set("tenant", tenant)
+	claim.Set("user", user)
+	claim.Set("token", claimTokenPrefix+hex.EncodeToString(b))
+	claim.Set("state", ClaimPending)
+	return claim, app.Save(claim)
+}
+
+// checkZoneClaimable returns an error if the zone already belongs to a tenant.
+// Zones without tenant, managed by superusers, can be claimed.
+func checkZoneClaimable(app core.App, zone string, tenant string) error {
+	existing, err := app.FindFirstRecordByData(zoneCollectionName, "name", zone)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	switch existing.GetString("tenant") {
+	case "":
+		return nil
+	case tenant:
+		return fmt.Errorf("zone %s already belongs to the tenant", zone)
+	default:
+		return fmt.Errorf("zone %s belongs to another tenant", zone)
+	}
+}
+
+// bindZoneToTenant makes the zone belong to the tenant, creating it if it does not exist yet,
+// and adds the user as an owner of the zone.
+func bindZoneToTenant(app core.App, zone string, tenant string, user string) (*core.Record, error) {
+	rec, err := app.FindFirstRecordByData(zoneCollectionName, "name", zone)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		coll, err := app.FindCollectionByNameOrId(zoneCollectionName)
+		if err != nil {
+			return nil, err
+		}
+		rec = core.NewRecord(coll)
+		rec.Set("name", zone)
+		rec.Set("state", m.ZoneStateActive)
+	case err != nil:
+		return nil, err
+	default:
+		// the quota of new zones is checked when they are created
+		if err = checkZoneQuota(app, tenant); err != nil {
+			return nil, err
+		}
+	}
+	rec.Set("tenant", tenant)
+	if err = app.Save(rec); err != nil {
+		return nil, err
+	}
+	log.Infof("Adding zone owner, zone: %s, user: %s", zone, user)
+	return rec, addZoneMember(app, rec.Id, user, ZoneRoleOwner)
+}
+
+// claimRecordName returns the name of the TXT record proving a claim of the zone.
+func claimRecordName(zone string) string {
+	return claimRecordLabel + "." + zone
+}
+
+// lookupClaimProof returns the texts of the TXT records proving claims of the zone, and where they were found.
+// The records are looked up in the nearest parent zone served by the instance, or upstream if there is none.
+func (inst *Instance) lookupClaimProof(ctx context.Context, zone string) (texts []string, source string, err error) {
+	name := claimRecordName(zone)
+	if parent, err := localParentZone(inst.pb, zone); err != nil {
+		return nil, "", err
+	} else if parent != "" {
+		texts, err = localTxtTexts(inst.pb, parent, name)
+		return texts, "zone " + parent, err
+	}
+
+	if inst.claimResolver == "" {
+		texts, err = net.DefaultResolver.LookupTXT(ctx, name)
+		return texts, "system resolver", err
+	}
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, dns.TypeTXT)
+	resp, _, err := new(dns.Client).ExchangeContext(ctx, msg, inst.claimResolver)
+	if err != nil {
+		return nil, inst.claimResolver, err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return nil, inst.claimResolver, fmt.Errorf("lookup of %s failed with %s", name, dns.RcodeToString[resp.Rcode])
+	}
+	for _, rr := range resp.Answer {
+		if txt, ok := rr.(*dns.TXT); ok {
+			texts = append(texts, strings.Join(txt.Txt, ""))
+		}
+	}
+	return texts, inst.claimResolver, nil
+}
+
+// localParentZone returns the nearest parent zone of the zone served by the instance, or an empty string if none.
+func localParentZone(app core.App, zone string) (string, error) {
+	labels := dns.SplitDomainName(zone)
+	for i := 1; i < len(labels); i++ {
+		parent := dns.Fqdn(strings.Join(labels[i:], "."))
+		_, err := app.FindFirstRecordByData(zoneCollectionName, "name", parent)
+		if err == nil {
+			return parent, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// localTxtTexts returns the texts of the enabled TXT records with the name in the zone.
+func localTxtTexts(app core.App, zone string, name string) ([]string, error) {
+	records, err := app.FindRecordsByFilter(recordCollectionName,
+		"zone = {:zone} && name = {:name} && record_type = 'TXT' && disabled = false", "", 0, 0,
+		dbx.Params{"zone": zone, "name": name})
+	if err != nil {
+		return nil, err
+	}
+	texts := make([]string, 0, len(records))
+	for _, rec := range records {
+		var txt m.TXTRecord
+		if err = json.Unmarshal([]byte(rec.GetString("content")), &txt); err == nil {
+			texts = append(texts, txt.Text)
+		}
+	}
+	return texts, nil
+}
@@ -0,0 +1,176 @@
+package pocketbase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+// cliActor is the actor of the changes made by the commands.
+var cliActor = Actor{Type: ActorSystem, Name: "cli"}
+
+// bindCommands registers the DNS management commands on the root command of the embedded PocketBase.
+func (inst *Instance) bindCommands() {
+	inst.pb.RootCmd.AddCommand(inst.newImportZoneCommand())
+	inst.pb.RootCmd.AddCommand(inst.newExportZoneCommand())
+	inst.pb.RootCmd.AddCommand(inst.newImportLegacyCommand())
+}
+
+// RunCommand runs a command of the embedded PocketBase, e.g. import-zone or export-zone, instead of serving.
+// It returns once the command is done.
+func (inst *Instance) RunCommand(args ...string) error {
+	inst.bindEvents()
+	if err := inst.pb.Bootstrap(); err != nil {
+		log.Error("Failed to bootstrap pocketbase", err)
+		return err
+	}
+	defer func() {
+		_ = inst.pb.ResetBootstrapState()
+	}()
+	// serving runs the migrations, the commands may run against a new data directory
+	if err := inst.pb.RunAllMigrations(); err != nil {
+		log.Error("Failed to run pocketbase migrations", err)
+		return err
+	}
+	inst.pb.RootCmd.SetArgs(args)
+	return inst.pb.RootCmd.Execute()
+}
+
+// newImportZoneCommand returns the command importing a zone file, printing the import report.
+func (inst *Instance) newImportZoneCommand() *cobra.Command {
+	var zone string
+	var format string
+	var opts zoneImportOptions
+	command := &cobra.Command{
+		Use:          "import-zone [flags] <zone file>",
+		Short:        "Imports the records of a zone file in master file format, or in octoDNS YAML format, into a zone",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			zone = strings.ToLower(dns.Fqdn(zone))
+			if err := m.ValidateZoneName(zone); err != nil {
+				return err
+			}
+			file, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			ctx := WithActor(context.Background(), cliActor)
+			opts.CreateZone = true
+			var report *zoneImportReport
+			switch format {
+			case zoneFileFormat:
+				var rrs []dns.RR
+				if rrs, err = parseZoneFile(file, zone, args[0], true); err != nil {
+					return err
+				}
+				report, err = inst.importZoneFile(ctx, zone, rrs, opts)
+			case octodnsFormat:
+				var oz *octodnsZone
+				if oz, err = parseOctodnsYaml(file, zone); err != nil {
+					return err
+				}
+				report, err = inst.importOctodnsZone(ctx, zone, oz, opts)
+			default:
+				return fmt.Errorf("unknown zone format %q, expected %s or %s", format, zoneFileFormat, octodnsFormat)
+			}
+			if report != nil && (err == nil || errors.Is(err, errZoneImportFailed)) {
+				out, _ := json.MarshalIndent(report, "", "  ")
+				fmt.Fprintln(command.OutOrStdout(), string(out))
+			}
+			return err
+		},
+	}
+	command.Flags().StringVar(&zone, "zone", "", "the zone to import into, the origin of relative names (required)")
+	command.Flags().StringVar(&format, "format", zoneFileFormat, fmt.Sprintf("the format of the zone file, %s or %s", zoneFileFormat, octodnsFormat))
+	command.Flags().BoolVar(&opts.DryRun, "dry-run", false, "only report what the import would change")
+	command.Flags().BoolVar(&opts.Overwrite, "overwrite", false, "replace the existing RRsets differing from the zone file")
+	_ = command.MarkFlagRequired("zone")
+	return command
+}
+
+// newExportZoneCommand returns the command exporting a zone file, to the standard output or to a file.
+func (inst *Instance) newExportZoneCommand() *cobra.Command {
+	var zone string
+	var format string
+	var output string
+	command := &cobra.Command{
+		Use:          "export-zone [flags]",
+		Short:        "Exports the enabled records of a zone as a zone file in master file format, or in octoDNS YAML format",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			zone = strings.ToLower(dns.Fqdn(zone))
+			var data []byte
+			switch format {
+			case zoneFileFormat:
+				zoneFile, err := inst.exportZoneFile(inst.pb, zone)
+				if err != nil {
+					return err
+				}
+				data = []byte(zoneFile)
+			case octodnsFormat:
+				var err error
+				if data, err = inst.exportOctodnsYaml(inst.pb, zone); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown zone format %q, expected %s or %s", format, zoneFileFormat, octodnsFormat)
+			}
+			if output == "" || output == "-" {
+				_, err := command.OutOrStdout().Write(data)
+				return err
+			}
+			return os.WriteFile(output, data, 0o644)
+		},
+	}
+	command.Flags().StringVar(&zone, "zone", "", "the zone to export (required)")
+	command.Flags().StringVar(&format, "format", zoneFileFormat, fmt.Sprintf("the format of the zone file, %s or %s", zoneFileFormat, octodnsFormat))
+	command.Flags().StringVarP(&output, "output", "o", "", "the file to write the zone file to, the standard output by default")
+	_ = command.MarkFlagRequired("zone")
+	return command
+}
+
+// newImportLegacyCommand returns the command importing the zones of a legacy backend, printing the mapping report.
+func (inst *Instance) newImportLegacyCommand() *cobra.Command {
+	var format string
+	var opts zoneImportOptions
+	command := &cobra.Command{
+		Use:          "import-legacy [flags] <source>",
+		Short:        "Imports the zones of a PowerDNS SQLite database, a coredns_mysql dump or an arvancloud/redis JSON dump",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			file, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			lz, err := readLegacyZones(format, args[0], file)
+			if err != nil {
+				return err
+			}
+			opts.CreateZone = true
+			report, err := inst.importLegacyZones(WithActor(context.Background(), cliActor), format, lz, opts)
+			if report != nil {
+				out, _ := json.MarshalIndent(report, "", "  ")
+				fmt.Fprintln(command.OutOrStdout(), string(out))
+			}
+			return err
+		},
+	}
+	command.Flags().StringVar(&format, "format", "", fmt.Sprintf("the format of the source, one of %v (required)", LegacyFormats))
+	command.Flags().BoolVar(&opts.DryRun, "dry-run", false, "only report what the import would change")
+	command.Flags().BoolVar(&opts.Overwrite, "overwrite", false, "replace the existing RRsets differing from the source")
+	_ = command.MarkFlagRequired("format")
+	return command
+}
@@ -2,6 +2,7 @@ package pocketbase
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/coredns/coredns/plugin/pkg/log"
@@ -11,8 +12,10 @@ import (
 
 // Composer is responsible for composing DNS records from PocketBase data.
 // It provides methods to convert PocketBase record data into DNS resource records.
+// The settings of the zones are fetched for each record, unless the composer is bound to a query, see NewQueryComposer.
 type Composer struct {
-	inst *Instance
+	inst  *Instance
+	zones map[string]*m.Zone
 }
 
 // NewComposer creates a new Composer instance with the given PocketBase instance.
@@ -22,15 +25,116 @@ func NewComposer(inst *Instance) *Composer {
 	}
 }
 
+// NewQueryComposer creates a Composer for the records of a single query, the settings of the queried zone are the
+// given ones and the settings of the other zones are fetched once. It is not safe for concurrent use.
+func NewQueryComposer(inst *Instance, zone string, settings *m.Zone) *Composer {
+	return &Composer{
+		inst:  inst,
+		zones: map[string]*m.Zone{zone: settings},
+	}
+}
+
+// zoneSettings returns the settings of the zone, nil if the zone does not exist.
+func (c *Composer) zoneSettings(zone string) (*m.Zone, error) {
+	if settings, ok := c.zones[zone]; ok {
+		return settings, nil
+	}
+	settings, err := c.inst.FetchZone(zone)
+	if err != nil {
+		return nil, err
+	}
+	if c.zones != nil {
+		c.zones[zone] = settings
+	}
+	return settings, nil
+}
+
+// ComposeRecord creates a DNS resource record of any supported type from a PocketBase record, see Composer.
+func (inst *Instance) ComposeRecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+	return inst.composer.ComposeRecord(rec)
+}
+
+// ComposeARecord creates a DNS A record from a PocketBase record, see Composer.
+func (inst *Instance) ComposeARecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+	return inst.composer.ComposeARecord(rec)
+}
+
+// ComposeAAAARecord creates a DNS AAAA record from a PocketBase record, see Composer.
+func (inst *Instance) ComposeAAAARecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+	return inst.composer.ComposeAAAARecord(rec)
+}
+
+// ComposeTXTRecord creates a DNS TXT record from a PocketBase record, see Composer.
+func (inst *Instance) ComposeTXTRecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+	return inst.composer.ComposeTXTRecord(rec)
+}
+
+// ComposeCNAMERecord creates a DNS CNAME record from a PocketBase record, see Composer.
+func (inst *Instance) ComposeCNAMERecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+	return inst.composer.ComposeCNAMERecord(rec)
+}
+
+// ComposeNSRecord creates a DNS NS record from a PocketBase record, see Composer.
+func (inst *Instance) ComposeNSRecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+	return inst.composer.ComposeNSRecord(rec)
+}
+
+// ComposeMXRecord creates a DNS MX record from a PocketBase record, see Composer.
+func (inst *Instance) ComposeMXRecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+	return inst.composer.ComposeMXRecord(rec)
+}
+
+// ComposeSRVRecord creates a DNS SRV record from a PocketBase record, see Composer.
+func (inst *Instance) ComposeSRVRecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+	return inst.composer.ComposeSRVRecord(rec)
+}
+
+// ComposeSOARecord creates a DNS SOA record from a PocketBase record, see Composer.
+func (inst *Instance) ComposeSOARecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+	return inst.composer.ComposeSOARecord(rec)
+}
+
+// ComposeCAARecord creates a DNS CAA record from a PocketBase record, see Composer.
+func (inst *Instance) ComposeCAARecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+	return inst.composer.ComposeCAARecord(rec)
+}
+
+// ComposeRecord creates a DNS resource record of any supported type from a PocketBase record.
+// It returns the composed record and any additional records needed.
+func (c *Composer) ComposeRecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+	switch rec.RecordType {
+	case "A":
+		return c.ComposeARecord(rec)
+	case "AAAA":
+		return c.ComposeAAAARecord(rec)
+	case "CNAME":
+		return c.ComposeCNAMERecord(rec)
+	case "SOA":
+		return c.ComposeSOARecord(rec)
+	case "SRV":
+		return c.ComposeSRVRecord(rec)
+	case "NS":
+		return c.ComposeNSRecord(rec)
+	case "MX":
+		return c.ComposeMXRecord(rec)
+	case "TXT":
+		return c.ComposeTXTRecord(rec)
+	case "CAA":
+		return c.ComposeCAARecord(rec)
+	default:
+		return nil, nil, fmt.Errorf("unsupported record type: %s", rec.RecordType)
+	}
+}
+
 // ComposeARecord creates a DNS A record from a PocketBase record.
 // It returns the composed A record and any additional records needed.
-func (inst *Instance) ComposeARecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+func (c *Composer) ComposeARecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
 	r := new(dns.A)
 	r.Hdr = dns.RR_Header{
 		Name:   rec.Name,
 		Rrtype: dns.TypeA,
 		Class:  dns.ClassINET,
-		Ttl:    inst.tryRefillTtl(rec),
+		Ttl:    c.tryRefillTtl(rec),
 	}
 	var retRec *m.ARecord
 	err = json.Unmarshal([]byte(rec.Content), &retRec)
@@ -50,13 +154,13 @@ func (inst *Instance) ComposeARecord(rec *m.Record) (record dns.RR, extras []dns
 
 // ComposeAAAARecord creates a DNS AAAA record from a PocketBase record.
 // It returns the composed AAAA record and any additional records needed.
-func (inst *Instance) ComposeAAAARecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+func (c *Composer) ComposeAAAARecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
 	r := new(dns.AAAA)
 	r.Hdr = dns.RR_Header{
 		Name:   rec.Name,
 		Rrtype: dns.TypeAAAA,
 		Class:  dns.ClassINET,
-		Ttl:    inst.tryRefillTtl(rec),
+		Ttl:    c.tryRefillTtl(rec),
 	}
 	var retRec *m.AAAARecord
 	err = json.Unmarshal([]byte(rec.Content), &retRec)
@@ -77,13 +181,13 @@ func (inst *Instance) ComposeAAAARecord(rec *m.Record) (record dns.RR, extras []
 
 // ComposeTXTRecord creates a DNS TXT record from a PocketBase record.
 // It returns the composed TXT record and any additional records needed.
-func (inst *Instance) ComposeTXTRecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+func (c *Composer) ComposeTXTRecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
 	r := new(dns.TXT)
 	r.Hdr = dns.RR_Header{
 		Name:   rec.Name,
 		Rrtype: dns.TypeTXT,
 		Class:  dns.ClassINET,
-		Ttl:    inst.tryRefillTtl(rec),
+		Ttl:    c.tryRefillTtl(rec),
 	}
 	var retRec *m.TXTRecord
 	err = json.Unmarshal([]byte(rec.Content), &retRec)
@@ -104,13 +208,13 @@ func (inst *Instance) ComposeTXTRecord(rec *m.Record) (record dns.RR, extras []d
 
 // ComposeCNAMERecord creates a DNS CNAME record from a PocketBase record.
 // It returns the composed CNAME record and any additional records needed.
-func (inst *Instance) ComposeCNAMERecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+func (c *Composer) ComposeCNAMERecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
 	r := new(dns.CNAME)
 	r.Hdr = dns.RR_Header{
 		Name:   rec.Name,
 		Rrtype: dns.TypeCNAME,
 		Class:  dns.ClassINET,
-		Ttl:    inst.tryRefillTtl(rec),
+		Ttl:    c.tryRefillTtl(rec),
 	}
 	var retRec *m.CNAMERecord
 	err = json.Unmarshal([]byte(rec.Content), &retRec)
@@ -130,13 +234,13 @@ func (inst *Instance) ComposeCNAMERecord(rec *m.Record) (record dns.RR, extras [
 
 // ComposeNSRecord creates a DNS NS record from a PocketBase record.
 // It returns the composed NS record and any additional records needed.
-func (inst *Instance) ComposeNSRecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+func (c *Composer) ComposeNSRecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
 	r := new(dns.NS)
 	r.Hdr = dns.RR_Header{
 		Name:   rec.Name,
 		Rrtype: dns.TypeNS,
 		Class:  dns.ClassINET,
-		Ttl:    inst.tryRefillTtl(rec),
+		Ttl:    c.tryRefillTtl(rec),
 	}
 	var retRec *m.NSRecord
 	err = json.Unmarshal([]byte(rec.Content), &retRec)
@@ -151,7 +255,7 @@ func (inst *Instance) ComposeNSRecord(rec *m.Record) (record dns.RR, extras []dn
 	}
 
 	r.Ns = retRec.Host
-	extras, err = inst.Hosts(rec.Zone, r.Ns)
+	extras, err = c.Hosts(rec.Zone, r.Ns)
 	if err != nil {
 		log.Errorf("Failed to compose NS record, zone: %s, name: %s, err: %+v", rec.Zone, rec.Name, err)
 		return nil, nil, err
@@ -162,13 +266,13 @@ func (inst *Instance) ComposeNSRecord(rec *m.Record) (record dns.RR, extras []dn
 
 // ComposeMXRecord creates a DNS MX record from a PocketBase record.
 // It returns the composed MX record and any additional records needed.
-func (inst *Instance) ComposeMXRecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+func (c *Composer) ComposeMXRecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
 	r := new(dns.MX)
 	r.Hdr = dns.RR_Header{
 		Name:   rec.Name,
 		Rrtype: dns.TypeMX,
 		Class:  dns.ClassINET,
-		Ttl:    inst.tryRefillTtl(rec),
+		Ttl:    c.tryRefillTtl(rec),
 	}
 	var retRec *m.MXRecord
 	err = json.Unmarshal([]byte(rec.Content), &retRec)
@@ -184,7 +288,7 @@ func (inst *Instance) ComposeMXRecord(rec *m.Record) (record dns.RR, extras []dn
 
 	r.Mx = retRec.Host
 	r.Preference = retRec.Preference
-	extras, err = inst.Hosts(rec.Zone, retRec.Host)
+	extras, err = c.Hosts(rec.Zone, retRec.Host)
 	if err != nil {
 		log.Errorf("Failed to compose MX record, zone: %s, name: %s, err: %+v", rec.Zone, rec.Name, err)
 		return nil, nil, err
@@ -195,13 +299,13 @@ func (inst *Instance) ComposeMXRecord(rec *m.Record) (record dns.RR, extras []dn
 
 // ComposeSRVRecord creates a DNS SRV record from a PocketBase record.
 // It returns the composed SRV record and any additional records needed.
-func (inst *Instance) ComposeSRVRecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+func (c *Composer) ComposeSRVRecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
 	r := new(dns.SRV)
 	r.Hdr = dns.RR_Header{
 		Name:   rec.Name,
 		Rrtype: dns.TypeSRV,
 		Class:  dns.ClassINET,
-		Ttl:    inst.tryRefillTtl(rec),
+		Ttl:    c.tryRefillTtl(rec),
 	}
 	var retRec *m.SRVRecord
 	err = json.Unmarshal([]byte(rec.Content), &retRec)
@@ -225,7 +329,7 @@ func (inst *Instance) ComposeSRVRecord(rec *m.Record) (record dns.RR, extras []d
 
 // ComposeSOARecord creates a DNS SOA record from a PocketBase record.
 // It returns the composed SOA record and any additional records needed.
-func (inst *Instance) ComposeSOARecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+func (c *Composer) ComposeSOARecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
 	r := new(dns.SOA)
 	var retRec *m.SOARecord
 	err = json.Unmarshal([]byte(rec.Content), &retRec)
@@ -233,27 +337,34 @@ func (inst *Instance) ComposeSOARecord(rec *m.Record) (record dns.RR, extras []d
 		log.Errorf("Failed to unmarshal SOA record, zone: %s, name: %s, err: %+v", rec.Zone, rec.Name, err)
 		return nil, nil, err
 	}
+	zone, err := c.zoneSettings(rec.Zone)
+	if err != nil {
+		log.Errorf("Failed to fetch zone of SOA record, zone: %s, name: %s, err: %+v", rec.Zone, rec.Name, err)
+		return nil, nil, err
+	}
 
 	if retRec.Ns == "" {
 		r.Hdr = dns.RR_Header{
 			Name:   dns.Fqdn(rec.Name),
 			Rrtype: dns.TypeSOA,
 			Class:  dns.ClassINET,
-			Ttl:    inst.tryRefillTtl(rec),
+			Ttl:    c.tryRefillTtl(rec),
 		}
-		// TODO: get from config
 		r.Ns = "ns1." + rec.Name
 		r.Mbox = "hostmaster." + rec.Name
 		r.Refresh = 86400
 		r.Retry = 7200
 		r.Expire = 3600
-		r.Minttl = inst.tryRefillTtl(rec)
+		r.Minttl = c.tryRefillTtl(rec)
+		if zone != nil {
+			applyZoneSoaSettings(r, zone)
+		}
 	} else {
 		r.Hdr = dns.RR_Header{
 			Name:   dns.Fqdn(rec.Zone),
 			Rrtype: dns.TypeSOA,
 			Class:  dns.ClassINET,
-			Ttl:    inst.tryRefillTtl(rec),
+			Ttl:    c.tryRefillTtl(rec),
 		}
 		r.Ns = retRec.Ns
 		r.Mbox = dns.Fqdn(retRec.MBox)
@@ -263,19 +374,44 @@ func (inst *Instance) ComposeSOARecord(rec *m.Record) (record dns.RR, extras []d
 		r.Minttl = retRec.MinTtl
 	}
 	r.Serial = serial()
+	if zone != nil && zone.Serial != 0 {
+		r.Serial = zone.Serial
+	}
 	log.Debugf("Composed SOA record, zone: %s, name: %s, serial: %d", rec.Zone, rec.Name, r.Serial)
 	return r, nil, nil
 }
 
+// applyZoneSoaSettings overrides the generated SOA fields with the ones set in the zone settings.
+func applyZoneSoaSettings(r *dns.SOA, zone *m.Zone) {
+	if zone.SoaNs != "" {
+		r.Ns = dns.Fqdn(zone.SoaNs)
+	}
+	if zone.SoaMBox != "" {
+		r.Mbox = dns.Fqdn(zone.SoaMBox)
+	}
+	if zone.SoaRefresh != 0 {
+		r.Refresh = zone.SoaRefresh
+	}
+	if zone.SoaRetry != 0 {
+		r.Retry = zone.SoaRetry
+	}
+	if zone.SoaExpire != 0 {
+		r.Expire = zone.SoaExpire
+	}
+	if zone.SoaMinTtl != 0 {
+		r.Minttl = zone.SoaMinTtl
+	}
+}
+
 // ComposeCAARecord creates a DNS CAA record from a PocketBase record.
 // It returns the composed CAA record and any additional records needed.
-func (inst *Instance) ComposeCAARecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
+func (c *Composer) ComposeCAARecord(rec *m.Record) (record dns.RR, extras []dns.RR, err error) {
 	r := new(dns.CAA)
 	r.Hdr = dns.RR_Header{
 		Name:   rec.Name,
 		Rrtype: dns.TypeCAA,
 		Class:  dns.ClassINET,
-		Ttl:    inst.tryRefillTtl(rec),
+		Ttl:    c.tryRefillTtl(rec),
 	}
 	var retRec *m.CAARecord
 	err = json.Unmarshal([]byte(rec.Content), &retRec)
@@ -297,14 +433,42 @@ func (inst *Instance) ComposeCAARecord(rec *m.Record) (record dns.RR, extras []d
 }
 
 // tryRefillTtl returns the TTL value for a record.
-// If the record's TTL is not set (0), it returns the default TTL from the instance configuration.
-func (inst *Instance) tryRefillTtl(rec *m.Record) uint32 {
-	if rec.Ttl == 0 {
-		log.Debugf("Record TTL is 0, zone: %s, name: %s, using default TTL: %d", rec.Zone, rec.Name, inst.defaultTtl)
-		return uint32(inst.defaultTtl)
-	}
-	log.Debugf("Record TTL is not 0, zone: %s, name: %s, using TTL: %d", rec.Zone, rec.Name, rec.Ttl)
-	return rec.Ttl
+// If the record's TTL is not set (0), the default TTL of its zone or of the instance configuration is used.
+// The TTL is then clamped to the TTL limits of the zone and of the instance configuration.
+func (c *Composer) tryRefillTtl(rec *m.Record) uint32 {
+	zone, err := c.zoneSettings(rec.Zone)
+	if err != nil {
+		log.Warningf("Failed to fetch zone settings, using global TTL settings, zone: %s, err: %+v", rec.Zone, err)
+	}
+	ttl := refillTtl(rec.Ttl, zone, uint32(c.inst.defaultTtl), uint32(c.inst.minTtl), uint32(c.inst.maxTtl))
+	log.Debugf("Refilled record TTL, zone: %s, name: %s, TTL: %d, using TTL: %d", rec.Zone, rec.Name, rec.Ttl, ttl)
+	return ttl
+}
+
+// refillTtl applies the default TTL to an unset TTL and clamps it to the minimum and maximum TTL, 0 disables a limit.
+// The default TTL of the zone takes precedence over the global one, the limits of the zone can only narrow the global limits.
+func refillTtl(ttl uint32, zone *m.Zone, defaultTtl uint32, minTtl uint32, maxTtl uint32) uint32 {
+	if zone != nil {
+		if zone.DefaultTtl > 0 {
+			defaultTtl = zone.DefaultTtl
+		}
+		if zone.MinTtl > minTtl {
+			minTtl = zone.MinTtl
+		}
+		if zone.MaxTtl > 0 && (maxTtl == 0 || zone.MaxTtl < maxTtl) {
+			maxTtl = zone.MaxTtl
+		}
+	}
+	if ttl == 0 {
+		ttl = defaultTtl
+	}
+	if ttl < minTtl {
+		ttl = minTtl
+	}
+	if maxTtl > 0 && ttl > maxTtl {
+		ttl = maxTtl
+	}
+	return ttl
 }
 
 // serial generates a serial number for SOA records based on the current Unix timestamp.
@@ -0,0 +1,58 @@
+package pocketbase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+func TestRefillTtl(t *testing.T) {
+	tests := []struct {
+		name       string
+		ttl        uint32
+		zone       *m.Zone
+		defaultTtl uint32
+		minTtl     uint32
+		maxTtl     uint32
+		expected   uint32
+	}{
+		{name: "record ttl", ttl: 300, defaultTtl: 30, expected: 300},
+		{name: "global default", ttl: 0, defaultTtl: 30, expected: 30},
+		{name: "zone default", ttl: 0, zone: &m.Zone{DefaultTtl: 600}, defaultTtl: 30, expected: 600},
+		{name: "zone without settings", ttl: 0, zone: &m.Zone{}, defaultTtl: 30, expected: 30},
+		{name: "global minimum", ttl: 1, defaultTtl: 30, minTtl: 60, expected: 60},
+		{name: "global maximum", ttl: 604800, defaultTtl: 30, maxTtl: 86400, expected: 86400},
+		{name: "default clamped", ttl: 0, defaultTtl: 30, minTtl: 60, expected: 60},
+		{name: "zone minimum", ttl: 10, zone: &m.Zone{MinTtl: 120}, defaultTtl: 30, minTtl: 60, expected: 120},
+		{name: "zone minimum below global", ttl: 10, zone: &m.Zone{MinTtl: 5}, defaultTtl: 30, minTtl: 60, expected: 60},
+		{name: "zone maximum", ttl: 7200, zone: &m.Zone{MaxTtl: 3600}, defaultTtl: 30, maxTtl: 86400, expected: 3600},
+		{name: "zone maximum above global", ttl: 604800, zone: &m.Zone{MaxTtl: 604800}, defaultTtl: 30, maxTtl: 86400,
+			expected: 86400},
+		{name: "zone maximum without global", ttl: 7200, zone: &m.Zone{MaxTtl: 3600}, defaultTtl: 30, expected: 3600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, refillTtl(tt.ttl, tt.zone, tt.defaultTtl, tt.minTtl, tt.maxTtl))
+		})
+	}
+}
+
+func TestQueryComposerZoneSettings(t *testing.T) {
+	// the instance has no PocketBase app: the records of the queried zone must be composed without fetching it
+	inst := &Instance{defaultTtl: 30}
+	c := NewQueryComposer(inst, "example.com.", &m.Zone{Name: "example.com.", DefaultTtl: 600, Serial: 2024010101})
+
+	rr, _, err := c.ComposeARecord(&m.Record{Zone: "example.com.", Name: "www.example.com.", RecordType: "A",
+		Content: `{"ip":"192.0.2.1"}`})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(600), rr.Header().Ttl)
+
+	rr, _, err = c.ComposeSOARecord(&m.Record{Zone: "example.com.", Name: "example.com.", RecordType: "SOA",
+		Content: "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, "example.com.\t600\tIN\tSOA\tns1.example.com. hostmaster.example.com. 2024010101 86400 7200 3600 600",
+		rr.String())
+}
@@ -0,0 +1,218 @@
+package pocketbase
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/miekg/dns"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	ddnsHostCollectionName = "coredns_ddns_hosts"
+	// DynDnsUpdatePath is the path of the dyndns2 compatible update endpoint.
+	DynDnsUpdatePath = "/nic/update"
+	// ddnsMaxHosts is the maximum number of hostnames accepted in a single update.
+	ddnsMaxHosts = 20
+)
+
+// dyndns2 return codes, see https://help.dyn.com/remote-access-api/return-codes/
+const (
+	ddnsGood    = "good"
+	ddnsNoChg   = "nochg"
+	ddnsBadAuth = "badauth"
+	ddnsNotFqdn = "notfqdn"
+	ddnsNumHost = "numhost"
+	ddnsDnsErr  = "dnserr"
+)
+
+// bindDynDnsRoutes registers the dyndns2 compatible update endpoint.
+func (inst *Instance) bindDynDnsRoutes(se *core.ServeEvent) {
+	log.Infof("Bind dyndns2 routes, path: %s", DynDnsUpdatePath)
+	se.Router.GET(DynDnsUpdatePath, inst.handleDynDnsUpdate)
+}
+
+// bindDynDnsHostEvents normalizes hostnames and zones of the dyndns hosts to lower case FQDNs on save.
+func (inst *Instance) bindDynDnsHostEvents() {
+	normalizeFunc := func(e *core.RecordEvent) error {
+		e.Record.Set("hostname", strings.ToLower(dns.Fqdn(e.Record.GetString("hostname"))))
+		e.Record.Set("zone", strings.ToLower(dns.Fqdn(e.Record.GetString("zone"))))
+		return e.Next()
+	}
+	inst.pb.OnRecordCreate(ddnsHostCollectionName).BindFunc(normalizeFunc)
+	inst.pb.OnRecordUpdate(ddnsHostCollectionName).BindFunc(normalizeFunc)
+}
+
+// handleDynDnsUpdate updates the A and AAAA records of the requested hostnames,
+// answering with one dyndns2 return code per hostname.
+func (inst *Instance) handleDynDnsUpdate(e *core.RequestEvent) error {
+	username, password, ok := e.Request.BasicAuth()
+	if !ok {
+		e.Response.Header().Set("WWW-Authenticate", `Basic realm="dyndns"`)
+		return e.String(http.StatusUnauthorized, ddnsBadAuth)
+	}
+
+	hostnames := splitList(e.Request.URL.Query().Get("hostname"))
+	if len(hostnames) == 0 {
+		return e.String(http.StatusOK, ddnsNotFqdn)
+	}
+	if len(hostnames) > ddnsMaxHosts {
+		return e.String(http.StatusOK, ddnsNumHost)
+	}
+
+	ipv4, ipv6 := ddnsAddresses(e.Request.URL.Query().Get("myip"), e.RealIP())
+	if ipv4 == nil && ipv6 == nil {
+		return e.String(http.StatusOK, ddnsDnsErr)
+	}
+
+	results := make([]string, 0, len(hostnames))
+	for _, hostname := range hostnames {
+		results = append(results, inst.updateDynDnsHost(e.Request.Context(), hostname, username, password, ipv4, ipv6))
+	}
+	return e.String(http.StatusOK, strings.Join(results, "\n"))
+}
+
+// updateDynDnsHost authenticates and updates a single hostname, returning its dyndns2 return code.
+func (inst *Instance) updateDynDnsHost(ctx context.Context, hostname, username, password string, ipv4, ipv6 net.IP) string {
+	if _, ok := dns.IsDomainName(hostname); !ok || !strings.Contains(strings.Trim(hostname, "."), ".") {
+		return ddnsNotFqdn
+	}
+	name := strings.ToLower(dns.Fqdn(hostname))
+
+	host, ok := inst.authenticateDynDnsHost(name, username, password)
+	if !ok {
+		return ddnsBadAuth
+	}
+	ctx = WithActor(ctx, Actor{Type: ActorDdns, Name: username})
+
+	zone := host.GetString("zone")
+	ttl := uint32(host.GetInt("ttl"))
+	changed := false
+	addresses := make([]string, 0, 2)
+	if ipv4 != nil {
+		c, err := inst.setDynDnsAddress(ctx, zone, name, "A", ttl, ipv4)
+		if err != nil {
+			log.Errorf("Failed to update dyndns A record, name: %s, err: %+v", name, err)
+			return ddnsDnsErr
+		}
+		changed = changed || c
+		addresses = append(addresses, ipv4.String())
+	}
+	if ipv6 != nil {
+		c, err := inst.setDynDnsAddress(ctx, zone, name, "AAAA", ttl, ipv6)
+		if err != nil {
+			log.Errorf("Failed to update dyndns AAAA record, name: %s, err: %+v", name, err)
+			return ddnsDnsErr
+		}
+		changed = changed || c
+		addresses = append(addresses, ipv6.String())
+	}
+
+	ips := strings.Join(addresses, ",")
+	if !changed {
+		return ddnsNoChg + " " + ips
+	}
+	host.Set("last_ip", ips)
+	if err := inst.pb.Save(host); err != nil {
+		log.Errorf("Failed to save dyndns host, hostname: %s, err: %+v", name, err)
+	}
+	log.Infof("Updated dyndns host, hostname: %s, ip: %s", name, ips)
+	return ddnsGood + " " + ips
+}
+
+// authenticateDynDnsHost returns the dyndns host of the hostname if the credentials are its own. Unknown hostnames
+// fail like wrong credentials, after as long a password check, so that callers can not probe the registered hostnames.
+func (inst *Instance) authenticateDynDnsHost(name, username, password string) (*core.Record, bool) {
+	host, err := inst.pb.FindFirstRecordByData(ddnsHostCollectionName, "hostname", name)
+	if err != nil {
+		_ = bcrypt.CompareHashAndPassword(ddnsDummyPasswordHash(), []byte(password))
+		return nil, false
+	}
+	if !host.ValidatePassword(password) || host.GetString("username") != username {
+		return nil, false
+	}
+	return host, true
+}
+
+// ddnsDummyPasswordHash is the password hash checked for unknown hostnames, with the cost of the stored passwords.
+var ddnsDummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dyndns"), bcrypt.DefaultCost)
+	return hash
+})
+
+// setDynDnsAddress makes ip the only address record of the given type at name.
+// It reports whether anything was changed.
+func (inst *Instance) setDynDnsAddress(ctx context.Context, zone, name, recordType string, ttl uint32, ip net.IP) (changed bool, err error) {
+	coll, err := inst.pb.FindCollectionByNameOrId(recordCollectionName)
+	if err != nil {
+		return false, err
+	}
+	existing, err := inst.pb.FindRecordsByFilter(coll,
+		"zone = {:zone} && name = {:name} && record_type = {:type}", "created", 0, 0,
+		dbx.Params{"zone": zone, "name": name, "type": recordType})
+	if err != nil {
+		return false, err
+	}
+
+	// a disabled record is replaced, re-enabling it
+	if len(existing) == 1 && uint32(existing[0].GetInt("ttl")) == ttl && !existing[0].GetBool("disabled") {
+		var current m.ARecord
+		if json.Unmarshal([]byte(existing[0].GetString("content")), &current) == nil && current.Ip.Equal(ip) {
+			return false, nil
+		}
+	}
+
+	var content any = m.ARecord{Ip: ip}
+	if recordType == "AAAA" {
+		content = m.AAAARecord{Ip: ip}
+	}
+	err = inst.pb.RunInTransaction(func(txApp core.App) error {
+		return inst.replaceRRSet(ctx, txApp, zone, name, recordType, ttl, []any{content})
+	})
+	return err == nil, err
+}
+
+// ddnsAddresses picks the IPv4 and IPv6 addresses to publish from the myip parameter,
+// falling back to the client address if myip holds no valid address.
+func ddnsAddresses(myIp string, clientIp string) (ipv4 net.IP, ipv6 net.IP) {
+	for _, s := range splitList(myIp) {
+		ip := net.ParseIP(s)
+		switch {
+		case ip == nil:
+			continue
+		case ip.To4() != nil && ipv4 == nil:
+			ipv4 = ip.To4()
+		case ip.To4() == nil && ipv6 == nil:
+			ipv6 = ip
+		}
+	}
+	if ipv4 == nil && ipv6 == nil {
+		if ip := net.ParseIP(clientIp); ip != nil {
+			if ip.To4() != nil {
+				ipv4 = ip.To4()
+			} else {
+				ipv6 = ip
+			}
+		}
+	}
+	return ipv4, ipv6
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,39 @@
+package pocketbase
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDdnsAddresses(t *testing.T) {
+	tests := []struct {
+		name     string
+		myIp     string
+		clientIp string
+		ipv4     net.IP
+		ipv6     net.IP
+	}{
+		{name: "ipv4 from myip", myIp: "192.0.2.1", clientIp: "198.51.100.1", ipv4: net.ParseIP("192.0.2.1").To4()},
+		{name: "ipv6 from myip", myIp: "2001:db8::1", clientIp: "198.51.100.1", ipv6: net.ParseIP("2001:db8::1")},
+		{name: "dual stack myip", myIp: "192.0.2.1, 2001:db8::1", clientIp: "198.51.100.1",
+			ipv4: net.ParseIP("192.0.2.1").To4(), ipv6: net.ParseIP("2001:db8::1")},
+		{name: "client ip fallback", myIp: "", clientIp: "198.51.100.1", ipv4: net.ParseIP("198.51.100.1").To4()},
+		{name: "invalid myip falls back", myIp: "invalid", clientIp: "2001:db8::2", ipv6: net.ParseIP("2001:db8::2")},
+		{name: "no address", myIp: "", clientIp: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ipv4, ipv6 := ddnsAddresses(tt.myIp, tt.clientIp)
+			assert.Equal(t, tt.ipv4, ipv4)
+			assert.Equal(t, tt.ipv6, ipv6)
+		})
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	assert.Nil(t, splitList(""))
+	assert.Equal(t, []string{"a.example.com", "b.example.com"}, splitList("a.example.com, ,b.example.com"))
+}
@@ -0,0 +1,325 @@
+package pocketbase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/miekg/dns"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+const (
+	// ExternalDnsRoutePrefix is the path prefix of the external-dns webhook provider API,
+	// external-dns should be started with --webhook-provider-url=http://<listen>/external-dns.
+	ExternalDnsRoutePrefix = "/external-dns"
+	// externalDnsMediaType is the media type of the external-dns webhook protocol.
+	externalDnsMediaType = "application/external.dns.webhook+json;version=1"
+)
+
+// externalDnsLoopback are the clients allowed to use the external-dns webhook provider API when no CIDRs are given:
+// the API is not authenticated, external-dns runs it as a sidecar of the webhook provider.
+var externalDnsLoopback = []string{"127.0.0.0/8", "::1/128"}
+
+// externalDnsEndpoint is the external-dns representation of a RRset.
+type externalDnsEndpoint struct {
+	DNSName          string                                `json:"dnsName,omitempty"`
+	Targets          []string                              `json:"targets,omitempty"`
+	RecordType       string                                `json:"recordType,omitempty"`
+	SetIdentifier    string                                `json:"setIdentifier,omitempty"`
+	RecordTTL        int64                                 `json:"recordTTL,omitempty"`
+	Labels           map[string]string                     `json:"labels,omitempty"`
+	ProviderSpecific []externalDnsProviderSpecificProperty `json:"providerSpecific,omitempty"`
+}
+
+// externalDnsProviderSpecificProperty is a provider specific endpoint property, none is supported.
+type externalDnsProviderSpecificProperty struct {
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// externalDnsChanges is the set of changes external-dns wants to apply.
+type externalDnsChanges struct {
+	Create    []*externalDnsEndpoint `json:"Create,omitempty"`
+	UpdateOld []*externalDnsEndpoint `json:"UpdateOld,omitempty"`
+	UpdateNew []*externalDnsEndpoint `json:"UpdateNew,omitempty"`
+	Delete    []*externalDnsEndpoint `json:"Delete,omitempty"`
+}
+
+// externalDnsDomainFilter tells external-dns which domains are managed by the provider.
+type externalDnsDomainFilter struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// bindExternalDnsRoutes registers the external-dns webhook provider routes,
+// it does nothing if the webhook provider is not enabled.
+func (inst *Instance) bindExternalDnsRoutes(se *core.ServeEvent) {
+	if !inst.externalDns {
+		log.Debug("external-dns webhook provider is not enabled, skipping external-dns routes...")
+		return
+	}
+	log.Infof("Bind external-dns routes, prefix: %s, allow from: %v", ExternalDnsRoutePrefix, inst.externalDnsAllowFrom)
+
+	g := se.Router.Group(ExternalDnsRoutePrefix)
+	g.BindFunc(inst.requireExternalDnsClient)
+	g.GET("", inst.handleExternalDnsNegotiate)
+	g.GET("/records", inst.handleExternalDnsGetRecords)
+	g.POST("/records", inst.handleExternalDnsApplyChanges)
+	g.POST("/adjustendpoints", inst.handleExternalDnsAdjustEndpoints)
+	g.GET("/healthz", func(e *core.RequestEvent) error {
+		return e.String(http.StatusOK, "ok")
+	})
+}
+
+// requireExternalDnsClient rejects the clients outside the allowed CIDRs, loopback clients only if none are configured.
+func (inst *Instance) requireExternalDnsClient(e *core.RequestEvent) error {
+	allowFrom := inst.externalDnsAllowFrom
+	if len(allowFrom) == 0 {
+		allowFrom = externalDnsLoopback
+	}
+	if !ipAllowed(e.RealIP(), allowFrom) {
+		return e.ForbiddenError("Client address is not allowed.", nil)
+	}
+	return e.Next()
+}
+
+// handleExternalDnsNegotiate returns the served zones as the domain filter.
+func (inst *Instance) handleExternalDnsNegotiate(e *core.RequestEvent) error {
+	zones, err := inst.FetchZones()
+	if err != nil {
+		return e.InternalServerError("Failed to fetch zones.", err)
+	}
+	filter := externalDnsDomainFilter{}
+	for _, zone := range zones {
+		filter.Include = append(filter.Include, strings.TrimSuffix(zone, "."))
+	}
+	return externalDnsJSON(e, http.StatusOK, filter)
+}
+
+// handleExternalDnsGetRecords returns all RRsets of the served zones as endpoints.
+func (inst *Instance) handleExternalDnsGetRecords(e *core.RequestEvent) error {
+	zones, err := inst.FetchZones()
+	if err != nil {
+		return e.InternalServerError("Failed to fetch zones.", err)
+	}
+
+	endpoints := make([]*externalDnsEndpoint, 0)
+	for _, zone := range zones {
+		settings, err := inst.FetchZone(zone)
+		if err != nil {
+			return e.InternalServerError("Failed to fetch zone settings.", err)
+		}
+		recs, err := inst.FetchZoneRecords(zone)
+		if err != nil {
+			return e.InternalServerError("Failed to fetch records.", err)
+		}
+		endpoints = append(endpoints, recordsToEndpoints(NewQueryComposer(inst, zone, settings), recs)...)
+	}
+	return externalDnsJSON(e, http.StatusOK, endpoints)
+}
+
+// handleExternalDnsApplyChanges applies the changes within a single transaction,
+// after taking an automatic snapshot of every zone being changed.
+func (inst *Instance) handleExternalDnsApplyChanges(e *core.RequestEvent) error {
+	var changes externalDnsChanges
+	if err := json.NewDecoder(e.Request.Body).Decode(&changes); err != nil {
+		return e.BadRequestError("Failed to decode changes.", err)
+	}
+
+	ctx := WithActor(e.Request.Context(), Actor{Type: ActorExternalDns, Name: e.RealIP()})
+	err := inst.pb.RunInTransaction(func(txApp core.App) error {
+		if err := inst.snapshotEndpointZones(ctx, txApp, &changes); err != nil {
+			return err
+		}
+		for _, ep := range append(changes.Delete, changes.UpdateOld...) {
+			zone, err := inst.endpointZone(ep)
+			if err != nil {
+				return err
+			}
+			log.Debugf("external-dns deleting endpoint, name: %s, type: %s", ep.DNSName, ep.RecordType)
+			if err = inst.deleteRRSet(ctx, txApp, zone, dns.Fqdn(ep.DNSName), ep.RecordType); err != nil {
+				return err
+			}
+		}
+		for _, ep := range append(changes.Create, changes.UpdateNew...) {
+			zone, err := inst.endpointZone(ep)
+			if err != nil {
+				return err
+			}
+			contents, err := inst.endpointContents(ep)
+			if err != nil {
+				return err
+			}
+			log.Debugf("external-dns saving endpoint, name: %s, type: %s, targets: %v", ep.DNSName, ep.RecordType, ep.Targets)
+			if err = inst.replaceRRSet(ctx, txApp, zone, dns.Fqdn(ep.DNSName), ep.RecordType, uint32(ep.RecordTTL), contents); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Failed to apply external-dns changes, err: %+v", err)
+		return e.BadRequestError("Failed to apply changes: "+err.Error(), nil)
+	}
+	return e.NoContent(http.StatusNoContent)
+}
+
+// snapshotEndpointZones takes an automatic snapshot of each zone the changes belong to.
+func (inst *Instance) snapshotEndpointZones(ctx context.Context, app core.App, changes *externalDnsChanges) error {
+	snapshotted := make(map[string]bool)
+	for _, eps := range [][]*externalDnsEndpoint{changes.Create, changes.UpdateOld, changes.UpdateNew, changes.Delete} {
+		for _, ep := range eps {
+			zone, err := inst.endpointZone(ep)
+			if err != nil {
+				return err
+			}
+			if snapshotted[zone] {
+				continue
+			}
+			snapshotted[zone] = true
+			if _, err = inst.createZoneSnapshot(ctx, app, zone, SnapshotAuto, "before external-dns changes"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// handleExternalDnsAdjustEndpoints normalizes the desired endpoints to the form returned by the records endpoint,
+// so that external-dns does not plan changes for equal RRsets.
+func (inst *Instance) handleExternalDnsAdjustEndpoints(e *core.RequestEvent) error {
+	var endpoints []*externalDnsEndpoint
+	if err := json.NewDecoder(e.Request.Body).Decode(&endpoints); err != nil {
+		return e.BadRequestError("Failed to decode endpoints.", err)
+	}
+	for _, ep := range endpoints {
+		ep.ProviderSpecific = nil
+		for i, target := range ep.Targets {
+			if ep.RecordType == "TXT" {
+				ep.Targets[i] = strconv.Quote(unquoteTxt(target))
+				continue
+			}
+			rr, err := dns.NewRR(fmt.Sprintf("%s IN %s %s", dns.Fqdn(ep.DNSName), ep.RecordType, target))
+			if err == nil && rr != nil {
+				ep.Targets[i] = m.RdataString(rr)
+			}
+		}
+	}
+	return externalDnsJSON(e, http.StatusOK, endpoints)
+}
+
+// recordsToEndpoints groups the records into one endpoint per name and type. The targets are built from the record
+// contents and the TTLs are the served ones, the composer provides the zone settings.
+func recordsToEndpoints(c *Composer, recs []*m.Record) []*externalDnsEndpoint {
+	endpoints := make([]*externalDnsEndpoint, 0)
+	index := make(map[string]*externalDnsEndpoint)
+	for _, rec := range recs {
+		// the SOA record and the template records are maintained by the zone owner, not by external-dns
+		if rec.RecordType == "SOA" || rec.RecordType == m.TemplateRecordType {
+			continue
+		}
+		ttl := c.tryRefillTtl(rec)
+		rr, err := m.RRFromContent(rec.Name, ttl, rec.RecordType, rec.Content)
+		if err != nil || rr == nil {
+			log.Warningf("Skipping record for external-dns, zone: %s, name: %s, type: %s, err: %+v",
+				rec.Zone, rec.Name, rec.RecordType, err)
+			continue
+		}
+		key := rec.Name + "/" + rec.RecordType
+		ep, ok := index[key]
+		if !ok {
+			ep = &externalDnsEndpoint{
+				DNSName:    strings.TrimSuffix(rec.Name, "."),
+				RecordType: rec.RecordType,
+				RecordTTL:  int64(ttl),
+				Targets:    []string{},
+			}
+			index[key] = ep
+			endpoints = append(endpoints, ep)
+		}
+		target := m.RdataString(rr)
+		if t, ok := rr.(*dns.TXT); ok {
+			target = strconv.Quote(strings.Join(t.Txt, ""))
+		}
+		ep.Targets = append(ep.Targets, target)
+	}
+	return endpoints
+}
+
+// endpointZone returns the served zone the endpoint belongs to.
+func (inst *Instance) endpointZone(ep *externalDnsEndpoint) (string, error) {
+	if !m.IsSupportedRecordType(ep.RecordType) {
+		return "", fmt.Errorf("unsupported record type: %s", ep.RecordType)
+	}
+	zone, err := inst.MatchZone(ep.DNSName)
+	if err != nil {
+		return "", err
+	}
+	if zone == "" {
+		return "", fmt.Errorf("no zone found for name: %s", ep.DNSName)
+	}
+	return zone, nil
+}
+
+// endpointContents converts the targets of the endpoint into record contents.
+func (inst *Instance) endpointContents(ep *externalDnsEndpoint) ([]any, error) {
+	contents := make([]any, 0, len(ep.Targets))
+	for _, target := range ep.Targets {
+		if ep.RecordType == "TXT" {
+			contents = append(contents, m.TXTRecord{Text: unquoteTxt(target)})
+			continue
+		}
+		rr, err := dns.NewRR(fmt.Sprintf("%s IN %s %s", dns.Fqdn(ep.DNSName), ep.RecordType, target))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s target %q: %w", ep.RecordType, target, err)
+		}
+		content, err := inst.contentFromRR(rr)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, content)
+	}
+	return contents, nil
+}
+
+// contentFromRR converts a resource record into its content model,
+// setting the zone of CNAME targets to the served zone they belong to, empty for external targets.
+func (inst *Instance) contentFromRR(rr dns.RR) (any, error) {
+	_, content, err := m.ContentFromRR(rr)
+	if err != nil {
+		return nil, err
+	}
+	if cname, ok := content.(m.CNAMERecord); ok {
+		if cname.Zone, err = inst.MatchZone(cname.Host); err != nil {
+			return nil, err
+		}
+		content = cname
+	}
+	return content, nil
+}
+
+// unquoteTxt strips the surrounding quotes of a TXT target, if any.
+func unquoteTxt(target string) string {
+	if len(target) >= 2 && strings.HasPrefix(target, `"`) && strings.HasSuffix(target, `"`) {
+		if s, err := strconv.Unquote(target); err == nil {
+			return s
+		}
+		return target[1 : len(target)-1]
+	}
+	return target
+}
+
+func externalDnsJSON(e *core.RequestEvent, status int, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return e.InternalServerError("Failed to encode response.", err)
+	}
+	e.Response.Header().Set("Vary", "Content-Type")
+	return e.Blob(status, externalDnsMediaType, b)
+}
@@ -0,0 +1,53 @@
+package pocketbase
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnquoteTxt(t *testing.T) {
+	assert.Equal(t, "heritage=external-dns,external-dns/owner=default",
+		unquoteTxt(`"heritage=external-dns,external-dns/owner=default"`))
+	assert.Equal(t, `v=spf1 include:"x" -all`, unquoteTxt(`"v=spf1 include:\"x\" -all"`))
+	assert.Equal(t, "v=spf1 -all", unquoteTxt("v=spf1 -all"))
+	assert.Equal(t, `"`, unquoteTxt(`"`))
+}
+
+func TestRequireExternalDnsClient(t *testing.T) {
+	tests := []struct {
+		name       string
+		allowFrom  []string
+		remoteAddr string
+		allowed    bool
+	}{
+		{name: "loopback by default", remoteAddr: "127.0.0.1:5353", allowed: true},
+		{name: "ipv6 loopback by default", remoteAddr: "[::1]:5353", allowed: true},
+		{name: "remote denied by default", remoteAddr: "192.0.2.1:5353"},
+		{name: "remote within cidr", allowFrom: []string{"192.0.2.0/24"}, remoteAddr: "192.0.2.1:5353", allowed: true},
+		{name: "loopback outside cidr", allowFrom: []string{"192.0.2.0/24"}, remoteAddr: "127.0.0.1:5353"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inst := NewWithDataDir(t.TempDir()).WithExternalDns(tt.allowFrom...)
+			e := &core.RequestEvent{App: inst.pb}
+			e.Request = httptest.NewRequest(http.MethodPost, ExternalDnsRoutePrefix+"/records", nil)
+			e.Request.RemoteAddr = tt.remoteAddr
+			e.Response = httptest.NewRecorder()
+
+			err := inst.requireExternalDnsClient(e)
+			if tt.allowed {
+				assert.NoError(t, err)
+				return
+			}
+			var apiErr *router.ApiError
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, http.StatusForbidden, apiErr.Status)
+		})
+	}
+}
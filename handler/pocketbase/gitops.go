@@ -0,0 +1,327 @@
+package pocketbase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/miekg/dns"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+// gitopsActor is the actor of the changes made by the reconciles of the GitOps directory.
+var gitopsActor = Actor{Type: ActorSystem, Name: "gitops"}
+
+// gitopsFileFormats are the formats of the zone definitions of the GitOps directory, by file extension.
+var gitopsFileFormats = map[string]string{
+	".zone": zoneFileFormat,
+	".yaml": octodnsFormat,
+	".yml":  octodnsFormat,
+}
+
+// gitopsReport tells what the last reconcile of the GitOps directory changed. The drift of a zone, the changes
+// made to the database since the zone definitions were reconciled, are the RRsets created, replaced and pruned.
+type gitopsReport struct {
+	Dir        string              `json:"dir"`
+	Prune      bool                `json:"prune"`
+	Reconciled time.Time           `json:"reconciled"`
+	Zones      []*zoneImportReport `json:"zones"`
+	// Released are the zones whose definitions were removed, their records are kept but no longer managed
+	Released []string `json:"released"`
+	Errors   []string `json:"errors"`
+}
+
+// gitopsState is the state of the reconciles of the GitOps directory.
+type gitopsState struct {
+	// mu serializes the reconciles
+	mu sync.Mutex
+	// fingerprint is the hash of the zone definitions of the last successful reconcile, telling apart the polls
+	// finding changes
+	fingerprint string
+	report      *gitopsReport
+}
+
+// gitopsZone is a zone definition of the GitOps directory.
+type gitopsZone struct {
+	zone   string
+	path   string
+	format string
+}
+
+type managedCtxKey struct{}
+
+// withManagedChanges returns a context which allows the saves and deletes made with it to change managed records.
+// The records saved with it are marked as managed if mark is true.
+func withManagedChanges(ctx context.Context, mark bool) context.Context {
+	return context.WithValue(ctx, managedCtxKey{}, mark)
+}
+
+// managedChanges reports whether the context allows changing managed records, and whether it marks them.
+func managedChanges(ctx context.Context) (allowed bool, mark bool) {
+	if ctx == nil {
+		return false, false
+	}
+	mark, allowed = ctx.Value(managedCtxKey{}).(bool)
+	return allowed, mark
+}
+
+// bindGitopsEvents keeps the records managed by the GitOps directory read-only, in the UI and in the API alike:
+// only the reconciles may create, change or delete them.
+func (inst *Instance) bindGitopsEvents() {
+	if inst.gitopsDir == "" {
+		return
+	}
+	log.Debug("Bind GitOps events...")
+
+	saveFunc := func(e *core.RecordEvent) error {
+		allowed, mark := managedChanges(e.Context)
+		if allowed {
+			if mark {
+				e.Record.Set("managed", true)
+			}
+			return e.Next()
+		}
+		original := e.Record.Original()
+		if e.Record.GetBool("managed") || !e.Record.IsNew() && original != nil && original.GetBool("managed") {
+			return errRecordManaged(e.Record)
+		}
+		return e.Next()
+	}
+	inst.pb.OnRecordCreate(recordCollectionName).BindFunc(saveFunc)
+	inst.pb.OnRecordUpdate(recordCollectionName).BindFunc(saveFunc)
+	inst.pb.OnRecordDelete(recordCollectionName).BindFunc(func(e *core.RecordEvent) error {
+		if allowed, _ := managedChanges(e.Context); !allowed && e.Record.GetBool("managed") {
+			return errRecordManaged(e.Record)
+		}
+		return e.Next()
+	})
+}
+
+// errRecordManaged returns the error rejecting a change of a managed record.
+func errRecordManaged(rec *core.Record) error {
+	return validation.Errors{"managed": validation.NewError("validation_record_managed",
+		fmt.Sprintf("%s %s is managed by the GitOps directory, it can only be changed there",
+			rec.GetString("name"), rec.GetString("record_type")))}
+}
+
+// markManaged marks the records as managed, saving them with a context marking them.
+func markManaged(ctx context.Context, app core.App, recs []*core.Record) error {
+	for _, rec := range recs {
+		if rec.GetBool("managed") {
+			continue
+		}
+		rec.Set("managed", true)
+		if err := app.SaveWithContext(ctx, rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseManaged unmarks the managed records, so that they can be changed again.
+func releaseManaged(ctx context.Context, app core.App, recs []*core.Record) error {
+	ctx = withManagedChanges(WithoutIntegrityChecks(ctx), false)
+	for _, rec := range recs {
+		if !rec.GetBool("managed") {
+			continue
+		}
+		rec.Set("managed", false)
+		if err := app.SaveWithContext(ctx, rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bindGitopsRoutes registers the routes of the GitOps directory reconciles, for superusers only.
+func (inst *Instance) bindGitopsRoutes(g *router.RouterGroup[*core.RequestEvent]) {
+	if inst.gitopsDir == "" {
+		return
+	}
+	g.GET("/gitops", inst.handleGetGitopsReport).Bind(apis.RequireSuperuserAuth())
+	g.POST("/gitops/reconcile", inst.handleReconcileGitops).Bind(apis.RequireSuperuserAuth())
+}
+
+// handleGetGitopsReport answers with the report of the last reconcile.
+func (inst *Instance) handleGetGitopsReport(e *core.RequestEvent) error {
+	inst.gitops.mu.Lock()
+	report := inst.gitops.report
+	inst.gitops.mu.Unlock()
+	if report == nil {
+		return e.NotFoundError("The GitOps directory has not been reconciled yet.", nil)
+	}
+	return e.JSON(http.StatusOK, report)
+}
+
+// handleReconcileGitops reconciles the GitOps directory right away, answering with the report.
+func (inst *Instance) handleReconcileGitops(e *core.RequestEvent) error {
+	report := inst.reconcileGitops(e.Request.Context())
+	if len(report.Errors) > 0 {
+		return e.JSON(http.StatusBadRequest, report)
+	}
+	return e.JSON(http.StatusOK, report)
+}
+
+// initGitopsSchedule starts a background goroutine reconciling the GitOps directory right away,
+// then at the configured interval.
+func (inst *Instance) initGitopsSchedule() {
+	if inst.gitopsDir == "" {
+		return
+	}
+	go func() {
+		log.Infof("Start GitOps reconcile schedule, dir: %s, interval: %s, prune: %t", inst.gitopsDir, inst.gitopsInterval,
+			inst.gitopsPrune)
+		for {
+			inst.reconcileGitops(context.Background())
+			time.Sleep(inst.gitopsInterval)
+		}
+	}()
+}
+
+// reconcileGitops reconciles the zone definitions of the GitOps directory into the database, each zone within
+// its own transaction like an import with overwrite. The zones are compared with the database at every reconcile,
+// even if their definitions did not change, so that the changes made through the API are reported or pruned.
+// Zones whose definitions were removed are released.
+func (inst *Instance) reconcileGitops(ctx context.Context) *gitopsReport {
+	inst.gitops.mu.Lock()
+	defer inst.gitops.mu.Unlock()
+
+	report := &gitopsReport{
+		Dir:        inst.gitopsDir,
+		Prune:      inst.gitopsPrune,
+		Reconciled: time.Now(),
+		Zones:      []*zoneImportReport{},
+		Released:   []string{},
+		Errors:     []string{},
+	}
+	zones, fingerprint, err := readGitopsDir(inst.gitopsDir)
+	if err != nil {
+		log.Errorf("Failed to read GitOps directory, dir: %s, err: %+v", inst.gitopsDir, err)
+		report.Errors = append(report.Errors, err.Error())
+		inst.gitops.report = report
+		return report
+	}
+	ctx = WithActor(ctx, gitopsActor)
+	opts := zoneImportOptions{Overwrite: true, CreateZone: true, Managed: true, Prune: inst.gitopsPrune}
+	for _, gz := range zones {
+		zoneReport, err := inst.importZoneDefinition(ctx, gz.zone, gz.path, gz.format, opts)
+		if zoneReport != nil {
+			report.Zones = append(report.Zones, zoneReport)
+		}
+		if err != nil {
+			log.Errorf("Failed to reconcile zone, zone: %s, err: %+v", gz.zone, err)
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", gz.path, err))
+		}
+	}
+	released, err := inst.releaseGitopsZones(ctx, zones)
+	report.Released = append(report.Released, released...)
+	if err != nil {
+		log.Errorf("Failed to release removed GitOps zones, err: %+v", err)
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	// the polls changing nothing are only logged for debugging
+	logf := log.Debugf
+	if fingerprint != inst.gitops.fingerprint || len(report.Released) > 0 || len(report.Errors) > 0 ||
+		slices.ContainsFunc(report.Zones, (*zoneImportReport).changed) {
+		logf = log.Infof
+	}
+	if len(report.Errors) == 0 {
+		inst.gitops.fingerprint = fingerprint
+	}
+	inst.gitops.report = report
+	logf("Reconciled GitOps directory, dir: %s, zones: %d, released: %d, errors: %d", inst.gitopsDir,
+		len(report.Zones), len(report.Released), len(report.Errors))
+	return report
+}
+
+// releaseGitopsZones releases the managed records of the zones which are no longer defined in the GitOps directory,
+// returning the released zones. The records are kept, they can be changed through the API again.
+func (inst *Instance) releaseGitopsZones(ctx context.Context, zones []*gitopsZone) ([]string, error) {
+	recs, err := inst.pb.FindRecordsByFilter(recordCollectionName, "managed = true", "zone", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	defined := make(map[string]bool, len(zones))
+	for _, gz := range zones {
+		defined[gz.zone] = true
+	}
+	byZone := make(map[string][]*core.Record)
+	released := make([]string, 0)
+	for _, rec := range recs {
+		zone := rec.GetString("zone")
+		if defined[zone] {
+			continue
+		}
+		if _, ok := byZone[zone]; !ok {
+			released = append(released, zone)
+		}
+		byZone[zone] = append(byZone[zone], rec)
+	}
+
+	// the served records do not change
+	ctx = deferCacheInvalidation(withoutSerialBumps(ctx))
+	for _, zone := range released {
+		err = inst.pb.RunInTransaction(func(txApp core.App) error {
+			return releaseManaged(ctx, txApp, byZone[zone])
+		})
+		if err != nil {
+			return released, fmt.Errorf("%s: %w", zone, err)
+		}
+		log.Infof("Released zone removed from the GitOps directory, zone: %s, records: %d", zone, len(byZone[zone]))
+	}
+	return released, nil
+}
+
+// readGitopsDir lists the zone definitions of the GitOps directory, named after their zone with the extension of
+// their format, e.g. example.com.zone or example.com.yaml, and returns them with the hash of their contents.
+func readGitopsDir(dir string) ([]*gitopsZone, string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	zones := make([]*gitopsZone, 0)
+	seen := make(map[string]string)
+	hash := sha256.New()
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		format, ok := gitopsFileFormats[ext]
+		if !ok || entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		zone := strings.ToLower(dns.Fqdn(strings.TrimSuffix(entry.Name(), ext)))
+		if err = m.ValidateZoneName(zone); err != nil {
+			return nil, "", fmt.Errorf("invalid zone definition %s: %w", entry.Name(), err)
+		}
+		if other, ok := seen[zone]; ok {
+			return nil, "", fmt.Errorf("zone %s is defined by both %s and %s", zone, other, entry.Name())
+		}
+		seen[zone] = entry.Name()
+
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", err
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00", entry.Name(), len(data))
+		hash.Write(data)
+		zones = append(zones, &gitopsZone{zone: zone, path: path, format: format})
+	}
+	slices.SortFunc(zones, func(a, b *gitopsZone) int {
+		return strings.Compare(a.zone, b.zone)
+	})
+	return zones, hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,61 @@
+package pocketbase
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadGitopsDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+	write("example.com.zone", "@ IN A 192.0.2.1\n")
+	write("Example.ORG.yml", "'':\n  type: A\n  value: 192.0.2.2\n")
+	write("README.md", "zone definitions\n")
+	write(".hidden.zone", "")
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub.zone"), 0o700))
+
+	zones, fingerprint, err := readGitopsDir(dir)
+	require.NoError(t, err)
+	got := make([]string, 0, len(zones))
+	for _, gz := range zones {
+		got = append(got, gz.zone+" "+gz.format+" "+filepath.Base(gz.path))
+	}
+	assert.Equal(t, []string{"example.com. zone example.com.zone", "example.org. octodns Example.ORG.yml"}, got)
+
+	_, same, err := readGitopsDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, fingerprint, same)
+
+	write("example.com.zone", "@ IN A 192.0.2.3\n")
+	_, changed, err := readGitopsDir(dir)
+	require.NoError(t, err)
+	assert.NotEqual(t, fingerprint, changed)
+
+	write("example.com.yaml", "")
+	_, _, err = readGitopsDir(dir)
+	assert.ErrorContains(t, err, "defined by both")
+
+	_, _, err = readGitopsDir(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestManagedChanges(t *testing.T) {
+	allowed, mark := managedChanges(context.Background())
+	assert.False(t, allowed)
+	assert.False(t, mark)
+
+	allowed, mark = managedChanges(withManagedChanges(context.Background(), true))
+	assert.True(t, allowed)
+	assert.True(t, mark)
+
+	allowed, mark = managedChanges(withManagedChanges(context.Background(), false))
+	assert.True(t, allowed)
+	assert.False(t, mark)
+}
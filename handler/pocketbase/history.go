@@ -0,0 +1,143 @@
+package pocketbase
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+const recordHistoryCollectionName = "coredns_record_history"
+
+// historyFields are the fields of a record kept in the snapshots of its history.
+var historyFields = []string{"zone", "name", "record_type", "ttl", "content", "disabled", "comment", "owner"}
+
+// bindRecordHistory writes a history entry with the actor and the before and after snapshots
+// for every create, update and delete of the records collection, within the transaction of the change.
+func (inst *Instance) bindRecordHistory() {
+	log.Debug("Bind record history...")
+
+	inst.actors.bind(inst.pb, recordCollectionName)
+	inst.actors.bind(inst.pb, zoneCollectionName)
+
+	historyFunc := func(e *core.RecordEvent) error {
+		var before map[string]any
+		if e.Type != core.ModelEventTypeCreate {
+			before = recordSnapshot(e.Record.Original())
+		}
+		actor := inst.actors.actorOf(e.Context, e.Record)
+		return e.App.RunInTransaction(func(txApp core.App) error {
+			e.App = txApp
+			if err := e.Next(); err != nil {
+				return err
+			}
+			var after map[string]any
+			if e.Type != core.ModelEventTypeDelete {
+				after = recordSnapshot(e.Record)
+			}
+			return saveRecordHistory(txApp, e.Record.Id, e.Type, actor, before, after)
+		})
+	}
+	inst.pb.OnRecordCreate(recordCollectionName).BindFunc(historyFunc)
+	inst.pb.OnRecordUpdate(recordCollectionName).BindFunc(historyFunc)
+	inst.pb.OnRecordDelete(recordCollectionName).BindFunc(historyFunc)
+}
+
+// bindHistoryRoutes registers the record history routes.
+func (inst *Instance) bindHistoryRoutes(g *router.RouterGroup[*core.RequestEvent]) {
+	g.POST("/history/{id}/restore", inst.handleRestoreRevision).Bind(apis.RequireAuth())
+}
+
+// handleRestoreRevision restores a record to the revision of a history entry.
+// Restoring the entry of a delete re-creates the deleted record.
+func (inst *Instance) handleRestoreRevision(e *core.RequestEvent) error {
+	entry, err := inst.pb.FindRecordById(recordHistoryCollectionName, e.Request.PathValue("id"))
+	if err != nil {
+		return e.NotFoundError("History entry not found.", err)
+	}
+	if err = inst.requireZoneRole(e, entry.GetString("zone"), zoneEditors); err != nil {
+		return err
+	}
+	// the record may have moved to another zone since, which the restore takes it out of
+	if current, err := inst.pb.FindRecordById(recordCollectionName, entry.GetString("record")); err == nil &&
+		current.GetString("zone") != entry.GetString("zone") {
+		if err = inst.requireZoneRole(e, current.GetString("zone"), zoneEditors); err != nil {
+			return err
+		}
+	}
+
+	var snapshot map[string]any
+	if err = entry.UnmarshalJSONField("after", &snapshot); err != nil || snapshot == nil {
+		if err = entry.UnmarshalJSONField("before", &snapshot); err != nil || snapshot == nil {
+			return e.BadRequestError("History entry has no snapshot to restore.", err)
+		}
+	}
+
+	ctx := WithActor(e.Request.Context(), requestActor(e))
+	rec, err := inst.restoreRecord(ctx, entry.GetString("record"), snapshot)
+	if err != nil {
+		log.Errorf("Failed to restore record, history entry: %s, err: %+v", entry.Id, err)
+		return e.BadRequestError("Failed to restore record: "+err.Error(), err)
+	}
+	log.Infof("Restored record, id: %s, history entry: %s", rec.Id, entry.Id)
+	return e.JSON(http.StatusOK, rec)
+}
+
+// restoreRecord sets the fields of the record with the given id to the snapshot,
+// creating the record if it does not exist anymore.
+func (inst *Instance) restoreRecord(ctx context.Context, id string, snapshot map[string]any) (*core.Record, error) {
+	rec, err := inst.pb.FindRecordById(recordCollectionName, id)
+	if err != nil {
+		coll, err := inst.pb.FindCollectionByNameOrId(recordCollectionName)
+		if err != nil {
+			return nil, err
+		}
+		rec = core.NewRecord(coll)
+		rec.Id = id
+	}
+	for _, field := range historyFields {
+		rec.Set(field, snapshot[field])
+	}
+	if err = inst.pb.SaveWithContext(ctx, rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// saveRecordHistory writes a history entry of a record change.
+func saveRecordHistory(app core.App, recordId string, operation string, actor Actor, before, after map[string]any) error {
+	coll, err := app.FindCollectionByNameOrId(recordHistoryCollectionName)
+	if err != nil {
+		return err
+	}
+	snapshot := after
+	if snapshot == nil {
+		snapshot = before
+	}
+	entry := core.NewRecord(coll)
+	entry.Set("record", recordId)
+	entry.Set("zone", snapshot["zone"])
+	entry.Set("name", snapshot["name"])
+	entry.Set("record_type", snapshot["record_type"])
+	entry.Set("operation", operation)
+	entry.Set("actor_type", actor.Type)
+	entry.Set("actor", actor.Name)
+	entry.Set("before", before)
+	entry.Set("after", after)
+	return app.Save(entry)
+}
+
+// recordSnapshot returns the history fields of a record.
+func recordSnapshot(rec *core.Record) map[string]any {
+	if rec == nil {
+		return nil
+	}
+	snapshot := make(map[string]any, len(historyFields))
+	for _, field := range historyFields {
+		snapshot[field] = rec.Get(field)
+	}
+	return snapshot
+}
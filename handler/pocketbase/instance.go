@@ -2,12 +2,14 @@ package pocketbase
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/miekg/dns"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/hook"
@@ -32,12 +34,37 @@ type Instance struct {
 	suPassword    string
 	listen        string
 	defaultTtl    int
+	minTtl        int
+	maxTtl        int
 	cacheCapacity int
+	acmeDnsZone   string
+	externalDns   bool
+	// externalDnsAllowFrom restricts the external-dns webhook provider to these CIDRs
+	externalDnsAllowFrom []string
+	// integrityOverride disables the zone integrity checks
+	integrityOverride bool
+	// claimResolver is the DNS server zone claims are verified with, empty for the system resolver
+	claimResolver string
+	// gitopsDir is the directory of the zone definitions reconciled into the database, empty disables GitOps
+	gitopsDir      string
+	gitopsInterval time.Duration
+	gitopsPrune    bool
+	// exportDir is the directory the zone files of the zones are continuously exported to, empty disables the exports
+	exportDir   string
+	exportDelay time.Duration
+	// seeds are the records of zones imported at startup, seedOverwrite replaces the differing existing RRsets
+	seeds         []*zoneSeed
+	seedOverwrite bool
 	// internal
-	zonesCache   *cache.ZonesCache
-	recordsCache *cache.RecordsCache
-	readyChan    chan struct{}
-	composer     *Composer
+	zonesCache        *cache.ZonesCache
+	zoneSettingsCache *cache.ZoneSettingsCache
+	recordsCache      *cache.RecordsCache
+	readyChan         chan struct{}
+	composer          *Composer
+	actors            requestActors
+	gitops            gitopsState
+	zoneFileExports   zoneFileExports
+	templates         zoneTemplates
 }
 
 // NewWithDataDir creates a new Instance with the specified data directory.
@@ -52,6 +79,7 @@ func NewWithDataDir(dataDir string) *Instance {
 		readyChan: make(chan struct{}),
 	}
 	inst.composer = NewComposer(inst)
+	inst.bindCommands()
 
 	return inst
 }
@@ -77,6 +105,14 @@ func (inst *Instance) WithDefaultTtl(defaultTtl int) *Instance {
 	return inst
 }
 
+// WithTtlLimits sets the minimum and maximum TTL of served records, 0 disables the limit.
+// The limits of a zone can only narrow these global limits.
+func (inst *Instance) WithTtlLimits(minTtl int, maxTtl int) *Instance {
+	inst.minTtl = minTtl
+	inst.maxTtl = maxTtl
+	return inst
+}
+
 // WithListen sets the address and port where the PocketBase server should listen.
 // The format should be "address:port".
 func (inst *Instance) WithListen(listen string) *Instance {
@@ -85,7 +121,7 @@ func (inst *Instance) WithListen(listen string) *Instance {
 }
 
 // WithCacheCapacity sets the capacity for the records cache.
-// If capacity is greater than 1, both zones and records caching will be enabled.
+// If capacity is greater than 1, zones, zone settings and records caching will be enabled.
 // If there's an error initializing the cache, caching will be disabled.
 func (inst *Instance) WithCacheCapacity(capacity int) *Instance {
 	inst.cacheCapacity = capacity
@@ -98,6 +134,12 @@ func (inst *Instance) WithCacheCapacity(capacity int) *Instance {
 			inst.cacheCapacity = 0
 			return inst
 		}
+		inst.zoneSettingsCache, err = cache.NewZoneSettingsCache()
+		if err != nil {
+			log.Error("Failed to create zone settings cache", err)
+			inst.cacheCapacity = 0
+			return inst
+		}
 		inst.recordsCache, err = cache.NewRecordsCache(inst.cacheCapacity)
 		if err != nil {
 			log.Error("Failed to create records cache", err)
@@ -108,6 +150,77 @@ func (inst *Instance) WithCacheCapacity(capacity int) *Instance {
 	return inst
 }
 
+// WithAcmeDnsZone sets the zone in which acme-dns accounts publish their challenges.
+// An empty zone disables the acme-dns compatible API.
+func (inst *Instance) WithAcmeDnsZone(zone string) *Instance {
+	if zone != "" {
+		zone = dns.Fqdn(zone)
+	}
+	inst.acmeDnsZone = zone
+	return inst
+}
+
+// WithExternalDns enables the external-dns webhook provider API.
+// Only clients within the CIDRs may use the API, loopback clients only if none are given.
+func (inst *Instance) WithExternalDns(allowFrom ...string) *Instance {
+	inst.externalDns = true
+	inst.externalDnsAllowFrom = allowFrom
+	return inst
+}
+
+// WithIntegrityOverride sets whether the zone integrity checks of record changes are disabled.
+// It is meant for migrating data which does not yet satisfy the integrity rules.
+func (inst *Instance) WithIntegrityOverride(integrityOverride bool) *Instance {
+	inst.integrityOverride = integrityOverride
+	return inst
+}
+
+// WithClaimResolver sets the DNS server the TXT records proving zone claims are looked up with,
+// when the parent zone is not served by the instance. The port defaults to 53, an empty server
+// uses the system resolver.
+func (inst *Instance) WithClaimResolver(server string) *Instance {
+	if server != "" {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+	}
+	inst.claimResolver = server
+	return inst
+}
+
+// WithGitops sets the directory of zone files and octoDNS YAML files reconciled into the database at the
+// interval. The reconciled records are managed, they can only be changed in the directory.
+// With prune, the RRsets of the managed zones which are not in their zone definitions are deleted.
+// An empty directory disables GitOps.
+func (inst *Instance) WithGitops(dir string, interval time.Duration, prune bool) *Instance {
+	inst.gitopsDir = dir
+	inst.gitopsInterval = interval
+	inst.gitopsPrune = prune
+	return inst
+}
+
+// WithZoneFileExports sets the directory the zone file of each zone is kept up to date in, written atomically
+// once the changes of the zone settle for the delay. An empty directory disables the exports.
+func (inst *Instance) WithZoneFileExports(dir string, delay time.Duration) *Instance {
+	inst.exportDir = dir
+	inst.exportDelay = delay
+	return inst
+}
+
+// WithSeed adds records of a zone imported at startup, in master file format with names relative to the zone,
+// or the seed file of the zone, a zone file or an octoDNS YAML file. Zones which do not exist are created.
+func (inst *Instance) WithSeed(zone string, records []string, file string) *Instance {
+	inst.seeds = append(inst.seeds, &zoneSeed{zone: strings.ToLower(dns.Fqdn(zone)), records: records, file: file})
+	return inst
+}
+
+// WithSeedOverwrite sets whether the seeds replace the existing RRsets differing from them,
+// seeded RRsets are only inserted if absent otherwise.
+func (inst *Instance) WithSeedOverwrite(overwrite bool) *Instance {
+	inst.seedOverwrite = overwrite
+	return inst
+}
+
 // initZonesCacheRefreshSchedule starts a background goroutine that periodically
 // refreshes the zones cache at the specified interval.
 func (inst *Instance) initZonesCacheRefreshSchedule() {
@@ -145,13 +258,20 @@ func (inst *Instance) Start() error {
 				return err
 			}
 			inst.initZonesCacheRefreshSchedule()
+			inst.seedZones()
+			inst.bindAcmeDnsRoutes(e)
+			inst.bindDynDnsRoutes(e)
+			inst.bindExternalDnsRoutes(e)
+			inst.bindApiTokenAuth(e)
+			inst.bindApiRoutes(e)
+			inst.initGitopsSchedule()
+			inst.initZoneFileExports()
 			close(inst.readyChan)
 			return e.Next()
 		},
 	})
 
-	// after altering records, emit event
-	inst.bindRecordAlteringEvent()
+	inst.bindEvents()
 
 	log.Info("Bootstrapping PocketBase instance...")
 	err := inst.pb.Bootstrap()
@@ -167,6 +287,33 @@ func (inst *Instance) Start() error {
 	return nil
 }
 
+// bindEvents binds the record events keeping the data consistent, for serving and for commands alike.
+func (inst *Instance) bindEvents() {
+	// after altering records, emit event
+	inst.bindRecordAlteringEvent()
+	// link records to their zones and bump the zone serials
+	inst.bindZoneEvents()
+	inst.bindDynDnsHostEvents()
+	// validate records written through the API
+	inst.bindRecordValidators()
+	// reject record changes violating the zone integrity
+	inst.bindZoneIntegrityChecks()
+	// keep the history of record changes
+	inst.bindRecordHistory()
+	// keep staged change sets in draft until published
+	inst.bindChangeSetEvents()
+	// keep the zones of the tenants within their members and quotas
+	inst.bindTenantEvents()
+	// keep the requests authenticated with API tokens within the scopes of the tokens
+	inst.bindApiTokenEvents()
+	// keep the records managed by the GitOps directory read-only
+	inst.bindGitopsEvents()
+	// keep the exported zone files up to date
+	inst.bindZoneFileExportEvents()
+	// keep the cached template records up to date
+	inst.bindTemplateEvents()
+}
+
 func (inst *Instance) bindRecordAlteringEvent() {
 	log.Debug("Bind record altering event...")
 
@@ -176,25 +323,11 @@ func (inst *Instance) bindRecordAlteringEvent() {
 	}
 
 	cachePopFunc := func(e *core.RecordEvent) error {
-		zone := e.Record.GetString("zone")
-		name := e.Record.GetString("name")
-		typ := e.Record.GetString("record_type")
-
-		cacheKey := fmt.Sprintf(RecordsCacheKeyFormat, zone, name, typ)
-		log.Debugf("Deleting record cache, key: %s", cacheKey)
-		inst.recordsCache.Delete(cacheKey)
-
-		// remove special cache key used in query
-		if typ == "A" || typ == "AAAA" || typ == "CNAME" {
-			cacheKey = fmt.Sprintf(RecordsCacheKeyFormat, zone, name,
-				fmt.Sprintf(RecordsCacheKeyFormat, zone, name, strings.Join([]string{"A", "AAAA", "CNAME"}, ",")))
-			log.Debugf("Deleting record cache, key: %s", cacheKey)
-			inst.recordsCache.Delete(cacheKey)
+		// batches invalidate the caches once they are applied
+		if !cacheInvalidationDeferred(e.Context) {
+			inst.invalidateRecordCaches(e.Record.GetString("zone"), e.Record.GetString("name"), e.Record.GetString("record_type"))
+			inst.invalidateZoneCaches(e.Record.GetString("zone"))
 		}
-
-		log.Debug("Deleting zones cache...")
-		inst.zonesCache.Delete(ZonesCacheKey)
-
 		return e.Next()
 	}
 
@@ -203,6 +336,34 @@ func (inst *Instance) bindRecordAlteringEvent() {
 	inst.pb.OnRecordAfterDeleteSuccess(recordCollectionName).BindFunc(cachePopFunc)
 }
 
+// invalidateRecordCaches deletes the cached records of the RRset.
+func (inst *Instance) invalidateRecordCaches(zone string, name string, typ string) {
+	if inst.cacheCapacity <= 0 {
+		return
+	}
+	cacheKey := fmt.Sprintf(RecordsCacheKeyFormat, zone, name, typ)
+	log.Debugf("Deleting record cache, key: %s", cacheKey)
+	inst.recordsCache.Delete(cacheKey)
+
+	// remove special cache key used in query
+	if typ == "A" || typ == "AAAA" || typ == "CNAME" {
+		cacheKey = fmt.Sprintf(RecordsCacheKeyFormat, zone, name,
+			fmt.Sprintf(RecordsCacheKeyFormat, zone, name, strings.Join([]string{"A", "AAAA", "CNAME"}, ",")))
+		log.Debugf("Deleting record cache, key: %s", cacheKey)
+		inst.recordsCache.Delete(cacheKey)
+	}
+}
+
+// invalidateZoneCaches deletes the cached zones and the cached settings of the zone, whose serial has been bumped.
+func (inst *Instance) invalidateZoneCaches(zone string) {
+	if inst.cacheCapacity <= 0 {
+		return
+	}
+	log.Debug("Deleting zones cache...")
+	inst.zonesCache.Delete(ZonesCacheKey)
+	inst.zoneSettingsCache.Delete(zone)
+}
+
 // initTheOnlySuperuser ensures there is exactly one superuser with the specified credentials.
 // It will create a new superuser if none exists, or update the password if the user exists.
 // All other superusers will be deleted to maintain a single superuser configuration.
@@ -1,10 +1,14 @@
 package pocketbase
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/pocketbase/pocketbase/core"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -72,3 +76,36 @@ func TestToAbsPath(t *testing.T) {
 	result = toAbsPath(relPath)
 	assert.Equal(t, expectedPath, result)
 }
+
+// newMigratedInstance returns an instance with its events bound and its database migrated in a temporary directory,
+// without serving.
+func newMigratedInstance(t *testing.T) *Instance {
+	t.Helper()
+	inst := NewWithDataDir(t.TempDir()).WithDefaultTtl(30).WithCacheCapacity(0)
+	inst.bindEvents()
+	require.NoError(t, inst.pb.Bootstrap())
+	t.Cleanup(func() {
+		_ = inst.pb.ResetBootstrapState()
+	})
+	require.NoError(t, inst.pb.RunAllMigrations())
+	return inst
+}
+
+// importTestZone creates the zone with the records of the zone file.
+func importTestZone(t *testing.T, inst *Instance, zone string, zoneFile string) {
+	t.Helper()
+	rrs, err := parseZoneFile(strings.NewReader(zoneFile), zone, "", false)
+	require.NoError(t, err)
+	_, err = inst.importZoneFile(WithActor(context.Background(), cliActor), zone, rrs, zoneImportOptions{CreateZone: true})
+	require.NoError(t, err)
+}
+
+// superuserEvent returns a request event authenticated as a superuser.
+func superuserEvent(t *testing.T, inst *Instance) *core.RequestEvent {
+	t.Helper()
+	coll, err := inst.pb.FindCollectionByNameOrId(core.CollectionNameSuperusers)
+	require.NoError(t, err)
+	e := &core.RequestEvent{App: inst.pb}
+	e.Auth = core.NewRecord(coll)
+	return e
+}
@@ -0,0 +1,285 @@
+package pocketbase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/miekg/dns"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+type integrityCtxKey struct{}
+
+// WithoutIntegrityChecks returns a context which disables the zone integrity checks
+// of saves and deletes made with it, e.g. for migrations of inconsistent data.
+func WithoutIntegrityChecks(ctx context.Context) context.Context {
+	return context.WithValue(ctx, integrityCtxKey{}, true)
+}
+
+// integrityChecksDisabled reports whether the context disables the zone integrity checks.
+func integrityChecksDisabled(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	disabled, _ := ctx.Value(integrityCtxKey{}).(bool)
+	return disabled
+}
+
+// bindZoneIntegrityChecks checks every create, update and delete of the records collection
+// against the zone integrity rules within the transaction of the change, rolling back violating changes.
+func (inst *Instance) bindZoneIntegrityChecks() {
+	if inst.integrityOverride {
+		log.Warning("Zone integrity checks are overridden, records are saved without checking")
+		return
+	}
+
+	checkFunc := func(e *core.RecordEvent) error {
+		if integrityChecksDisabled(e.Context) {
+			return e.Next()
+		}
+		original := e.Record.Original()
+		return e.App.RunInTransaction(func(txApp core.App) error {
+			e.App = txApp
+			if err := e.Next(); err != nil {
+				return err
+			}
+			if err := inst.checkRecordIntegrity(txApp, e.Record.GetString("zone"), e.Record.GetString("name")); err != nil {
+				return err
+			}
+			if e.Type == core.ModelEventTypeUpdate && original != nil {
+				return inst.checkRecordIntegrity(txApp, original.GetString("zone"), original.GetString("name"))
+			}
+			return nil
+		})
+	}
+	inst.pb.OnRecordCreate(recordCollectionName).BindFunc(checkFunc)
+	inst.pb.OnRecordUpdate(recordCollectionName).BindFunc(checkFunc)
+	inst.pb.OnRecordDelete(recordCollectionName).BindFunc(checkFunc)
+}
+
+// checkRecordIntegrity checks the enabled records at name and the SOA records of the zone,
+// it does nothing if the integrity checks are overridden.
+func (inst *Instance) checkRecordIntegrity(app core.App, zone string, name string) error {
+	if inst.integrityOverride {
+		return nil
+	}
+	var nameRecs []*m.Record
+	err := app.RecordQuery(recordCollectionName).
+		Select("name", "zone", "ttl", "record_type", "content").
+		Where(dbx.HashExp{"zone": zone, "name": name, "disabled": false}).
+		All(&nameRecs)
+	if err != nil {
+		return err
+	}
+	if err = checkNameIntegrity(zone, name, nameRecs); err != nil {
+		return err
+	}
+	if err = inst.checkRecordTemplateIntegrity(app, zone, nameRecs); err != nil {
+		return err
+	}
+
+	var soaRecs []*m.Record
+	err = app.RecordQuery(recordCollectionName).
+		Select("name", "zone", "ttl", "record_type", "content").
+		Where(dbx.HashExp{"zone": zone, "record_type": "SOA", "disabled": false}).
+		All(&soaRecs)
+	if err != nil {
+		return err
+	}
+	return checkSoaIntegrity(zone, soaRecs)
+}
+
+// checkZoneIntegrity checks all enabled records of the zone, e.g. after replacing the records of the zone,
+// it does nothing if the integrity checks are overridden.
+func (inst *Instance) checkZoneIntegrity(app core.App, zone string) error {
+	if inst.integrityOverride {
+		return nil
+	}
+	var recs []*m.Record
+	err := app.RecordQuery(recordCollectionName).
+		Select("name", "zone", "ttl", "record_type", "content").
+		Where(dbx.HashExp{"zone": zone, "disabled": false}).
+		OrderBy("name").
+		All(&recs)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	byName := make(map[string][]*m.Record)
+	var soaRecs []*m.Record
+	for _, rec := range recs {
+		if _, ok := byName[rec.Name]; !ok {
+			names = append(names, rec.Name)
+		}
+		byName[rec.Name] = append(byName[rec.Name], rec)
+		if rec.RecordType == "SOA" {
+			soaRecs = append(soaRecs, rec)
+		}
+	}
+	for _, name := range names {
+		if err = checkNameIntegrity(zone, name, byName[name]); err != nil {
+			return err
+		}
+	}
+	if err = checkTemplateIntegrity(recs, true); err != nil {
+		return err
+	}
+	return checkSoaIntegrity(zone, soaRecs)
+}
+
+// checkRecordTemplateIntegrity checks the records at a name against the template records of the zone. The template
+// records at the name, i.e. a saved template record, are checked against all records of the zone.
+func (inst *Instance) checkRecordTemplateIntegrity(app core.App, zone string, nameRecs []*m.Record) error {
+	var recs []*m.Record
+	err := app.RecordQuery(recordCollectionName).
+		Select("name", "zone", "ttl", "record_type", "content").
+		Where(dbx.HashExp{"zone": zone, "record_type": m.TemplateRecordType, "disabled": false}).
+		All(&recs)
+	if err != nil || len(recs) == 0 {
+		return err
+	}
+	if !slices.ContainsFunc(nameRecs, func(rec *m.Record) bool { return rec.RecordType == m.TemplateRecordType }) {
+		return checkTemplateIntegrity(append(recs, nameRecs...), false)
+	}
+	var others []*m.Record
+	err = app.RecordQuery(recordCollectionName).
+		Select("name", "zone", "ttl", "record_type", "content").
+		Where(dbx.HashExp{"zone": zone, "disabled": false}).
+		AndWhere(dbx.Not(dbx.HashExp{"record_type": m.TemplateRecordType})).
+		All(&others)
+	if err != nil {
+		return err
+	}
+	return checkTemplateIntegrity(append(recs, others...), true)
+}
+
+// checkNameIntegrity checks the records sharing a name:
+//   - the name must be within the zone,
+//   - a CNAME must not coexist with other records at the same name (RFC 1034 3.6.2, RFC 2181 10.1),
+//   - the records of a RRset must have the same TTL (RFC 2181 5.2).
+//
+// Template records are named after the pattern of the names they generate, which only has to be within the zone.
+func checkNameIntegrity(zone string, name string, recs []*m.Record) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	if !dns.IsSubDomain(strings.ToLower(zone), strings.ToLower(name)) {
+		return integrityError("name", "name %s is outside of zone %s", name, zone)
+	}
+	recs = slices.DeleteFunc(slices.Clone(recs), func(rec *m.Record) bool {
+		return rec.RecordType == m.TemplateRecordType
+	})
+
+	ttls := make(map[string]uint32)
+	cnames := 0
+	for _, rec := range recs {
+		if rec.RecordType == "CNAME" {
+			cnames++
+		}
+		if ttl, ok := ttls[rec.RecordType]; ok && ttl != rec.Ttl {
+			return integrityError("ttl", "records of the %s RRset at %s have different TTLs (%d and %d)",
+				rec.RecordType, name, ttl, rec.Ttl)
+		}
+		ttls[rec.RecordType] = rec.Ttl
+	}
+	if cnames > 1 {
+		return integrityError("record_type", "name %s has more than one CNAME record", name)
+	}
+	if cnames == 1 && len(recs) > 1 {
+		return integrityError("record_type", "name %s has a CNAME record, no other records may exist at the same name", name)
+	}
+	return nil
+}
+
+// checkTemplateIntegrity checks the names generated by the template records against the other records, as the
+// generated records are served and exported along with them: a generated CNAME must not share its name with records of
+// other types (RFC 1034 3.6.2), nor may records be generated at the name of a CNAME. Generated records are hidden by
+// the records of their name and type only. The templates are checked against each other too if pairs is set.
+func checkTemplateIntegrity(recs []*m.Record, pairs bool) error {
+	type template struct {
+		rec *m.Record
+		t   m.TEMPLATERecord
+	}
+	var templates []template
+	types := make(map[string][]string)
+	for _, rec := range recs {
+		if rec.RecordType != m.TemplateRecordType {
+			name := strings.ToLower(rec.Name)
+			if !slices.Contains(types[name], rec.RecordType) {
+				types[name] = append(types[name], rec.RecordType)
+			}
+			continue
+		}
+		tmpl := template{rec: rec}
+		if err := json.Unmarshal([]byte(rec.Content), &tmpl.t); err != nil {
+			return err
+		}
+		templates = append(templates, tmpl)
+	}
+	conflicts := func(recordTypes []string, generated string) bool {
+		if generated == "CNAME" {
+			return slices.ContainsFunc(recordTypes, func(recordType string) bool { return recordType != "CNAME" })
+		}
+		return slices.Contains(recordTypes, "CNAME")
+	}
+
+	for name, recordTypes := range types {
+		for _, tmpl := range templates {
+			if _, ok := m.MatchTemplateName(tmpl.rec.Name, &tmpl.t, name); ok && conflicts(recordTypes, tmpl.t.RecordType) {
+				return integrityError("name", "template %s generates %s records at %s, which has %s records, "+
+					"a CNAME record may not share its name with other records", tmpl.rec.Name, tmpl.t.RecordType, name,
+					strings.Join(recordTypes, ", "))
+			}
+		}
+	}
+	if !pairs {
+		return nil
+	}
+	for i, a := range templates {
+		if a.t.RecordType != "CNAME" {
+			continue
+		}
+		for j, b := range templates {
+			if i == j {
+				continue
+			}
+			for k, n := 0, a.t.Len(); k < n; k++ {
+				name, err := m.ExpandTemplate(strings.ToLower(a.rec.Name), a.t.Value(k))
+				if err != nil {
+					return err
+				}
+				if _, ok := m.MatchTemplateName(b.rec.Name, &b.t, name); ok {
+					return integrityError("name", "templates %s and %s generate CNAME and %s records at %s, "+
+						"a CNAME record may not share its name with other records", a.rec.Name, b.rec.Name, b.t.RecordType, name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkSoaIntegrity checks that a zone has at most one SOA record, at the zone apex.
+func checkSoaIntegrity(zone string, soaRecs []*m.Record) error {
+	if len(soaRecs) > 1 {
+		return integrityError("record_type", "zone %s has more than one SOA record", zone)
+	}
+	for _, rec := range soaRecs {
+		if !strings.EqualFold(rec.Name, zone) {
+			return integrityError("name", "SOA record must be at the zone apex %s, not at %s", zone, rec.Name)
+		}
+	}
+	return nil
+}
+
+// integrityError reports a violation of the zone integrity rules as a field error.
+func integrityError(field string, format string, args ...any) error {
+	return validation.Errors{field: validation.NewError("validation_zone_integrity", fmt.Sprintf(format, args...))}
+}
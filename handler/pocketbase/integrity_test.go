@@ -0,0 +1,102 @@
+package pocketbase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+func TestCheckNameIntegrity(t *testing.T) {
+	rec := func(name string, recordType string, ttl uint32) *m.Record {
+		return &m.Record{Zone: "example.com.", Name: name, RecordType: recordType, Ttl: ttl}
+	}
+	tests := []struct {
+		name    string
+		recName string
+		recs    []*m.Record
+		wantErr bool
+	}{
+		{name: "no records", recName: "www.example.com.", recs: nil},
+		{name: "rrsets with equal ttls", recName: "www.example.com.", recs: []*m.Record{
+			rec("www.example.com.", "A", 300), rec("www.example.com.", "A", 300), rec("www.example.com.", "AAAA", 60)}},
+		{name: "single cname", recName: "www.example.com.", recs: []*m.Record{rec("www.example.com.", "CNAME", 300)}},
+		{name: "name outside zone", recName: "www.example.org.", recs: []*m.Record{rec("www.example.org.", "A", 300)},
+			wantErr: true},
+		{name: "rrset with different ttls", recName: "www.example.com.", recs: []*m.Record{
+			rec("www.example.com.", "A", 300), rec("www.example.com.", "A", 60)}, wantErr: true},
+		{name: "cname with other type", recName: "www.example.com.", recs: []*m.Record{
+			rec("www.example.com.", "CNAME", 300), rec("www.example.com.", "TXT", 300)}, wantErr: true},
+		{name: "two cnames", recName: "www.example.com.", recs: []*m.Record{
+			rec("www.example.com.", "CNAME", 300), rec("www.example.com.", "CNAME", 300)}, wantErr: true},
+		{name: "templates with different ttls", recName: "host-$.example.com.", recs: []*m.Record{
+			rec("host-$.example.com.", "TEMPLATE", 300), rec("host-$.example.com.", "TEMPLATE", 60)}},
+		{name: "template outside zone", recName: "host-$.example.org.", recs: []*m.Record{
+			rec("host-$.example.org.", "TEMPLATE", 300)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkNameIntegrity("example.com.", tt.recName, tt.recs)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCheckTemplateIntegrity(t *testing.T) {
+	rec := func(name string, recordType string, content string) *m.Record {
+		return &m.Record{Zone: "example.com.", Name: name, RecordType: recordType, Content: content}
+	}
+	aTemplate := rec("host-$.example.com.", "TEMPLATE", `{"record_type":"A","start":1,"stop":10,"template":"10.0.0.$"}`)
+	cnameTemplate := rec("host-$.example.com.", "TEMPLATE", `{"record_type":"CNAME","start":5,"stop":20,"template":"node-$"}`)
+	tests := []struct {
+		name    string
+		recs    []*m.Record
+		pairs   bool
+		wantErr bool
+	}{
+		{name: "no templates", recs: []*m.Record{rec("www.example.com.", "CNAME", "")}},
+		{name: "records outside the range", recs: []*m.Record{aTemplate, rec("host-11.example.com.", "CNAME", "")}},
+		{name: "records hidden by a record", recs: []*m.Record{aTemplate, rec("host-1.example.com.", "A", "")}},
+		{name: "records at a CNAME", recs: []*m.Record{aTemplate, rec("HOST-3.example.com.", "CNAME", "")},
+			wantErr: true},
+		{name: "CNAME hidden by a CNAME", recs: []*m.Record{cnameTemplate, rec("host-5.example.com.", "CNAME", "")}},
+		{name: "CNAME at other records", recs: []*m.Record{cnameTemplate, rec("host-20.example.com.", "TXT", "")},
+			wantErr: true},
+		{name: "templates not checked against each other", recs: []*m.Record{aTemplate, cnameTemplate}},
+		{name: "overlapping templates", recs: []*m.Record{aTemplate, cnameTemplate}, pairs: true, wantErr: true},
+		{name: "disjoint templates", pairs: true, recs: []*m.Record{aTemplate,
+			rec("alias-$.example.com.", "TEMPLATE", `{"record_type":"CNAME","start":1,"stop":10,"template":"host-$"}`)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTemplateIntegrity(tt.recs, tt.pairs)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCheckSoaIntegrity(t *testing.T) {
+	apex := &m.Record{Zone: "example.com.", Name: "example.com.", RecordType: "SOA"}
+	sub := &m.Record{Zone: "example.com.", Name: "sub.example.com.", RecordType: "SOA"}
+
+	assert.NoError(t, checkSoaIntegrity("example.com.", nil))
+	assert.NoError(t, checkSoaIntegrity("example.com.", []*m.Record{apex}))
+	assert.Error(t, checkSoaIntegrity("example.com.", []*m.Record{sub}))
+	assert.Error(t, checkSoaIntegrity("example.com.", []*m.Record{apex, apex}))
+}
+
+func TestIntegrityChecksDisabled(t *testing.T) {
+	assert.False(t, integrityChecksDisabled(context.Background()))
+	assert.True(t, integrityChecksDisabled(WithoutIntegrityChecks(context.Background())))
+}
@@ -0,0 +1,520 @@
+package pocketbase
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pocketbase/dbx"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+// Formats of the legacy backends the records can be imported from.
+const (
+	// LegacyPdnsSqlite is the database file of the PowerDNS generic SQLite backend, as used by coredns-pdsql.
+	LegacyPdnsSqlite = "pdns-sqlite"
+	// LegacyCorednsMysql is a SQL dump of the coredns_records table of coredns_mysql.
+	LegacyCorednsMysql = "coredns-mysql"
+	// LegacyRedisJson is a JSON dump of the hashes of the arvancloud/redis plugin, by key and field.
+	LegacyRedisJson = "redis-json"
+)
+
+// LegacyFormats lists the formats of the legacy backends.
+var LegacyFormats = []string{LegacyPdnsSqlite, LegacyCorednsMysql, LegacyRedisJson}
+
+// corednsMysqlColumns are the columns of the coredns_records table of coredns_mysql,
+// for dumps without table definition or column list.
+var corednsMysqlColumns = []string{"id", "zone", "name", "ttl", "content", "record_type"}
+
+// redisRecordTypes maps the keys of the records of the arvancloud/redis plugin to their record types.
+var redisRecordTypes = map[string]string{
+	"a": "A", "aaaa": "AAAA", "cname": "CNAME", "txt": "TXT", "ns": "NS", "mx": "MX", "srv": "SRV", "soa": "SOA", "caa": "CAA",
+}
+
+// pdnsPrioFields are the numbers of fields of the contents of the record types whose priority PowerDNS keeps
+// in the prio column, contents with fewer fields lack the priority.
+var pdnsPrioFields = map[string]int{"MX": 2, "SRV": 4}
+
+// legacyZones are the records read from a legacy backend by zone, in the order the zones are read,
+// with the records which could not be converted.
+type legacyZones struct {
+	order  []string
+	rrs    map[string][]dns.RR
+	issues map[string][]*zoneImportIssue
+}
+
+// legacyImportReport maps a legacy backend to the import reports of its zones.
+type legacyImportReport struct {
+	Format string              `json:"format"`
+	DryRun bool                `json:"dry_run"`
+	Zones  []*zoneImportReport `json:"zones"`
+}
+
+func newLegacyZones() *legacyZones {
+	return &legacyZones{rrs: make(map[string][]dns.RR), issues: make(map[string][]*zoneImportIssue)}
+}
+
+// zone adds the zone, if not yet read, and returns its normalized name.
+func (lz *legacyZones) zone(zone string) string {
+	zone = strings.ToLower(dns.Fqdn(zone))
+	if !slices.Contains(lz.order, zone) {
+		lz.order = append(lz.order, zone)
+	}
+	return zone
+}
+
+// add adds a converted record to the zone.
+func (lz *legacyZones) add(zone string, rr dns.RR) {
+	zone = lz.zone(zone)
+	lz.rrs[zone] = append(lz.rrs[zone], rr)
+}
+
+// skip reports a record of the zone which could not be converted.
+func (lz *legacyZones) skip(zone string, name string, recordType string, reason string) {
+	zone = lz.zone(zone)
+	lz.issues[zone] = append(lz.issues[zone], &zoneImportIssue{Name: name, Type: recordType, Reason: reason})
+}
+
+// importLegacyZones imports the zones read from a legacy backend one by one, each zone within its own transaction,
+// creating the zones which do not exist yet. It returns errZoneImportFailed once all zones are tried
+// if the import of any zone failed, the reports of the zones telling why.
+func (inst *Instance) importLegacyZones(ctx context.Context, format string, lz *legacyZones, opts zoneImportOptions) (*legacyImportReport, error) {
+	report := &legacyImportReport{Format: format, DryRun: opts.DryRun, Zones: make([]*zoneImportReport, 0, len(lz.order))}
+	var failed error
+	for _, zone := range lz.order {
+		zoneReport, err := inst.importZoneFile(ctx, zone, lz.rrs[zone], opts)
+		if zoneReport != nil {
+			zoneReport.Unsupported = append(lz.issues[zone], zoneReport.Unsupported...)
+			report.Zones = append(report.Zones, zoneReport)
+		}
+		switch {
+		case errors.Is(err, errZoneImportFailed):
+			failed = err
+		case err != nil:
+			return report, fmt.Errorf("zone %s: %w", zone, err)
+		}
+	}
+	return report, failed
+}
+
+// readLegacyZones reads the zones of a legacy backend in the format from the source.
+// The database file of the PowerDNS SQLite backend is read from its path, the dumps from the reader.
+func readLegacyZones(format string, path string, r io.Reader) (*legacyZones, error) {
+	switch format {
+	case LegacyPdnsSqlite:
+		return readPdnsSqlite(path)
+	case LegacyCorednsMysql:
+		return readCorednsMysqlDump(r)
+	case LegacyRedisJson:
+		return readRedisJsonDump(r)
+	default:
+		return nil, fmt.Errorf("unknown legacy format %q, expected one of %v", format, LegacyFormats)
+	}
+}
+
+// pdnsRecord is a row of the records table of the PowerDNS generic SQL backends.
+type pdnsRecord struct {
+	Zone     string        `db:"zone"`
+	Name     string        `db:"name"`
+	Type     string        `db:"type"`
+	Content  string        `db:"content"`
+	Ttl      sql.NullInt64 `db:"ttl"`
+	Prio     sql.NullInt64 `db:"prio"`
+	Disabled sql.NullBool  `db:"disabled"`
+}
+
+// readPdnsSqlite reads the zones of a database file of the PowerDNS generic SQLite backend, opened read-only.
+// Disabled records are skipped, the priority of MX and SRV records is taken from the prio column.
+func readPdnsSqlite(path string) (*legacyZones, error) {
+	db, err := dbx.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var domains []string
+	if err = db.NewQuery("SELECT name FROM domains ORDER BY id").Column(&domains); err != nil {
+		return nil, err
+	}
+	var rows []*pdnsRecord
+	err = db.NewQuery("SELECT d.name AS zone, r.name, r.type, r.content, r.ttl, r.prio, r.disabled " +
+		"FROM records r JOIN domains d ON d.id = r.domain_id WHERE r.type IS NOT NULL AND r.type != '' ORDER BY d.id, r.id").
+		All(&rows)
+	if err != nil {
+		return nil, err
+	}
+
+	lz := newLegacyZones()
+	for _, domain := range domains {
+		lz.zone(domain)
+	}
+	for _, row := range rows {
+		name := strings.ToLower(dns.Fqdn(row.Name))
+		recordType := strings.ToUpper(row.Type)
+		if row.Disabled.Valid && row.Disabled.Bool {
+			lz.skip(row.Zone, name, recordType, "the record is disabled in the source")
+			continue
+		}
+		content := row.Content
+		if fields, ok := pdnsPrioFields[recordType]; ok && row.Prio.Valid && len(strings.Fields(content)) < fields {
+			content = fmt.Sprintf("%d %s", row.Prio.Int64, content)
+		}
+		rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", name, row.Ttl.Int64, recordType, content))
+		if err != nil || rr == nil {
+			lz.skip(row.Zone, name, recordType, fmt.Sprintf("the record content %q can not be converted", row.Content))
+			continue
+		}
+		lz.add(row.Zone, rr)
+	}
+	return lz, nil
+}
+
+var (
+	sqlCreateTablePattern = regexp.MustCompile("(?is)^CREATE\\s+TABLE\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?`?(\\w+)`?\\s*\\((.*)\\)")
+	sqlInsertPattern      = regexp.MustCompile("(?is)^INSERT\\s+(?:IGNORE\\s+)?INTO\\s+`?(\\w+)`?\\s*(?:\\(([^)]*)\\))?\\s*VALUES\\s*")
+)
+
+// readCorednsMysqlDump reads the zones of a SQL dump of the coredns_records table of coredns_mysql,
+// as written by mysqldump. The contents of the records are in the JSON format of coredns_records.
+func readCorednsMysqlDump(r io.Reader) (*legacyZones, error) {
+	dump, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	columns := corednsMysqlColumns
+	lz := newLegacyZones()
+	for _, stmt := range splitSqlStatements(string(dump)) {
+		if match := sqlCreateTablePattern.FindStringSubmatch(stmt); match != nil && strings.EqualFold(match[1], recordCollectionName) {
+			columns = sqlColumnNames(match[2])
+			continue
+		}
+		match := sqlInsertPattern.FindStringSubmatch(stmt)
+		if match == nil || !strings.EqualFold(match[1], recordCollectionName) {
+			continue
+		}
+		insertColumns := columns
+		if match[2] != "" {
+			insertColumns = sqlColumnNames(match[2])
+		}
+		rows, err := parseSqlValues(stmt[len(match[0]):])
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			addCorednsMysqlRow(lz, sqlRowMap(insertColumns, row))
+		}
+	}
+	return lz, nil
+}
+
+// addCorednsMysqlRow converts a row of the coredns_records table of coredns_mysql.
+// Names are relative to the zone, empty or @ for the zone apex, unless fully qualified.
+func addCorednsMysqlRow(lz *legacyZones, row map[string]string) {
+	zone := strings.ToLower(dns.Fqdn(row["zone"]))
+	name := strings.ToLower(row["name"])
+	switch {
+	case name == "" || name == "@":
+		name = zone
+	case !dns.IsFqdn(name):
+		name = name + "." + zone
+	}
+	recordType := strings.ToUpper(row["record_type"])
+	if !m.IsSupportedRecordType(recordType) {
+		lz.skip(zone, name, recordType, "the record type is not supported")
+		return
+	}
+	var ttl uint32
+	_, _ = fmt.Sscan(row["ttl"], &ttl)
+	rr, err := m.RRFromContent(name, ttl, recordType, row["content"])
+	if err != nil {
+		lz.skip(zone, name, recordType, err.Error())
+		return
+	}
+	lz.add(zone, rr)
+}
+
+// readRedisJsonDump reads the zones of a JSON dump of the arvancloud/redis plugin, an object with the hashes
+// of the zones by key, each hash an object with the records by field. Keys are the zone names, fields the names
+// relative to the zone or @ for the apex, and values the records of the name in the JSON format of the plugin,
+// either as objects or as the strings stored in redis.
+func readRedisJsonDump(r io.Reader) (*legacyZones, error) {
+	var dump map[string]map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&dump); err != nil {
+		return nil, fmt.Errorf("invalid redis JSON dump: %w", err)
+	}
+	keys := make([]string, 0, len(dump))
+	for key := range dump {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	lz := newLegacyZones()
+	for _, key := range keys {
+		zone := lz.zone(key)
+		fields := make([]string, 0, len(dump[key]))
+		for field := range dump[key] {
+			fields = append(fields, field)
+		}
+		slices.Sort(fields)
+		for _, field := range fields {
+			name := strings.ToLower(field)
+			switch {
+			case name == "@":
+				name = zone
+			case !dns.IsFqdn(name):
+				name = name + "." + zone
+			}
+			addRedisRecords(lz, zone, name, dump[key][field])
+		}
+	}
+	return lz, nil
+}
+
+// addRedisRecords converts the records of a name stored by the arvancloud/redis plugin,
+// an object with the records of each type by their lower-case type.
+func addRedisRecords(lz *legacyZones, zone string, name string, value json.RawMessage) {
+	var stored string
+	if err := json.Unmarshal(value, &stored); err == nil {
+		value = json.RawMessage(stored)
+	}
+	var byType map[string]json.RawMessage
+	if err := json.Unmarshal(value, &byType); err != nil {
+		lz.skip(zone, name, "", "invalid records: "+err.Error())
+		return
+	}
+	keys := make([]string, 0, len(byType))
+	for key := range byType {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		recordType, ok := redisRecordTypes[strings.ToLower(key)]
+		if !ok {
+			lz.skip(zone, name, strings.ToUpper(key), "the record type is not supported")
+			continue
+		}
+		items := []json.RawMessage{byType[key]}
+		if trimmed := bytes.TrimSpace(byType[key]); len(trimmed) > 0 && trimmed[0] == '[' {
+			if err := json.Unmarshal(trimmed, &items); err != nil {
+				lz.skip(zone, name, recordType, "invalid records: "+err.Error())
+				continue
+			}
+		}
+		for _, item := range items {
+			var ttl struct {
+				Ttl uint32 `json:"ttl"`
+			}
+			_ = json.Unmarshal(item, &ttl)
+			rr, err := m.RRFromContent(name, ttl.Ttl, recordType, string(item))
+			if err != nil {
+				lz.skip(zone, name, recordType, err.Error())
+				continue
+			}
+			lz.add(zone, rr)
+		}
+	}
+}
+
+// splitSqlStatements splits a SQL dump into its statements, dropping the comments.
+func splitSqlStatements(dump string) []string {
+	stmts := make([]string, 0)
+	var sb strings.Builder
+	var quote byte
+	for i := 0; i < len(dump); i++ {
+		c := dump[i]
+		switch {
+		case quote != 0:
+			sb.WriteByte(c)
+			if c == '\\' && i+1 < len(dump) {
+				i++
+				sb.WriteByte(dump[i])
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			sb.WriteByte(c)
+		case c == '#' || c == '-' && strings.HasPrefix(dump[i:], "-- "):
+			for i < len(dump) && dump[i] != '\n' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(dump[i:], "/*"):
+			end := strings.Index(dump[i+2:], "*/")
+			if end < 0 {
+				i = len(dump)
+			} else {
+				i += end + 3
+			}
+		case c == ';':
+			if stmt := strings.TrimSpace(sb.String()); stmt != "" {
+				stmts = append(stmts, stmt)
+			}
+			sb.Reset()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	if stmt := strings.TrimSpace(sb.String()); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+// sqlColumnNames returns the column names of a column list or of the definitions of a CREATE TABLE statement.
+func sqlColumnNames(defs string) []string {
+	columns := make([]string, 0)
+	depth, start := 0, 0
+	for i := 0; i <= len(defs); i++ {
+		if i < len(defs) {
+			switch defs[i] {
+			case '(':
+				depth++
+				continue
+			case ')':
+				depth--
+				continue
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		fields := strings.Fields(defs[start:i])
+		start = i + 1
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "PRIMARY", "KEY", "UNIQUE", "INDEX", "CONSTRAINT", "FULLTEXT", "SPATIAL", "CHECK", "FOREIGN":
+			continue
+		}
+		columns = append(columns, strings.Trim(fields[0], "`\""))
+	}
+	return columns
+}
+
+// parseSqlValues parses the tuples of values of an INSERT statement, NULL values are empty strings.
+func parseSqlValues(values string) ([][]string, error) {
+	rows := make([][]string, 0)
+	i := 0
+	skipSpace := func() {
+		for i < len(values) && strings.ContainsRune(" \t\r\n", rune(values[i])) {
+			i++
+		}
+	}
+	for {
+		skipSpace()
+		if i >= len(values) {
+			return rows, nil
+		}
+		if values[i] != '(' {
+			return nil, fmt.Errorf("invalid SQL values at %q", truncate(values[i:], 20))
+		}
+		i++
+		row := make([]string, 0)
+		for {
+			skipSpace()
+			value, n, err := parseSqlValue(values[i:])
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, value)
+			i += n
+			skipSpace()
+			if i >= len(values) {
+				return nil, errors.New("unterminated SQL values")
+			}
+			if values[i] == ')' {
+				i++
+				break
+			}
+			if values[i] != ',' {
+				return nil, fmt.Errorf("invalid SQL values at %q", truncate(values[i:], 20))
+			}
+			i++
+		}
+		rows = append(rows, row)
+		skipSpace()
+		if i < len(values) && values[i] == ',' {
+			i++
+		}
+	}
+}
+
+// parseSqlValue parses a quoted or bare SQL value at the start of s, returning it with the length it takes.
+func parseSqlValue(s string) (string, int, error) {
+	if s == "" {
+		return "", 0, errors.New("missing SQL value")
+	}
+	if s[0] != '\'' && s[0] != '"' {
+		end := strings.IndexAny(s, ",)")
+		if end < 0 {
+			return "", 0, errors.New("unterminated SQL values")
+		}
+		value := strings.TrimSpace(s[:end])
+		if strings.EqualFold(value, "NULL") {
+			value = ""
+		}
+		return value, end, nil
+	}
+	quote := s[0]
+	var sb strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case '0':
+				sb.WriteByte(0)
+			case 'Z':
+				sb.WriteByte(26)
+			default:
+				sb.WriteByte(s[i])
+			}
+		case c == quote && i+1 < len(s) && s[i+1] == quote:
+			i++
+			sb.WriteByte(c)
+		case c == quote:
+			return sb.String(), i + 1, nil
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", 0, errors.New("unterminated SQL string")
+}
+
+// sqlRowMap maps the values of a row to their columns.
+func sqlRowMap(columns []string, row []string) map[string]string {
+	values := make(map[string]string, len(columns))
+	for i, column := range columns {
+		if i < len(row) {
+			values[strings.ToLower(column)] = row[i]
+		}
+	}
+	return values
+}
+
+// truncate shortens s to at most n bytes.
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
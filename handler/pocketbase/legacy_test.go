@@ -0,0 +1,136 @@
+package pocketbase
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// legacyRRs returns the records of the zones in presentation format, with the skipped records.
+func legacyRRs(lz *legacyZones) (rrs []string, skipped []string) {
+	for _, zone := range lz.order {
+		for _, rr := range lz.rrs[zone] {
+			rrs = append(rrs, strings.ReplaceAll(rr.String(), "\t", " "))
+		}
+		for _, issue := range lz.issues[zone] {
+			skipped = append(skipped, issue.Name+" "+issue.Type)
+		}
+	}
+	return rrs, skipped
+}
+
+func TestReadPdnsSqlite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pdns.sqlite3")
+	db, err := dbx.Open("sqlite", path)
+	require.NoError(t, err)
+	for _, stmt := range []string{
+		"CREATE TABLE domains (id INTEGER PRIMARY KEY, name VARCHAR(255) NOT NULL, type VARCHAR(8) NOT NULL)",
+		"CREATE TABLE records (id INTEGER PRIMARY KEY, domain_id INTEGER, name VARCHAR(255), type VARCHAR(10), " +
+			"content VARCHAR(65535), ttl INTEGER, prio INTEGER, disabled BOOLEAN DEFAULT 0, ordername VARCHAR(255), auth BOOL DEFAULT 1)",
+		"INSERT INTO domains (id, name, type) VALUES (1, 'example.com', 'NATIVE'), (2, 'empty.org', 'NATIVE')",
+		"INSERT INTO records (domain_id, name, type, content, ttl, prio, disabled) VALUES " +
+			"(1, 'example.com', 'SOA', 'ns1.example.com hostmaster.example.com 1 10800 3600 604800 300', 3600, 0, 0), " +
+			"(1, 'example.com', 'MX', 'mail.example.com', 300, 10, 0), " +
+			"(1, '_sip._tcp.example.com', 'SRV', '20 5060 sip.example.com', 300, 10, 0), " +
+			"(1, 'www.example.com', 'A', '192.0.2.1', 300, NULL, 0), " +
+			"(1, 'old.example.com', 'A', '192.0.2.2', 300, NULL, 1), " +
+			"(1, 'txt.example.com', 'TXT', '\"hello world\"', 300, NULL, 0), " +
+			"(1, 'ent.example.com', NULL, NULL, NULL, NULL, 0), " +
+			"(1, 'alias.example.com', 'ALIAS', 'www.example.net', 300, NULL, 0)",
+	} {
+		_, err = db.NewQuery(stmt).Execute()
+		require.NoError(t, err)
+	}
+	require.NoError(t, db.Close())
+
+	lz, err := readPdnsSqlite(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.com.", "empty.org."}, lz.order)
+	rrs, skipped := legacyRRs(lz)
+	assert.Equal(t, []string{
+		"example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 10800 3600 604800 300",
+		"example.com. 300 IN MX 10 mail.example.com.",
+		"_sip._tcp.example.com. 300 IN SRV 10 20 5060 sip.example.com.",
+		"www.example.com. 300 IN A 192.0.2.1",
+		"txt.example.com. 300 IN TXT \"hello world\"",
+	}, rrs)
+	assert.Equal(t, []string{"old.example.com. A", "alias.example.com. ALIAS"}, skipped)
+}
+
+func TestReadCorednsMysqlDump(t *testing.T) {
+	dump := "-- MySQL dump 10.13\n" +
+		"/*!40101 SET NAMES utf8mb4 */;\n" +
+		"DROP TABLE IF EXISTS `coredns_records`;\n" +
+		"CREATE TABLE `coredns_records` (\n" +
+		"  `id` int(11) NOT NULL AUTO_INCREMENT,\n" +
+		"  `zone` varchar(255) NOT NULL,\n" +
+		"  `name` varchar(255) NOT NULL,\n" +
+		"  `ttl` int(11) DEFAULT NULL,\n" +
+		"  `content` text,\n" +
+		"  `record_type` varchar(255) NOT NULL,\n" +
+		"  `weight` decimal(10,2) DEFAULT NULL,\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n" +
+		"INSERT INTO `coredns_records` VALUES " +
+		"(1,'example.org.','foo',30,'{\\\"ip\\\": \\\"192.0.2.1\\\"}','A',NULL)," +
+		"(2,'example.org.','',60,'{\\\"host\\\": \\\"mail.example.org.\\\", \\\"preference\\\": 10}','MX',1.50)," +
+		"(3,'example.org.','@',60,'{\\\"text\\\": \\\"it''s; here\\\"}','TXT',NULL)," +
+		"(4,'example.org.','ptr',60,'{\\\"host\\\": \\\"x.\\\"}','PTR',NULL)," +
+		"(5,'example.org.','bad',60,'{\\\"ip\\\": ','A',NULL);\n" +
+		"INSERT INTO coredns_records (zone, name, ttl, content, record_type) VALUES " +
+		"('example.net', 'www.example.net.', 300, '{\"host\": \"foo.example.org\"}', 'CNAME');\n"
+
+	lz, err := readCorednsMysqlDump(strings.NewReader(dump))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.org.", "example.net."}, lz.order)
+	rrs, skipped := legacyRRs(lz)
+	assert.Equal(t, []string{
+		"foo.example.org. 30 IN A 192.0.2.1",
+		"example.org. 60 IN MX 10 mail.example.org.",
+		"example.org. 60 IN TXT \"it's; here\"",
+		"www.example.net. 300 IN CNAME foo.example.org.",
+	}, rrs)
+	assert.Equal(t, []string{"ptr.example.org. PTR", "bad.example.org. A"}, skipped)
+}
+
+func TestReadRedisJsonDump(t *testing.T) {
+	dump := `{
+		"example.net.": {
+			"@": {"soa": {"ttl": 300, "minttl": 100, "mbox": "hostmaster.example.net.", "ns": "ns1.example.net.", "refresh": 44, "retry": 55, "expire": 66},
+				"ns": [{"ttl": 300, "host": "ns1.example.net."}]},
+			"x": "{\"a\": [{\"ttl\": 300, \"ip\": \"192.0.2.1\"}, {\"ttl\": 300, \"ip\": \"192.0.2.2\"}], \"ptr\": [{\"host\": \"y.\"}]}",
+			"_ssh._tcp.host1": {"srv": [{"ttl": 300, "target": "tcp.example.com.", "port": 123, "priority": 10, "weight": 100}]}
+		}
+	}`
+
+	lz, err := readRedisJsonDump(strings.NewReader(dump))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.net."}, lz.order)
+	rrs, skipped := legacyRRs(lz)
+	assert.Equal(t, []string{
+		"example.net. 300 IN NS ns1.example.net.",
+		"example.net. 300 IN SOA ns1.example.net. hostmaster.example.net. 1 44 55 66 100",
+		"_ssh._tcp.host1.example.net. 300 IN SRV 10 100 123 tcp.example.com.",
+		"x.example.net. 300 IN A 192.0.2.1",
+		"x.example.net. 300 IN A 192.0.2.2",
+	}, rrs)
+	assert.Equal(t, []string{"x.example.net. PTR"}, skipped)
+
+	_, err = readRedisJsonDump(strings.NewReader(`[]`))
+	assert.Error(t, err)
+}
+
+func TestParseSqlValues(t *testing.T) {
+	rows, err := parseSqlValues(`(1,'a\'b','c''d',NULL, "e\\f\n" ), (2,'x,y)','')`)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"1", "a'b", "c'd", "", "e\\f\n"}, {"2", "x,y)", ""}}, rows)
+
+	_, err = parseSqlValues(`(1,'unterminated)`)
+	assert.Error(t, err)
+	_, err = parseSqlValues(`1, 2`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,144 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// SupportedRecordTypes lists the record types that can be stored and served.
+var SupportedRecordTypes = []string{"A", "AAAA", "CNAME", "SOA", "TXT", "NS", "MX", "CAA", "SRV", TemplateRecordType}
+
+// IsSupportedRecordType reports whether records of the given type can be stored and served.
+func IsSupportedRecordType(recordType string) bool {
+	for _, t := range SupportedRecordTypes {
+		if t == recordType {
+			return true
+		}
+	}
+	return false
+}
+
+// ContentFromRR converts a DNS resource record into its record type and content model.
+// The zone of CNAME targets is left empty as it depends on the zones being served.
+func ContentFromRR(rr dns.RR) (recordType string, content any, err error) {
+	switch r := rr.(type) {
+	case *dns.A:
+		return "A", ARecord{Ip: r.A.To4()}, nil
+	case *dns.AAAA:
+		return "AAAA", AAAARecord{Ip: r.AAAA}, nil
+	case *dns.CNAME:
+		return "CNAME", CNAMERecord{Host: r.Target}, nil
+	case *dns.TXT:
+		return "TXT", TXTRecord{Text: strings.Join(r.Txt, "")}, nil
+	case *dns.NS:
+		return "NS", NSRecord{Host: r.Ns}, nil
+	case *dns.MX:
+		return "MX", MXRecord{Host: r.Mx, Preference: r.Preference}, nil
+	case *dns.SRV:
+		return "SRV", SRVRecord{Priority: r.Priority, Weight: r.Weight, Port: r.Port, Target: r.Target}, nil
+	case *dns.SOA:
+		return "SOA", SOARecord{Ns: r.Ns, MBox: r.Mbox, Refresh: r.Refresh, Retry: r.Retry, Expire: r.Expire, MinTtl: r.Minttl}, nil
+	case *dns.CAA:
+		return "CAA", CAARecord{Flag: r.Flag, Tag: r.Tag, Value: r.Value}, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported record type: %s", dns.TypeToString[rr.Header().Rrtype])
+	}
+}
+
+// RdataString returns the presentation format of the record data, without the header.
+func RdataString(rr dns.RR) string {
+	return strings.TrimSpace(strings.TrimPrefix(rr.String(), rr.Header().String()))
+}
+
+// RRFromContent converts a record content in JSON format into a DNS resource record, the reverse of ContentFromRR.
+// Host names of the content are made fully qualified, texts longer than 255 bytes are split into several strings.
+func RRFromContent(name string, ttl uint32, recordType string, content string) (dns.RR, error) {
+	hdr := dns.RR_Header{Name: dns.Fqdn(name), Class: dns.ClassINET, Ttl: ttl}
+	unmarshal := func(v any) error {
+		if err := json.Unmarshal([]byte(content), v); err != nil {
+			return fmt.Errorf("invalid %s record content: %w", recordType, err)
+		}
+		return nil
+	}
+	switch recordType {
+	case "A":
+		var r ARecord
+		if err := unmarshal(&r); err != nil {
+			return nil, err
+		}
+		hdr.Rrtype = dns.TypeA
+		return &dns.A{Hdr: hdr, A: r.Ip}, nil
+	case "AAAA":
+		var r AAAARecord
+		if err := unmarshal(&r); err != nil {
+			return nil, err
+		}
+		hdr.Rrtype = dns.TypeAAAA
+		return &dns.AAAA{Hdr: hdr, AAAA: r.Ip}, nil
+	case "CNAME":
+		var r CNAMERecord
+		if err := unmarshal(&r); err != nil {
+			return nil, err
+		}
+		hdr.Rrtype = dns.TypeCNAME
+		return &dns.CNAME{Hdr: hdr, Target: dns.Fqdn(r.Host)}, nil
+	case "TXT":
+		var r TXTRecord
+		if err := unmarshal(&r); err != nil {
+			return nil, err
+		}
+		hdr.Rrtype = dns.TypeTXT
+		return &dns.TXT{Hdr: hdr, Txt: splitText(r.Text)}, nil
+	case "NS":
+		var r NSRecord
+		if err := unmarshal(&r); err != nil {
+			return nil, err
+		}
+		hdr.Rrtype = dns.TypeNS
+		return &dns.NS{Hdr: hdr, Ns: dns.Fqdn(r.Host)}, nil
+	case "MX":
+		var r MXRecord
+		if err := unmarshal(&r); err != nil {
+			return nil, err
+		}
+		hdr.Rrtype = dns.TypeMX
+		return &dns.MX{Hdr: hdr, Mx: dns.Fqdn(r.Host), Preference: r.Preference}, nil
+	case "SRV":
+		var r SRVRecord
+		if err := unmarshal(&r); err != nil {
+			return nil, err
+		}
+		hdr.Rrtype = dns.TypeSRV
+		return &dns.SRV{Hdr: hdr, Priority: r.Priority, Weight: r.Weight, Port: r.Port, Target: dns.Fqdn(r.Target)}, nil
+	case "SOA":
+		var r SOARecord
+		if err := unmarshal(&r); err != nil {
+			return nil, err
+		}
+		hdr.Rrtype = dns.TypeSOA
+		return &dns.SOA{Hdr: hdr, Ns: dns.Fqdn(r.Ns), Mbox: dns.Fqdn(r.MBox), Serial: 1, Refresh: r.Refresh, Retry: r.Retry,
+			Expire: r.Expire, Minttl: r.MinTtl}, nil
+	case "CAA":
+		var r CAARecord
+		if err := unmarshal(&r); err != nil {
+			return nil, err
+		}
+		hdr.Rrtype = dns.TypeCAA
+		return &dns.CAA{Hdr: hdr, Flag: r.Flag, Tag: r.Tag, Value: r.Value}, nil
+	default:
+		return nil, fmt.Errorf("unsupported record type: %s", recordType)
+	}
+}
+
+// splitText splits a text into the strings of at most 255 bytes a TXT record is made of.
+func splitText(text string) []string {
+	parts := make([]string, 0, len(text)/255+1)
+	for len(text) > 255 {
+		parts = append(parts, text[:255])
+		text = text[255:]
+	}
+	return append(parts, text)
+}
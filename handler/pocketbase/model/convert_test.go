@@ -0,0 +1,88 @@
+package model
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentFromRR(t *testing.T) {
+	tests := []struct {
+		rr         string
+		recordType string
+		content    any
+	}{
+		{rr: "a.example.com. 300 IN A 192.0.2.1", recordType: "A", content: ARecord{Ip: net.ParseIP("192.0.2.1").To4()}},
+		{rr: "a.example.com. 300 IN AAAA 2001:db8::1", recordType: "AAAA", content: AAAARecord{Ip: net.ParseIP("2001:db8::1")}},
+		{rr: "c.example.com. IN CNAME a.example.com.", recordType: "CNAME", content: CNAMERecord{Host: "a.example.com."}},
+		{rr: `t.example.com. IN TXT "hello" "world"`, recordType: "TXT", content: TXTRecord{Text: "helloworld"}},
+		{rr: "example.com. IN MX 10 mail.example.com.", recordType: "MX", content: MXRecord{Host: "mail.example.com.", Preference: 10}},
+		{rr: "_sip._tcp.example.com. IN SRV 10 20 5060 sip.example.com.", recordType: "SRV",
+			content: SRVRecord{Priority: 10, Weight: 20, Port: 5060, Target: "sip.example.com."}},
+		{rr: `example.com. IN CAA 0 issue "letsencrypt.org"`, recordType: "CAA", content: CAARecord{Flag: 0, Tag: "issue", Value: "letsencrypt.org"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.recordType, func(t *testing.T) {
+			rr, err := dns.NewRR(tt.rr)
+			require.NoError(t, err)
+			recordType, content, err := ContentFromRR(rr)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.recordType, recordType)
+			assert.Equal(t, tt.content, content)
+		})
+	}
+
+	rr, err := dns.NewRR("example.com. IN HINFO cpu os")
+	require.NoError(t, err)
+	_, _, err = ContentFromRR(rr)
+	assert.Error(t, err)
+}
+
+func TestRRFromContent(t *testing.T) {
+	tests := []string{
+		"a.example.com.\t300\tIN\tA\t192.0.2.1",
+		"a.example.com.\t300\tIN\tAAAA\t2001:db8::1",
+		"c.example.com.\t60\tIN\tCNAME\ta.example.com.",
+		"t.example.com.\t60\tIN\tTXT\t\"hello world\"",
+		"example.com.\t60\tIN\tNS\tns1.example.com.",
+		"example.com.\t60\tIN\tMX\t10 mail.example.com.",
+		"_sip._tcp.example.com.\t60\tIN\tSRV\t10 20 5060 sip.example.com.",
+		"example.com.\t60\tIN\tSOA\tns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300",
+		"example.com.\t60\tIN\tCAA\t0 issue \"letsencrypt.org\"",
+	}
+
+	for _, tt := range tests {
+		t.Run(tt, func(t *testing.T) {
+			rr, err := dns.NewRR(tt)
+			require.NoError(t, err)
+			recordType, content, err := ContentFromRR(rr)
+			require.NoError(t, err)
+			data, err := json.Marshal(content)
+			require.NoError(t, err)
+			converted, err := RRFromContent(rr.Header().Name, rr.Header().Ttl, recordType, string(data))
+			assert.NoError(t, err)
+			assert.Equal(t, tt, converted.String())
+		})
+	}
+
+	rr, err := RRFromContent("t.example.com.", 60, "TXT", `{"text": "`+strings.Repeat("x", 300)+`"}`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{strings.Repeat("x", 255), strings.Repeat("x", 45)}, rr.(*dns.TXT).Txt)
+
+	_, err = RRFromContent("a.example.com.", 60, "A", `{"ip": `)
+	assert.Error(t, err)
+	_, err = RRFromContent("a.example.com.", 60, "HINFO", `{}`)
+	assert.Error(t, err)
+}
+
+func TestRdataString(t *testing.T) {
+	rr, err := dns.NewRR("example.com. 300 IN MX 10 mail.example.com.")
+	require.NoError(t, err)
+	assert.Equal(t, "10 mail.example.com.", RdataString(rr))
+}
@@ -11,6 +11,9 @@ type Record struct {
 	RecordType string `db:"record_type" json:"record_type"` // The type of DNS record (A, AAAA, TXT, etc.)
 	Ttl        uint32 `db:"ttl" json:"ttl"`                 // Time to live for the record in seconds
 	Content    string `db:"content" json:"content"`         // The content of the record in JSON format
+	Disabled   bool   `db:"disabled" json:"disabled"`       // Disabled records are kept but not served
+	Comment    string `db:"comment" json:"comment"`         // Why the record exists
+	Owner      string `db:"owner" json:"owner"`             // The team or person owning the record
 }
 
 // ARecord represents an A (IPv4) DNS record
@@ -0,0 +1,265 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// TemplateRecordType is the type of the records standing for a range of records of another type, like the $GENERATE
+// directive of BIND zone files: the name of the record and the template of the record data are expanded for each
+// value of the range.
+const TemplateRecordType = "TEMPLATE"
+
+// MaxTemplateRecords bounds the number of records a template expands into.
+const MaxTemplateRecords = 65536
+
+// TEMPLATERecord represents a range of records, see TemplateRecordType.
+// In the name and the template, $ is replaced with the value, ${offset,width,base} with the value plus offset,
+// zero padded to width, in base d, o, x or X, and \$ is a literal $.
+type TEMPLATERecord struct {
+	RecordType string `json:"record_type"` // Type of the generated records
+	Start      uint32 `json:"start"`       // First value of the range
+	Stop       uint32 `json:"stop"`        // Last value of the range, included
+	Step       uint32 `json:"step"`        // Increment between the values, 1 if not set
+	Template   string `json:"template"`    // Record data in master file format, relative names are within the zone
+}
+
+// templatePart is a literal or a substitution of a template.
+type templatePart struct {
+	literal string
+	subst   bool
+	offset  int64
+	width   int
+	base    byte
+}
+
+// Validate checks the generated record type, the range and the syntax of the template.
+func (r *TEMPLATERecord) Validate() error {
+	if !IsSupportedRecordType(r.RecordType) || r.RecordType == "SOA" || r.RecordType == TemplateRecordType {
+		return fmt.Errorf("record_type %q can not be generated", r.RecordType)
+	}
+	if r.Stop < r.Start {
+		return fmt.Errorf("stop %d is lower than start %d", r.Stop, r.Start)
+	}
+	if n := r.Len(); n > MaxTemplateRecords {
+		return fmt.Errorf("the range has %d values, at most %d are allowed", n, MaxTemplateRecords)
+	}
+	if strings.TrimSpace(r.Template) == "" {
+		return fmt.Errorf("template is required")
+	}
+	if _, err := parseTemplate(r.Template); err != nil {
+		return fmt.Errorf("template: %v", err)
+	}
+	return nil
+}
+
+// Len returns the number of values of the range.
+func (r *TEMPLATERecord) Len() int {
+	if r.Stop < r.Start {
+		return 0
+	}
+	return int((uint64(r.Stop)-uint64(r.Start))/uint64(r.step())) + 1
+}
+
+// Value returns the i-th value of the range.
+func (r *TEMPLATERecord) Value(i int) uint32 {
+	return r.Start + uint32(i)*r.step()
+}
+
+func (r *TEMPLATERecord) step() uint32 {
+	if r.Step == 0 {
+		return 1
+	}
+	return r.Step
+}
+
+// ExpandTemplateRecord returns the record generated by the template for the value of its range,
+// name is the owner name pattern and relative names of the record data are within the zone.
+func ExpandTemplateRecord(zone string, name string, ttl uint32, r *TEMPLATERecord, value uint32) (dns.RR, error) {
+	owner, err := ExpandTemplate(name, value)
+	if err != nil {
+		return nil, fmt.Errorf("name: %v", err)
+	}
+	rdata, err := ExpandTemplate(r.Template, value)
+	if err != nil {
+		return nil, fmt.Errorf("template: %v", err)
+	}
+	if err = validateFqdn(owner); err != nil {
+		return nil, err
+	}
+	if !dns.IsSubDomain(strings.ToLower(zone), strings.ToLower(owner)) {
+		return nil, fmt.Errorf("name %s is outside of zone %s", owner, zone)
+	}
+	zp := dns.NewZoneParser(strings.NewReader(fmt.Sprintf("%s %d IN %s %s", owner, ttl, r.RecordType, rdata)), zone, "")
+	rr, ok := zp.Next()
+	if err = zp.Err(); err != nil {
+		return nil, fmt.Errorf("invalid %s record %s: %v", r.RecordType, rdata, err)
+	}
+	if !ok || dns.TypeToString[rr.Header().Rrtype] != r.RecordType {
+		return nil, fmt.Errorf("invalid %s record %s", r.RecordType, rdata)
+	}
+	return rr, nil
+}
+
+// ValidateTemplateName checks the owner name pattern of a template has a single substitution, so that the value of
+// a name is parsed from the name instead of searched in the range.
+func ValidateTemplateName(pattern string) error {
+	parts, err := parseTemplate(pattern)
+	if err != nil {
+		return err
+	}
+	if _, _, _, ok := splitSingleSubstitution(parts); !ok {
+		return fmt.Errorf("the name must have a single substitution, e.g. host-$")
+	}
+	return nil
+}
+
+// MatchTemplateName returns the value of the range of the template whose owner name is name, case-insensitively.
+// The pattern must have a single substitution, see ValidateTemplateName.
+func MatchTemplateName(pattern string, r *TEMPLATERecord, name string) (uint32, bool) {
+	parts, err := parseTemplate(strings.ToLower(pattern))
+	if err != nil {
+		return 0, false
+	}
+	prefix, subst, suffix, ok := splitSingleSubstitution(parts)
+	if !ok {
+		return 0, false
+	}
+	name = strings.ToLower(name)
+	if len(name) < len(prefix)+len(suffix) || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return 0, false
+	}
+	base := map[byte]int{'d': 10, 'o': 8, 'x': 16, 'X': 16}[subst.base]
+	v, err := strconv.ParseInt(name[len(prefix):len(name)-len(suffix)], base, 64)
+	if err != nil {
+		return 0, false
+	}
+	v -= subst.offset
+	if v < int64(r.Start) || v > int64(r.Stop) || (v-int64(r.Start))%int64(r.step()) != 0 {
+		return 0, false
+	}
+	// the expansion tells apart the zero padding and the case of the digits
+	return uint32(v), strings.EqualFold(expandParts(parts, uint32(v)), name)
+}
+
+// splitSingleSubstitution returns the literal prefix, the substitution and the literal suffix of a template,
+// ok is false unless the template has a single substitution.
+func splitSingleSubstitution(parts []templatePart) (prefix string, subst templatePart, suffix string, ok bool) {
+	for _, p := range parts {
+		switch {
+		case p.subst && ok:
+			return "", templatePart{}, "", false
+		case p.subst:
+			subst, ok = p, true
+		case !ok:
+			prefix = p.literal
+		default:
+			suffix = p.literal
+		}
+	}
+	return prefix, subst, suffix, ok
+}
+
+// ExpandTemplate replaces the substitutions of the template with the value.
+func ExpandTemplate(s string, value uint32) (string, error) {
+	parts, err := parseTemplate(s)
+	if err != nil {
+		return "", err
+	}
+	return expandParts(parts, value), nil
+}
+
+func expandParts(parts []templatePart, value uint32) string {
+	var sb strings.Builder
+	for _, p := range parts {
+		if !p.subst {
+			sb.WriteString(p.literal)
+			continue
+		}
+		v := int64(value) + p.offset
+		var digits string
+		switch p.base {
+		case 'o':
+			digits = strconv.FormatInt(v, 8)
+		case 'x':
+			digits = strconv.FormatInt(v, 16)
+		case 'X':
+			digits = strings.ToUpper(strconv.FormatInt(v, 16))
+		default:
+			digits = strconv.FormatInt(v, 10)
+		}
+		if len(digits) < p.width {
+			sb.WriteString(strings.Repeat("0", p.width-len(digits)))
+		}
+		sb.WriteString(digits)
+	}
+	return sb.String()
+}
+
+// parseTemplate splits a template into its literals and substitutions.
+func parseTemplate(s string) ([]templatePart, error) {
+	parts := make([]templatePart, 0)
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			parts = append(parts, templatePart{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == '$':
+			literal.WriteByte('$')
+			i++
+		case s[i] != '$':
+			literal.WriteByte(s[i])
+		case i+1 < len(s) && s[i+1] == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated substitution in %q", s)
+			}
+			p, err := parseSubstitution(s[i+2 : i+end])
+			if err != nil {
+				return nil, err
+			}
+			flush()
+			parts = append(parts, p)
+			i += end
+		default:
+			flush()
+			parts = append(parts, templatePart{subst: true, base: 'd'})
+		}
+	}
+	flush()
+	return parts, nil
+}
+
+// parseSubstitution parses the offset, width and base of a ${offset,width,base} substitution,
+// the width and the base are optional.
+func parseSubstitution(s string) (templatePart, error) {
+	p := templatePart{subst: true, base: 'd'}
+	fields := strings.Split(s, ",")
+	if len(fields) > 3 {
+		return p, fmt.Errorf("invalid substitution ${%s}, expected ${offset,width,base}", s)
+	}
+	var err error
+	if p.offset, err = strconv.ParseInt(strings.TrimSpace(fields[0]), 10, 32); err != nil {
+		return p, fmt.Errorf("invalid offset of substitution ${%s}", s)
+	}
+	if len(fields) > 1 {
+		if p.width, err = strconv.Atoi(strings.TrimSpace(fields[1])); err != nil || p.width < 0 || p.width > 255 {
+			return p, fmt.Errorf("invalid width of substitution ${%s}", s)
+		}
+	}
+	if len(fields) > 2 {
+		base := strings.TrimSpace(fields[2])
+		if len(base) != 1 || !strings.Contains("doxX", base) {
+			return p, fmt.Errorf("invalid base of substitution ${%s}, must be d, o, x or X", s)
+		}
+		p.base = base[0]
+	}
+	return p, nil
+}
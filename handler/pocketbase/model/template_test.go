@@ -0,0 +1,139 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		value    uint32
+		want     string
+		wantErr  bool
+	}{
+		{name: "plain value", template: "host-$", value: 7, want: "host-7"},
+		{name: "several values", template: "10.0.$.$", value: 3, want: "10.0.3.3"},
+		{name: "offset", template: "${10}", value: 7, want: "17"},
+		{name: "negative offset", template: "${-1}", value: 7, want: "6"},
+		{name: "width", template: "host-${0,3}", value: 7, want: "host-007"},
+		{name: "hex", template: "${0,2,x}", value: 255, want: "ff"},
+		{name: "upper hex", template: "${0,4,X}", value: 255, want: "00FF"},
+		{name: "octal", template: "${0,0,o}", value: 8, want: "10"},
+		{name: "escaped", template: `\$-$`, value: 1, want: "$-1"},
+		{name: "no substitution", template: "www", value: 1, want: "www"},
+		{name: "unterminated", template: "${1", wantErr: true},
+		{name: "invalid offset", template: "${a}", wantErr: true},
+		{name: "invalid width", template: "${0,-1}", wantErr: true},
+		{name: "invalid base", template: "${0,1,n}", wantErr: true},
+		{name: "too many fields", template: "${0,1,d,x}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandTemplate(tt.template, tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTemplateRange(t *testing.T) {
+	r := TEMPLATERecord{Start: 1, Stop: 254}
+	assert.Equal(t, 254, r.Len())
+	assert.Equal(t, uint32(1), r.Value(0))
+	assert.Equal(t, uint32(254), r.Value(253))
+
+	r = TEMPLATERecord{Start: 0, Stop: 10, Step: 4}
+	assert.Equal(t, 3, r.Len())
+	assert.Equal(t, uint32(8), r.Value(2))
+
+	r = TEMPLATERecord{Start: 0, Stop: 4294967295}
+	assert.Equal(t, 4294967296, r.Len())
+}
+
+func TestMatchTemplateName(t *testing.T) {
+	r := TEMPLATERecord{RecordType: "A", Start: 1, Stop: 254, Template: "10.0.1.$"}
+	value, ok := MatchTemplateName("host-$.example.com.", &r, "HOST-42.example.com.")
+	assert.True(t, ok)
+	assert.Equal(t, uint32(42), value)
+
+	_, ok = MatchTemplateName("host-$.example.com.", &r, "host-255.example.com.")
+	assert.False(t, ok)
+	_, ok = MatchTemplateName("host-$.example.com.", &r, "www.example.com.")
+	assert.False(t, ok)
+
+	value, ok = MatchTemplateName("${0,3}.pool.example.com.", &TEMPLATERecord{Start: 0, Stop: 100, Step: 10}, "020.pool.example.com.")
+	assert.True(t, ok)
+	assert.Equal(t, uint32(20), value)
+	_, ok = MatchTemplateName("${0,3}.pool.example.com.", &TEMPLATERecord{Start: 0, Stop: 100, Step: 10}, "025.pool.example.com.")
+	assert.False(t, ok)
+	_, ok = MatchTemplateName("host-$-$.example.com.", &r, "host-1-1.example.com.")
+	assert.False(t, ok)
+}
+
+func TestExpandTemplateRecord(t *testing.T) {
+	r := TEMPLATERecord{RecordType: "A", Start: 1, Stop: 254, Template: "10.0.1.$"}
+	rr, err := ExpandTemplateRecord("example.com.", "host-$.example.com.", 300, &r, 7)
+	require.NoError(t, err)
+	assert.Equal(t, "host-7.example.com.\t300\tIN\tA\t10.0.1.7", rr.String())
+
+	r = TEMPLATERecord{RecordType: "CNAME", Start: 1, Stop: 2, Template: "node-$"}
+	rr, err = ExpandTemplateRecord("example.com.", "alias-$.example.com.", 0, &r, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "alias-2.example.com.\t0\tIN\tCNAME\tnode-2.example.com.", rr.String())
+
+	r = TEMPLATERecord{RecordType: "A", Start: 1, Stop: 300, Template: "10.0.1.$"}
+	_, err = ExpandTemplateRecord("example.com.", "host-$.example.com.", 300, &r, 256)
+	assert.Error(t, err)
+	_, err = ExpandTemplateRecord("example.com.", "host-$.example.org.", 300, &r, 1)
+	assert.Error(t, err)
+}
+
+func TestValidateTemplateRecord(t *testing.T) {
+	valid := Record{Zone: "example.com.", Name: "host-$.example.com.", RecordType: TemplateRecordType,
+		Content: `{"record_type":"A","start":1,"stop":254,"template":"10.0.1.$"}`}
+	assert.NoError(t, ValidateRecord(&valid))
+
+	tests := []struct {
+		name   string
+		modify func(r *Record)
+		field  string
+	}{
+		{name: "relative name", modify: func(r *Record) { r.Name = "host-$" }, field: "name"},
+		{name: "name outside zone", modify: func(r *Record) { r.Name = "host-$.example.org." }, field: "name"},
+		{name: "name without substitution", modify: func(r *Record) { r.Name = "host.example.com." }, field: "name"},
+		{name: "name with several substitutions", modify: func(r *Record) { r.Name = "host-$-$.example.com." }, field: "name"},
+		{name: "generated SOA", modify: func(r *Record) {
+			r.Content = `{"record_type":"SOA","start":1,"stop":2,"template":"ns1 hostmaster 1 2 3 4 5"}`
+		}, field: "content"},
+		{name: "stop before start", modify: func(r *Record) {
+			r.Content = `{"record_type":"A","start":2,"stop":1,"template":"10.0.1.$"}`
+		}, field: "content"},
+		{name: "range too large", modify: func(r *Record) {
+			r.Content = `{"record_type":"A","start":0,"stop":65536,"template":"10.0.1.$"}`
+		}, field: "content"},
+		{name: "invalid generated record", modify: func(r *Record) {
+			r.Content = `{"record_type":"A","start":250,"stop":260,"template":"10.0.1.$"}`
+		}, field: "content"},
+		{name: "missing template", modify: func(r *Record) {
+			r.Content = `{"record_type":"A","start":1,"stop":2}`
+		}, field: "content"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := valid
+			tt.modify(&rec)
+			var vErr *ValidationError
+			require.True(t, errors.As(ValidateRecord(&rec), &vErr))
+			assert.Equal(t, tt.field, vErr.Field)
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// CAA property tags, see RFC 8659 and the IANA "Certification Authority Restriction Properties" registry.
+var caaTags = []string{"issue", "issuewild", "iodef", "issuemail", "issuevmc", "contactemail", "contactphone"}
+
+// ValidationError describes an invalid field of a record.
+type ValidationError struct {
+	Field   string // The record field the error belongs to, e.g. "content"
+	Message string // Human readable description of the problem
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidateRecord checks the zone, name, type and content of a record.
+// It returns a *ValidationError describing the first problem found.
+func ValidateRecord(rec *Record) error {
+	if err := validateFqdn(rec.Zone); err != nil {
+		return &ValidationError{Field: "zone", Message: err.Error()}
+	}
+	if rec.RecordType == TemplateRecordType {
+		return validateTemplateRecord(rec)
+	}
+	if err := validateFqdn(rec.Name); err != nil {
+		return &ValidationError{Field: "name", Message: err.Error()}
+	}
+	if !IsSupportedRecordType(rec.RecordType) {
+		return &ValidationError{Field: "record_type", Message: fmt.Sprintf(
+			"unsupported record type %q, must be one of %s", rec.RecordType, strings.Join(SupportedRecordTypes, ", "))}
+	}
+	return ValidateContent(rec.RecordType, rec.Content)
+}
+
+// ValidateZoneName checks that the name of a zone is a fully qualified domain name.
+// It returns a *ValidationError if it is not.
+func ValidateZoneName(name string) error {
+	if err := validateFqdn(name); err != nil {
+		return &ValidationError{Field: "name", Message: err.Error()}
+	}
+	return nil
+}
+
+// ValidateContent unmarshals the JSON content into the model of the record type and checks its field semantics.
+// It returns a *ValidationError describing the first problem found.
+func ValidateContent(recordType string, content string) error {
+	var v interface{ Validate() error }
+	switch recordType {
+	case "A":
+		v = &ARecord{}
+	case "AAAA":
+		v = &AAAARecord{}
+	case "CNAME":
+		v = &CNAMERecord{}
+	case "SOA":
+		v = &SOARecord{}
+	case "SRV":
+		v = &SRVRecord{}
+	case "NS":
+		v = &NSRecord{}
+	case "MX":
+		v = &MXRecord{}
+	case "TXT":
+		v = &TXTRecord{}
+	case "CAA":
+		v = &CAARecord{}
+	case TemplateRecordType:
+		v = &TEMPLATERecord{}
+	default:
+		return &ValidationError{Field: "record_type", Message: fmt.Sprintf("unsupported record type %q", recordType)}
+	}
+
+	if strings.TrimSpace(content) == "" || strings.TrimSpace(content) == "null" {
+		return &ValidationError{Field: "content", Message: fmt.Sprintf("%s record content is required", recordType)}
+	}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(content)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return &ValidationError{Field: "content", Message: fmt.Sprintf("invalid %s record content: %v", recordType, err)}
+	}
+	if err := v.Validate(); err != nil {
+		return &ValidationError{Field: "content", Message: fmt.Sprintf("invalid %s record content: %v", recordType, err)}
+	}
+	return nil
+}
+
+// Validate checks that the address is an IPv4 address.
+func (r *ARecord) Validate() error {
+	if r.Ip == nil {
+		return fmt.Errorf("ip is required")
+	}
+	if r.Ip.To4() == nil {
+		return fmt.Errorf("ip %s is not an IPv4 address", r.Ip)
+	}
+	return nil
+}
+
+// Validate checks that the address is an IPv6 address.
+func (r *AAAARecord) Validate() error {
+	if r.Ip == nil {
+		return fmt.Errorf("ip is required")
+	}
+	if r.Ip.To4() != nil {
+		return fmt.Errorf("ip %s is not an IPv6 address", r.Ip)
+	}
+	return nil
+}
+
+// Validate checks that the text is not empty.
+func (r *TXTRecord) Validate() error {
+	if r.Text == "" {
+		return fmt.Errorf("text is required")
+	}
+	return nil
+}
+
+// Validate checks that the target host and its zone are fully qualified domain names.
+func (r *CNAMERecord) Validate() error {
+	if err := validateFqdn(r.Host); err != nil {
+		return fmt.Errorf("host: %v", err)
+	}
+	if r.Zone != "" {
+		if err := validateFqdn(r.Zone); err != nil {
+			return fmt.Errorf("zone: %v", err)
+		}
+		if !dns.IsSubDomain(r.Zone, r.Host) {
+			return fmt.Errorf("host %s is not within zone %s", r.Host, r.Zone)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the name server is a fully qualified domain name.
+func (r *NSRecord) Validate() error {
+	if err := validateFqdn(r.Host); err != nil {
+		return fmt.Errorf("host: %v", err)
+	}
+	return nil
+}
+
+// Validate checks that the mail server is a fully qualified domain name.
+func (r *MXRecord) Validate() error {
+	if err := validateFqdn(r.Host); err != nil {
+		return fmt.Errorf("host: %v", err)
+	}
+	return nil
+}
+
+// Validate checks that the target is a fully qualified domain name and the port is set,
+// unless the target is "." which means the service is not available.
+func (r *SRVRecord) Validate() error {
+	if err := validateFqdn(r.Target); err != nil {
+		return fmt.Errorf("target: %v", err)
+	}
+	if r.Target != "." && r.Port == 0 {
+		return fmt.Errorf("port must be between 1 and 65535")
+	}
+	return nil
+}
+
+// Validate checks the name server and mailbox, an SOA without name server is filled with defaults.
+func (r *SOARecord) Validate() error {
+	if r.Ns == "" {
+		return nil
+	}
+	if err := validateFqdn(r.Ns); err != nil {
+		return fmt.Errorf("ns: %v", err)
+	}
+	if _, ok := dns.IsDomainName(r.MBox); !ok || r.MBox == "" {
+		return fmt.Errorf("mbox %q is not a valid mailbox domain name", r.MBox)
+	}
+	return nil
+}
+
+// Validate checks the flag, that the tag is a registered property and that the value suits the tag.
+func (r *CAARecord) Validate() error {
+	if r.Flag != 0 && r.Flag != 128 {
+		return fmt.Errorf("flag must be 0 or 128 (issuer critical)")
+	}
+	known := false
+	for _, tag := range caaTags {
+		if r.Tag == tag {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return fmt.Errorf("unknown tag %q, must be one of %s", r.Tag, strings.Join(caaTags, ", "))
+	}
+	if r.Value == "" {
+		return fmt.Errorf("value is required")
+	}
+	if r.Tag == "iodef" {
+		u, err := url.Parse(r.Value)
+		if err != nil || (u.Scheme != "mailto" && u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("iodef value must be a mailto:, http: or https: URL")
+		}
+	}
+	return nil
+}
+
+// validateTemplateRecord checks the content of a template record and every record it expands into,
+// its name is the owner name pattern of the records.
+func validateTemplateRecord(rec *Record) error {
+	if err := ValidateContent(rec.RecordType, rec.Content); err != nil {
+		return err
+	}
+	if !dns.IsFqdn(rec.Name) {
+		return &ValidationError{Field: "name", Message: fmt.Sprintf("%q is not fully qualified, add a trailing dot", rec.Name)}
+	}
+	if err := ValidateTemplateName(rec.Name); err != nil {
+		return &ValidationError{Field: "name", Message: err.Error()}
+	}
+	var t TEMPLATERecord
+	if err := json.Unmarshal([]byte(rec.Content), &t); err != nil {
+		return &ValidationError{Field: "content", Message: err.Error()}
+	}
+	for i, n := 0, t.Len(); i < n; i++ {
+		value := t.Value(i)
+		owner, err := ExpandTemplate(rec.Name, value)
+		if err == nil {
+			err = validateFqdn(owner)
+		}
+		if err == nil && !dns.IsSubDomain(strings.ToLower(rec.Zone), strings.ToLower(owner)) {
+			err = fmt.Errorf("name %s is outside of zone %s", owner, rec.Zone)
+		}
+		if err != nil {
+			return &ValidationError{Field: "name", Message: fmt.Sprintf("value %d: %v", value, err)}
+		}
+		rr, err := ExpandTemplateRecord(rec.Zone, rec.Name, rec.Ttl, &t, value)
+		if err != nil {
+			return &ValidationError{Field: "content", Message: fmt.Sprintf("value %d: %v", value, err)}
+		}
+		_, content, err := ContentFromRR(rr)
+		if err != nil {
+			return &ValidationError{Field: "content", Message: fmt.Sprintf("value %d: %v", value, err)}
+		}
+		data, err := json.Marshal(content)
+		if err != nil {
+			return &ValidationError{Field: "content", Message: fmt.Sprintf("value %d: %v", value, err)}
+		}
+		if err = ValidateContent(t.RecordType, string(data)); err != nil {
+			return &ValidationError{Field: "content", Message: fmt.Sprintf("value %d: %s", value, err.(*ValidationError).Message)}
+		}
+	}
+	return nil
+}
+
+// validateFqdn checks that name is a valid fully qualified domain name.
+func validateFqdn(name string) error {
+	if name == "" {
+		return fmt.Errorf("domain name is required")
+	}
+	if _, ok := dns.IsDomainName(name); !ok {
+		return fmt.Errorf("%q is not a valid domain name", name)
+	}
+	if !dns.IsFqdn(name) {
+		return fmt.Errorf("%q is not fully qualified, add a trailing dot", name)
+	}
+	return nil
+}
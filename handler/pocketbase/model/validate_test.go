@@ -0,0 +1,94 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateContent(t *testing.T) {
+	tests := []struct {
+		name       string
+		recordType string
+		content    string
+		wantErr    bool
+	}{
+		{name: "valid A", recordType: "A", content: `{"ip":"192.0.2.1"}`},
+		{name: "A with unknown field", recordType: "A", content: `{"ipp":"192.0.2.1"}`, wantErr: true},
+		{name: "A with IPv6", recordType: "A", content: `{"ip":"2001:db8::1"}`, wantErr: true},
+		{name: "A with invalid ip", recordType: "A", content: `{"ip":"192.0.2.256"}`, wantErr: true},
+		{name: "empty content", recordType: "A", content: ``, wantErr: true},
+		{name: "null content", recordType: "A", content: `null`, wantErr: true},
+		{name: "valid AAAA", recordType: "AAAA", content: `{"ip":"2001:db8::1"}`},
+		{name: "AAAA with IPv4", recordType: "AAAA", content: `{"ip":"192.0.2.1"}`, wantErr: true},
+		{name: "valid TXT", recordType: "TXT", content: `{"text":"hello"}`},
+		{name: "empty TXT", recordType: "TXT", content: `{"text":""}`, wantErr: true},
+		{name: "valid CNAME", recordType: "CNAME", content: `{"host":"b.example.com.","zone":"example.com."}`},
+		{name: "CNAME without zone", recordType: "CNAME", content: `{"host":"b.example.com."}`},
+		{name: "CNAME relative host", recordType: "CNAME", content: `{"host":"b.example.com"}`, wantErr: true},
+		{name: "CNAME host outside zone", recordType: "CNAME", content: `{"host":"b.example.org.","zone":"example.com."}`, wantErr: true},
+		{name: "valid NS", recordType: "NS", content: `{"host":"ns1.example.com."}`},
+		{name: "NS without host", recordType: "NS", content: `{}`, wantErr: true},
+		{name: "valid MX", recordType: "MX", content: `{"host":"mail.example.com.","preference":10}`},
+		{name: "MX preference out of range", recordType: "MX", content: `{"host":"mail.example.com.","preference":70000}`, wantErr: true},
+		{name: "valid SRV", recordType: "SRV", content: `{"priority":10,"weight":5,"port":5060,"target":"sip.example.com."}`},
+		{name: "SRV without port", recordType: "SRV", content: `{"priority":10,"weight":5,"target":"sip.example.com."}`, wantErr: true},
+		{name: "SRV port out of range", recordType: "SRV", content: `{"port":65536,"target":"sip.example.com."}`, wantErr: true},
+		{name: "SRV service unavailable", recordType: "SRV", content: `{"target":"."}`},
+		{name: "valid SOA", recordType: "SOA", content: `{"ns":"ns1.example.com.","mbox":"hostmaster.example.com.","refresh":86400}`},
+		{name: "default SOA", recordType: "SOA", content: `{}`},
+		{name: "SOA invalid mbox", recordType: "SOA", content: `{"ns":"ns1.example.com.","mbox":""}`, wantErr: true},
+		{name: "valid CAA", recordType: "CAA", content: `{"flag":0,"tag":"issue","value":"letsencrypt.org"}`},
+		{name: "CAA unknown tag", recordType: "CAA", content: `{"flag":0,"tag":"isue","value":"letsencrypt.org"}`, wantErr: true},
+		{name: "CAA invalid flag", recordType: "CAA", content: `{"flag":1,"tag":"issue","value":"letsencrypt.org"}`, wantErr: true},
+		{name: "CAA invalid iodef", recordType: "CAA", content: `{"flag":0,"tag":"iodef","value":"security@example.com"}`, wantErr: true},
+		{name: "CAA valid iodef", recordType: "CAA", content: `{"flag":0,"tag":"iodef","value":"mailto:security@example.com"}`},
+		{name: "unsupported type", recordType: "HINFO", content: `{}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateContent(tt.recordType, tt.content)
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+			var vErr *ValidationError
+			assert.True(t, errors.As(err, &vErr))
+		})
+	}
+}
+
+func TestValidateRecord(t *testing.T) {
+	valid := Record{Zone: "example.com.", Name: "www.example.com.", RecordType: "A", Content: `{"ip":"192.0.2.1"}`}
+	assert.NoError(t, ValidateRecord(&valid))
+
+	tests := []struct {
+		name   string
+		modify func(r *Record)
+		field  string
+	}{
+		{name: "relative zone", modify: func(r *Record) { r.Zone = "example.com" }, field: "zone"},
+		{name: "relative name", modify: func(r *Record) { r.Name = "www" }, field: "name"},
+		{name: "invalid name", modify: func(r *Record) { r.Name = "www..example.com." }, field: "name"},
+		{name: "unsupported type", modify: func(r *Record) { r.RecordType = "PTR" }, field: "record_type"},
+		{name: "invalid content", modify: func(r *Record) { r.Content = `{"ip":"x"}` }, field: "content"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := valid
+			tt.modify(&rec)
+			var vErr *ValidationError
+			assert.True(t, errors.As(ValidateRecord(&rec), &vErr))
+			assert.Equal(t, tt.field, vErr.Field)
+		})
+	}
+}
+
+func TestValidateZoneName(t *testing.T) {
+	assert.NoError(t, ValidateZoneName("example.com."))
+	assert.Error(t, ValidateZoneName(""))
+	assert.Error(t, ValidateZoneName("example.com"))
+	assert.Error(t, ValidateZoneName("example..com."))
+}
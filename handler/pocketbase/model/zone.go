@@ -0,0 +1,70 @@
+package model
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+)
+
+// Zone states
+const (
+	// ZoneStateActive zones are served and writable
+	ZoneStateActive = "active"
+	// ZoneStateDisabled zones are not served, queries are refused or passed to the next plugin
+	ZoneStateDisabled = "disabled"
+	// ZoneStateFrozen zones are served, but their records can not be changed
+	ZoneStateFrozen = "frozen"
+)
+
+// Zone represents a DNS zone with its settings
+type Zone struct {
+	Id          string `db:"id" json:"id"`                     // The id of the zone row
+	Name        string `db:"name" json:"name"`                 // The fully qualified name of the zone
+	DefaultTtl  uint32 `db:"default_ttl" json:"default_ttl"`   // TTL of records without TTL, 0 to use the global default
+	MinTtl      uint32 `db:"min_ttl" json:"min_ttl"`           // Minimum TTL of served records, 0 for no zone minimum
+	MaxTtl      uint32 `db:"max_ttl" json:"max_ttl"`           // Maximum TTL of served records, 0 for no zone maximum
+	SoaNs       string `db:"soa_ns" json:"soa_ns"`             // Primary name server of the generated SOA record
+	SoaMBox     string `db:"soa_mbox" json:"soa_mbox"`         // Email address of the administrator of the generated SOA record
+	SoaRefresh  uint32 `db:"soa_refresh" json:"soa_refresh"`   // Refresh interval of the generated SOA record in seconds
+	SoaRetry    uint32 `db:"soa_retry" json:"soa_retry"`       // Retry interval of the generated SOA record in seconds
+	SoaExpire   uint32 `db:"soa_expire" json:"soa_expire"`     // Expiration time of the generated SOA record in seconds
+	SoaMinTtl   uint32 `db:"soa_minttl" json:"soa_minttl"`     // Minimum TTL of the generated SOA record in seconds
+	Serial      uint32 `db:"serial" json:"serial"`             // Serial of the zone, bumped on every record change
+	State       string `db:"state" json:"state"`               // The state of the zone, see ZoneState* (empty means active)
+	TransferAcl string `db:"transfer_acl" json:"transfer_acl"` // CIDRs and addresses allowed to transfer the zone in JSON format
+	Owner       string `db:"owner" json:"owner"`               // The owner of the zone
+}
+
+// IsDisabled reports whether the zone is not served.
+func (z *Zone) IsDisabled() bool {
+	return z.State == ZoneStateDisabled
+}
+
+// IsFrozen reports whether the records of the zone can not be changed.
+func (z *Zone) IsFrozen() bool {
+	return z.State == ZoneStateFrozen
+}
+
+// TransferAllowed reports whether the address may transfer the zone, being within one of the CIDRs or equal to one
+// of the addresses of the transfer ACL. Zones without transfer ACL can not be transferred.
+func (z *Zone) TransferAllowed(ip net.IP) bool {
+	if ip == nil || z.TransferAcl == "" {
+		return false
+	}
+	var acl []string
+	if err := json.Unmarshal([]byte(z.TransferAcl), &acl); err != nil {
+		return false
+	}
+	for _, entry := range acl {
+		if !strings.Contains(entry, "/") {
+			if allowed := net.ParseIP(entry); allowed != nil && allowed.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZoneTransferAllowed(t *testing.T) {
+	tests := []struct {
+		name string
+		acl  string
+		ip   string
+		want bool
+	}{
+		{name: "no acl", acl: "", ip: "192.0.2.1"},
+		{name: "null acl", acl: "null", ip: "192.0.2.1"},
+		{name: "empty acl", acl: "[]", ip: "192.0.2.1"},
+		{name: "invalid acl", acl: "192.0.2.0/24", ip: "192.0.2.1"},
+		{name: "within cidr", acl: `["192.0.2.0/24"]`, ip: "192.0.2.1", want: true},
+		{name: "outside cidr", acl: `["192.0.2.0/24"]`, ip: "198.51.100.1"},
+		{name: "equal address", acl: `["2001:db8::53"]`, ip: "2001:db8::53", want: true},
+		{name: "other address", acl: `["2001:db8::53"]`, ip: "2001:db8::54"},
+		{name: "no client address", acl: `["0.0.0.0/0"]`, ip: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zone := &Zone{Name: "example.com.", TransferAcl: tt.acl}
+			assert.Equal(t, tt.want, zone.TransferAllowed(net.ParseIP(tt.ip)))
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package pocketbase
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// octodnsFormat selects the octoDNS YAML format of zones instead of the zone file format.
+	octodnsFormat = "octodns"
+	// octodnsContentType is the content type of zones in octoDNS YAML format.
+	octodnsContentType = "application/yaml"
+	// octodnsDefaultTtl is the TTL octoDNS gives records without TTL.
+	octodnsDefaultTtl = 3600
+)
+
+// octodnsRecord is a record of a name in the YAML format of the octoDNS YamlProvider,
+// with a single value or with several values.
+type octodnsRecord struct {
+	Type    string      `yaml:"type"`
+	Ttl     *uint32     `yaml:"ttl"`
+	Value   yaml.Node   `yaml:"value"`
+	Values  []yaml.Node `yaml:"values"`
+	Geo     yaml.Node   `yaml:"geo"`
+	Dynamic yaml.Node   `yaml:"dynamic"`
+}
+
+// octodnsMxValue is the value of an octoDNS MX record, older versions of octoDNS name its fields priority and value.
+type octodnsMxValue struct {
+	Exchange   string  `yaml:"exchange"`
+	Preference *uint16 `yaml:"preference"`
+	Priority   uint16  `yaml:"priority"`
+	Value      string  `yaml:"value"`
+}
+
+// octodnsSrvValue is the value of an octoDNS SRV record.
+type octodnsSrvValue struct {
+	Port     uint16 `yaml:"port"`
+	Priority uint16 `yaml:"priority"`
+	Target   string `yaml:"target"`
+	Weight   uint16 `yaml:"weight"`
+}
+
+// octodnsCaaValue is the value of an octoDNS CAA record.
+type octodnsCaaValue struct {
+	Flags uint8  `yaml:"flags"`
+	Tag   string `yaml:"tag"`
+	Value string `yaml:"value"`
+}
+
+// octodnsZone are the records read from an octoDNS YAML zone, with the records which could not be converted
+// and the warnings about the settings which are ignored.
+type octodnsZone struct {
+	rrs      []dns.RR
+	issues   []*zoneImportIssue
+	warnings []string
+}
+
+// wantsOctodns reports whether the request asks for the octoDNS YAML format.
+func wantsOctodns(e *core.RequestEvent) bool {
+	return e.Request.URL.Query().Get("format") == octodnsFormat
+}
+
+// parseOctodnsYaml reads the records of a zone in the YAML format of the octoDNS YamlProvider, names being relative
+// to the zone and empty for the apex. Geo and dynamic records are read with their default values, with a warning.
+func parseOctodnsYaml(r io.Reader, zone string) (*octodnsZone, error) {
+	var names map[string]yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&names); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid octoDNS YAML: %w", err)
+	}
+	keys := make([]string, 0, len(names))
+	for key := range names {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	oz := &octodnsZone{rrs: make([]dns.RR, 0)}
+	for _, key := range keys {
+		name := zone
+		if key != "" {
+			name = strings.ToLower(key) + "." + zone
+		}
+		node := names[key]
+		var records []*octodnsRecord
+		if node.Kind == yaml.SequenceNode {
+			if err := node.Decode(&records); err != nil {
+				return nil, fmt.Errorf("invalid octoDNS records of %q: %w", key, err)
+			}
+		} else {
+			record := &octodnsRecord{}
+			if err := node.Decode(record); err != nil {
+				return nil, fmt.Errorf("invalid octoDNS record of %q: %w", key, err)
+			}
+			records = append(records, record)
+		}
+		for _, record := range records {
+			oz.addRecord(name, record)
+		}
+	}
+	return oz, nil
+}
+
+// importOctodnsZone imports the records of an octoDNS zone like importZoneFile,
+// reporting the records which could not be converted and the warnings too.
+func (inst *Instance) importOctodnsZone(ctx context.Context, zone string, oz *octodnsZone, opts zoneImportOptions) (*zoneImportReport, error) {
+	report, err := inst.importZoneFile(ctx, zone, oz.rrs, opts)
+	if report != nil {
+		if len(oz.issues) > 0 {
+			report.Unsupported = append(oz.issues, report.Unsupported...)
+		}
+		report.Warnings = oz.warnings
+	}
+	return report, err
+}
+
+// addRecord converts the values of an octoDNS record.
+func (oz *octodnsZone) addRecord(name string, record *octodnsRecord) {
+	recordType := strings.ToUpper(record.Type)
+	if !m.IsSupportedRecordType(recordType) || recordType == "SOA" {
+		oz.issues = append(oz.issues, &zoneImportIssue{Name: name, Type: recordType, Reason: "the record type is not supported"})
+		return
+	}
+	if !record.Geo.IsZero() {
+		oz.warnings = append(oz.warnings, fmt.Sprintf("%s %s: geo is ignored, only the default values are imported", name, recordType))
+	}
+	if !record.Dynamic.IsZero() {
+		oz.warnings = append(oz.warnings, fmt.Sprintf("%s %s: dynamic is ignored, only the default values are imported", name, recordType))
+	}
+	ttl := uint32(octodnsDefaultTtl)
+	if record.Ttl != nil {
+		ttl = *record.Ttl
+	}
+	values := record.Values
+	if !record.Value.IsZero() {
+		values = append([]yaml.Node{record.Value}, values...)
+	}
+	for _, value := range values {
+		rr, err := octodnsRR(name, ttl, recordType, &value)
+		if err != nil {
+			oz.issues = append(oz.issues, &zoneImportIssue{Name: name, Type: recordType, Reason: err.Error()})
+			continue
+		}
+		oz.rrs = append(oz.rrs, rr)
+	}
+}
+
+// octodnsRR converts a value of an octoDNS record into a DNS resource record.
+func octodnsRR(name string, ttl uint32, recordType string, value *yaml.Node) (dns.RR, error) {
+	hdr := dns.RR_Header{Name: name, Class: dns.ClassINET, Ttl: ttl, Rrtype: dns.StringToType[recordType]}
+	var text string
+	switch recordType {
+	case "A", "AAAA", "CNAME", "NS", "TXT":
+		if err := value.Decode(&text); err != nil {
+			return nil, fmt.Errorf("invalid %s value: %w", recordType, err)
+		}
+	}
+	switch recordType {
+	case "A", "AAAA":
+		// an IPv4 address is only valid for A, any other address only for AAAA
+		ip := net.ParseIP(text)
+		if ip == nil || (recordType == "A") != (ip.To4() != nil) {
+			return nil, fmt.Errorf("invalid %s value %q", recordType, text)
+		}
+		if recordType == "A" {
+			return &dns.A{Hdr: hdr, A: ip.To4()}, nil
+		}
+		return &dns.AAAA{Hdr: hdr, AAAA: ip}, nil
+	case "CNAME":
+		return &dns.CNAME{Hdr: hdr, Target: dns.Fqdn(text)}, nil
+	case "NS":
+		return &dns.NS{Hdr: hdr, Ns: dns.Fqdn(text)}, nil
+	case "TXT":
+		return &dns.TXT{Hdr: hdr, Txt: []string{strings.ReplaceAll(text, `\;`, ";")}}, nil
+	case "MX":
+		var mx octodnsMxValue
+		if err := value.Decode(&mx); err != nil {
+			return nil, fmt.Errorf("invalid MX value: %w", err)
+		}
+		if mx.Exchange == "" {
+			mx.Exchange = mx.Value
+		}
+		if mx.Preference == nil {
+			mx.Preference = &mx.Priority
+		}
+		return &dns.MX{Hdr: hdr, Mx: dns.Fqdn(mx.Exchange), Preference: *mx.Preference}, nil
+	case "SRV":
+		var srv octodnsSrvValue
+		if err := value.Decode(&srv); err != nil {
+			return nil, fmt.Errorf("invalid SRV value: %w", err)
+		}
+		return &dns.SRV{Hdr: hdr, Priority: srv.Priority, Weight: srv.Weight, Port: srv.Port, Target: dns.Fqdn(srv.Target)}, nil
+	case "CAA":
+		var caa octodnsCaaValue
+		if err := value.Decode(&caa); err != nil {
+			return nil, fmt.Errorf("invalid CAA value: %w", err)
+		}
+		return &dns.CAA{Hdr: hdr, Flag: caa.Flags, Tag: caa.Tag, Value: caa.Value}, nil
+	default:
+		return nil, fmt.Errorf("unsupported record type: %s", recordType)
+	}
+}
+
+// exportOctodnsYaml renders the enabled records of the zone, as they are served, in the YAML format of the
+// octoDNS YamlProvider, template records expanded. SOA records are left out as octoDNS does not manage them.
+func (inst *Instance) exportOctodnsYaml(app core.App, zone string) ([]byte, error) {
+	if _, err := app.FindFirstRecordByData(zoneCollectionName, "name", zone); err != nil {
+		return nil, apis.NewNotFoundError("Zone not found.", err)
+	}
+	recs, err := app.FindRecordsByFilter(recordCollectionName, "zone = {:zone} && disabled = false && record_type != 'SOA'",
+		"name,record_type,created", 0, 0, dbx.Params{"zone": zone})
+	if err != nil {
+		return nil, err
+	}
+	rrs := inst.composeZoneRRs(recs)
+	sortCanonical(rrs)
+	return yaml.Marshal(octodnsRecords(zone, rrs))
+}
+
+// octodnsRecords groups the records by name relative to the zone into octoDNS records, one mapping for names with
+// records of a single type, a list of mappings by type otherwise. The TTL of a record is the TTL of its first value.
+func octodnsRecords(zone string, rrs []dns.RR) map[string]any {
+	records := make(map[string][]map[string]any)
+	for _, rr := range rrs {
+		name := strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(rr.Header().Name), zone), ".")
+		recordType := dns.TypeToString[rr.Header().Rrtype]
+		var record map[string]any
+		if n := len(records[name]); n > 0 && records[name][n-1]["type"] == recordType {
+			record = records[name][n-1]
+		} else {
+			record = map[string]any{"type": recordType, "ttl": rr.Header().Ttl}
+			records[name] = append(records[name], record)
+		}
+		values, _ := record["values"].([]any)
+		record["values"] = append(values, octodnsValue(rr))
+	}
+
+	out := make(map[string]any, len(records))
+	for name, byType := range records {
+		for _, record := range byType {
+			// single values are written as value, like octoDNS does
+			if values := record["values"].([]any); len(values) == 1 {
+				delete(record, "values")
+				record["value"] = values[0]
+			}
+		}
+		if len(byType) == 1 {
+			out[name] = byType[0]
+		} else {
+			out[name] = byType
+		}
+	}
+	return out
+}
+
+// octodnsValue returns the octoDNS value of a record.
+func octodnsValue(rr dns.RR) any {
+	switch r := rr.(type) {
+	case *dns.A:
+		return r.A.String()
+	case *dns.AAAA:
+		return r.AAAA.String()
+	case *dns.CNAME:
+		return r.Target
+	case *dns.NS:
+		return r.Ns
+	case *dns.TXT:
+		return strings.ReplaceAll(strings.Join(r.Txt, ""), ";", `\;`)
+	case *dns.MX:
+		return map[string]any{"exchange": r.Mx, "preference": r.Preference}
+	case *dns.SRV:
+		return map[string]any{"port": r.Port, "priority": r.Priority, "target": r.Target, "weight": r.Weight}
+	case *dns.CAA:
+		return map[string]any{"flags": r.Flag, "tag": r.Tag, "value": r.Value}
+	default:
+		return m.RdataString(rr)
+	}
+}
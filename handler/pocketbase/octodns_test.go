@@ -0,0 +1,120 @@
+package pocketbase
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseOctodnsYaml(t *testing.T) {
+	zoneYaml := `---
+'':
+  - type: NS
+    values:
+      - ns1.example.com.
+      - ns2.example.com.
+  - type: MX
+    values:
+      - exchange: mx1.example.com.
+        preference: 10
+      - priority: 20
+        value: mx2.example.com.
+  - type: TXT
+    value: v=spf1 -all\; comment
+  - type: SOA
+    value: ignored
+www:
+  type: A
+  ttl: 300
+  value: 192.0.2.10
+  geo:
+    EU:
+      - 192.0.2.20
+_sip._tcp:
+  type: SRV
+  value:
+    port: 5060
+    priority: 10
+    target: sip.example.com.
+    weight: 20
+ptr:
+  type: PTR
+  value: www.example.com.
+bad:
+  type: AAAA
+  value: not an address
+mixed:
+  - type: A
+    value: 2001:db8::1
+  - type: AAAA
+    value: 192.0.2.30
+`
+	oz, err := parseOctodnsYaml(strings.NewReader(zoneYaml), "example.com.")
+	require.NoError(t, err)
+
+	got := make([]string, 0, len(oz.rrs))
+	for _, rr := range oz.rrs {
+		got = append(got, rr.String())
+	}
+	assert.Equal(t, []string{
+		"example.com.\t3600\tIN\tNS\tns1.example.com.",
+		"example.com.\t3600\tIN\tNS\tns2.example.com.",
+		"example.com.\t3600\tIN\tMX\t10 mx1.example.com.",
+		"example.com.\t3600\tIN\tMX\t20 mx2.example.com.",
+		"example.com.\t3600\tIN\tTXT\t\"v=spf1 -all; comment\"",
+		"_sip._tcp.example.com.\t3600\tIN\tSRV\t10 20 5060 sip.example.com.",
+		"www.example.com.\t300\tIN\tA\t192.0.2.10",
+	}, got)
+
+	issues := make([]string, 0, len(oz.issues))
+	for _, issue := range oz.issues {
+		issues = append(issues, issue.Name+" "+issue.Type)
+	}
+	assert.Equal(t, []string{"example.com. SOA", "bad.example.com. AAAA", "mixed.example.com. A",
+		"mixed.example.com. AAAA", "ptr.example.com. PTR"}, issues)
+	assert.Equal(t, []string{"www.example.com. A: geo is ignored, only the default values are imported"}, oz.warnings)
+
+	_, err = parseOctodnsYaml(strings.NewReader("www: [\n"), "example.com.")
+	assert.Error(t, err)
+}
+
+func TestOctodnsRecords(t *testing.T) {
+	rrs := make([]dns.RR, 0)
+	for _, s := range []string{
+		"example.com. 3600 IN NS ns1.example.com.",
+		"example.com. 3600 IN NS ns2.example.com.",
+		"example.com. 600 IN TXT \"v=spf1 -all; comment\"",
+		"www.example.com. 300 IN A 192.0.2.10",
+	} {
+		rr, err := dns.NewRR(s)
+		require.NoError(t, err)
+		rrs = append(rrs, rr)
+	}
+
+	out, err := yaml.Marshal(octodnsRecords("example.com.", rrs))
+	require.NoError(t, err)
+	assert.Equal(t, `"":
+    - ttl: 3600
+      type: NS
+      values:
+        - ns1.example.com.
+        - ns2.example.com.
+    - ttl: 600
+      type: TXT
+      value: v=spf1 -all\; comment
+www:
+    ttl: 300
+    type: A
+    value: 192.0.2.10
+`, string(out))
+
+	// the export reads back as the same records
+	oz, err := parseOctodnsYaml(strings.NewReader(string(out)), "example.com.")
+	require.NoError(t, err)
+	assert.Empty(t, oz.issues)
+	assert.Len(t, oz.rrs, len(rrs))
+}
@@ -0,0 +1,129 @@
+package pb_migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		jsonData := `{
+			"createRule": null,
+			"deleteRule": null,
+			"fields": [
+				{
+					"autogeneratePattern": "[1-9][0-9]{17}",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 18,
+					"min": 1,
+					"name": "id",
+					"pattern": "^[1-9][0-9]+$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text4166911607",
+					"max": 0,
+					"min": 0,
+					"name": "username",
+					"pattern": "",
+					"presentable": true,
+					"primaryKey": false,
+					"required": true,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"cost": 0,
+					"hidden": true,
+					"id": "password901924565",
+					"max": 0,
+					"min": 0,
+					"name": "password",
+					"pattern": "",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "password"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text3252000302",
+					"max": 63,
+					"min": 1,
+					"name": "subdomain",
+					"pattern": "^[a-z0-9-]+$",
+					"presentable": false,
+					"primaryKey": false,
+					"required": true,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "json2843273087",
+					"maxSize": 0,
+					"name": "allow_from",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "json"
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"hidden": false,
+					"id": "autodate3332085495",
+					"name": "updated",
+					"onCreate": true,
+					"onUpdate": true,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				}
+			],
+			"id": "pbc_1261745821",
+			"indexes": [
+				"CREATE UNIQUE INDEX ` + "`" + `idx_acme_accounts_username` + "`" + ` ON ` + "`" + `coredns_acme_accounts` + "`" + ` (` + "`" + `username` + "`" + `)",
+				"CREATE UNIQUE INDEX ` + "`" + `idx_acme_accounts_subdomain` + "`" + ` ON ` + "`" + `coredns_acme_accounts` + "`" + ` (` + "`" + `subdomain` + "`" + `)"
+			],
+			"listRule": null,
+			"name": "coredns_acme_accounts",
+			"system": false,
+			"type": "base",
+			"updateRule": null,
+			"viewRule": null
+		}`
+
+		collection := &core.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_1261745821")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
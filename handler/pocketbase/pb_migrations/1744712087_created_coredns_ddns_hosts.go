@@ -0,0 +1,158 @@
+package pb_migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		jsonData := `{
+			"createRule": null,
+			"deleteRule": null,
+			"fields": [
+				{
+					"autogeneratePattern": "[1-9][0-9]{17}",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 18,
+					"min": 1,
+					"name": "id",
+					"pattern": "^[1-9][0-9]+$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text3847340049",
+					"max": 0,
+					"min": 0,
+					"name": "hostname",
+					"pattern": "",
+					"presentable": true,
+					"primaryKey": false,
+					"required": true,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text2699804679",
+					"max": 0,
+					"min": 0,
+					"name": "zone",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": true,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text4166911607",
+					"max": 0,
+					"min": 0,
+					"name": "username",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": true,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"cost": 0,
+					"hidden": true,
+					"id": "password901924565",
+					"max": 0,
+					"min": 0,
+					"name": "password",
+					"pattern": "",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "password"
+				},
+				{
+					"hidden": false,
+					"id": "number2750318623",
+					"max": null,
+					"min": 0,
+					"name": "ttl",
+					"onlyInt": true,
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "number"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text3408347291",
+					"max": 0,
+					"min": 0,
+					"name": "last_ip",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"hidden": false,
+					"id": "autodate3332085495",
+					"name": "updated",
+					"onCreate": true,
+					"onUpdate": true,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				}
+			],
+			"id": "pbc_1740682365",
+			"indexes": [
+				"CREATE UNIQUE INDEX ` + "`" + `idx_ddns_hosts_hostname` + "`" + ` ON ` + "`" + `coredns_ddns_hosts` + "`" + ` (` + "`" + `hostname` + "`" + `)"
+			],
+			"listRule": null,
+			"name": "coredns_ddns_hosts",
+			"system": false,
+			"type": "base",
+			"updateRule": null,
+			"viewRule": null
+		}`
+
+		collection := &core.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_1740682365")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,50 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_186858105")
+		if err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(1, []byte(`{
+			"cascadeDelete": true,
+			"collectionId": "pbc_962678492",
+			"hidden": false,
+			"id": "relation2670477227",
+			"maxSelect": 1,
+			"minSelect": 0,
+			"name": "zone_id",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "relation"
+		}`)); err != nil {
+			return err
+		}
+
+		// add index
+		collection.AddIndex("idx_coredns_records_zone_id", false, "`zone_id`", "")
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_186858105")
+		if err != nil {
+			return err
+		}
+
+		// remove index
+		collection.RemoveIndex("idx_coredns_records_zone_id")
+
+		// remove field
+		collection.Fields.RemoveById("relation2670477227")
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,52 @@
+package pb_migrations
+
+import (
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Creates a zone row for every distinct zone of the records and links the records to it.
+func init() {
+	m.Register(func(app core.App) error {
+		zonesColl, err := app.FindCollectionByNameOrId("pbc_962678492")
+		if err != nil {
+			return err
+		}
+
+		var zones []struct {
+			Zone string `db:"zone"`
+		}
+		err = app.DB().Select("zone").Distinct(true).From("coredns_records").All(&zones)
+		if err != nil {
+			return err
+		}
+
+		for _, z := range zones {
+			if z.Zone == "" {
+				continue
+			}
+			zone := core.NewRecord(zonesColl)
+			zone.Set("name", z.Zone)
+			zone.Set("enabled", true)
+			zone.Set("serial", time.Now().Unix())
+			if err = app.Save(zone); err != nil {
+				return err
+			}
+			_, err = app.DB().Update("coredns_records",
+				dbx.Params{"zone_id": zone.Id}, dbx.HashExp{"zone": z.Zone}).Execute()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(app core.App) error {
+		if _, err := app.DB().Update("coredns_records", dbx.Params{"zone_id": ""}, nil).Execute(); err != nil {
+			return err
+		}
+		_, err := app.DB().Delete("coredns_zones", nil).Execute()
+		return err
+	})
+}
@@ -0,0 +1,82 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Replaces the enabled flag of the zones with the state of the zone.
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_962678492")
+		if err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(12, []byte(`{
+			"hidden": false,
+			"id": "select2744374011",
+			"maxSelect": 1,
+			"name": "state",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "select",
+			"values": [
+				"active",
+				"disabled",
+				"frozen"
+			]
+		}`)); err != nil {
+			return err
+		}
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		_, err = app.DB().NewQuery("UPDATE {{coredns_zones}} SET [[state]] = CASE WHEN [[enabled]] THEN 'active' ELSE 'disabled' END").
+			Execute()
+		if err != nil {
+			return err
+		}
+
+		// remove field
+		collection.Fields.RemoveById("bool1358543748")
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_962678492")
+		if err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(12, []byte(`{
+			"hidden": false,
+			"id": "bool1358543748",
+			"name": "enabled",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "bool"
+		}`)); err != nil {
+			return err
+		}
+		if err := app.Save(collection); err != nil {
+			return err
+		}
+
+		_, err = app.DB().Update("coredns_zones", dbx.Params{"enabled": true},
+			dbx.NewExp("[[state]] != 'disabled'")).Execute()
+		if err != nil {
+			return err
+		}
+
+		// remove field
+		collection.Fields.RemoveById("select2744374011")
+
+		return app.Save(collection)
+	})
+}
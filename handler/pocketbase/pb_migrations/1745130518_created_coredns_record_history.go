@@ -0,0 +1,192 @@
+package pb_migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		jsonData := `{
+			"createRule": null,
+			"deleteRule": null,
+			"fields": [
+				{
+					"autogeneratePattern": "[1-9][0-9]{17}",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 18,
+					"min": 1,
+					"name": "id",
+					"pattern": "^[1-9][0-9]+$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text2603917201",
+					"max": 0,
+					"min": 0,
+					"name": "record",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": true,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text2699804679",
+					"max": 0,
+					"min": 0,
+					"name": "zone",
+					"pattern": "",
+					"presentable": true,
+					"primaryKey": false,
+					"required": true,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text1579384326",
+					"max": 0,
+					"min": 0,
+					"name": "name",
+					"pattern": "",
+					"presentable": true,
+					"primaryKey": false,
+					"required": true,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text1768539901",
+					"max": 0,
+					"min": 0,
+					"name": "record_type",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": true,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "select427927149",
+					"maxSelect": 1,
+					"name": "operation",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "select",
+					"values": [
+						"create",
+						"update",
+						"delete"
+					]
+				},
+				{
+					"hidden": false,
+					"id": "select3163973082",
+					"maxSelect": 1,
+					"name": "actor_type",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "select",
+					"values": [
+						"superuser",
+						"auth",
+						"token",
+						"ddns",
+						"acme-dns",
+						"external-dns",
+						"system"
+					]
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text1148540665",
+					"max": 0,
+					"min": 0,
+					"name": "actor",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "json3627769262",
+					"maxSize": 0,
+					"name": "before",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "json"
+				},
+				{
+					"hidden": false,
+					"id": "json2302955073",
+					"maxSize": 0,
+					"name": "after",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "json"
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				}
+			],
+			"id": "pbc_2332210139",
+			"indexes": [
+				"CREATE INDEX ` + "`" + `idx_record_history_record` + "`" + ` ON ` + "`" + `coredns_record_history` + "`" + ` (` + "`" + `record` + "`" + `)",
+				"CREATE INDEX ` + "`" + `idx_record_history_zone_name` + "`" + ` ON ` + "`" + `coredns_record_history` + "`" + ` (` + "`" + `zone` + "`" + `, ` + "`" + `name` + "`" + `)"
+			],
+			"listRule": null,
+			"name": "coredns_record_history",
+			"system": false,
+			"type": "base",
+			"updateRule": null,
+			"viewRule": null
+		}`
+
+		collection := &core.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2332210139")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
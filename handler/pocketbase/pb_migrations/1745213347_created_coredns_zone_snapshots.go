@@ -0,0 +1,176 @@
+package pb_migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		jsonData := `{
+			"createRule": null,
+			"deleteRule": null,
+			"fields": [
+				{
+					"autogeneratePattern": "[1-9][0-9]{17}",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 18,
+					"min": 1,
+					"name": "id",
+					"pattern": "^[1-9][0-9]+$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text2699804679",
+					"max": 0,
+					"min": 0,
+					"name": "zone",
+					"pattern": "",
+					"presentable": true,
+					"primaryKey": false,
+					"required": true,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text245846248",
+					"max": 0,
+					"min": 0,
+					"name": "label",
+					"pattern": "",
+					"presentable": true,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "select443223901",
+					"maxSelect": 1,
+					"name": "trigger",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "select",
+					"values": [
+						"manual",
+						"auto"
+					]
+				},
+				{
+					"hidden": false,
+					"id": "select3163973082",
+					"maxSelect": 1,
+					"name": "actor_type",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "select",
+					"values": [
+						"superuser",
+						"auth",
+						"token",
+						"ddns",
+						"acme-dns",
+						"external-dns",
+						"system"
+					]
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text1148540665",
+					"max": 0,
+					"min": 0,
+					"name": "actor",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "number3547646428",
+					"max": null,
+					"min": 0,
+					"name": "serial",
+					"onlyInt": true,
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "number"
+				},
+				{
+					"hidden": false,
+					"id": "number75687230",
+					"max": null,
+					"min": 0,
+					"name": "record_count",
+					"onlyInt": true,
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "number"
+				},
+				{
+					"hidden": false,
+					"id": "json2627557446",
+					"maxSize": 0,
+					"name": "records",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "json"
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				}
+			],
+			"id": "pbc_3630225061",
+			"indexes": [
+				"CREATE INDEX ` + "`" + `idx_zone_snapshots_zone` + "`" + ` ON ` + "`" + `coredns_zone_snapshots` + "`" + ` (` + "`" + `zone` + "`" + `, ` + "`" + `created` + "`" + `)"
+			],
+			"listRule": null,
+			"name": "coredns_zone_snapshots",
+			"system": false,
+			"type": "base",
+			"updateRule": null,
+			"viewRule": null
+		}`
+
+		collection := &core.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3630225061")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,119 @@
+package pb_migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		jsonData := `{
+			"createRule": "@collection.coredns_zone_members:access.zone ?= zone && @collection.coredns_zone_members:access.user ?= @request.auth.id && @collection.coredns_zone_members:access.role ?= \"owner\"",
+			"deleteRule": "@collection.coredns_zone_members:access.zone ?= zone && @collection.coredns_zone_members:access.user ?= @request.auth.id && @collection.coredns_zone_members:access.role ?= \"owner\"",
+			"fields": [
+				{
+					"autogeneratePattern": "[1-9][0-9]{17}",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 18,
+					"min": 1,
+					"name": "id",
+					"pattern": "^[1-9][0-9]+$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"cascadeDelete": true,
+					"collectionId": "pbc_962678492",
+					"hidden": false,
+					"id": "relation2699804679",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "zone",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"cascadeDelete": true,
+					"collectionId": "_pb_users_auth_",
+					"hidden": false,
+					"id": "relation2375276105",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "user",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"hidden": false,
+					"id": "select1466534506",
+					"maxSelect": 1,
+					"name": "role",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "select",
+					"values": [
+						"owner",
+						"editor",
+						"viewer"
+					]
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"hidden": false,
+					"id": "autodate3332085495",
+					"name": "updated",
+					"onCreate": true,
+					"onUpdate": true,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				}
+			],
+			"id": "pbc_2604224029",
+			"indexes": [
+				"CREATE UNIQUE INDEX ` + "`" + `idx_zone_members_zone_user` + "`" + ` ON ` + "`" + `coredns_zone_members` + "`" + ` (` + "`" + `zone` + "`" + `, ` + "`" + `user` + "`" + `)",
+				"CREATE INDEX ` + "`" + `idx_zone_members_user` + "`" + ` ON ` + "`" + `coredns_zone_members` + "`" + ` (` + "`" + `user` + "`" + `)"
+			],
+			"listRule": "@collection.coredns_zone_members:access.zone ?= zone && @collection.coredns_zone_members:access.user ?= @request.auth.id",
+			"name": "coredns_zone_members",
+			"system": false,
+			"type": "base",
+			"updateRule": "@collection.coredns_zone_members:access.zone ?= zone && @collection.coredns_zone_members:access.user ?= @request.auth.id && @collection.coredns_zone_members:access.role ?= \"owner\" && @request.body.zone:isset = false",
+			"viewRule": "@collection.coredns_zone_members:access.zone ?= zone && @collection.coredns_zone_members:access.user ?= @request.auth.id"
+		}`
+
+		collection := &core.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2604224029")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,66 @@
+package pb_migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("_pb_users_auth_")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"createRule": null,
+			"listRule": "id = @request.auth.id || (tenant != \"\" && tenant = @request.auth.tenant)",
+			"updateRule": "id = @request.auth.id && @request.body.tenant:isset = false",
+			"viewRule": "id = @request.auth.id || (tenant != \"\" && tenant = @request.auth.tenant)"
+		}`), &collection); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSON([]byte(`{
+			"cascadeDelete": false,
+			"collectionId": "pbc_358067745",
+			"hidden": false,
+			"id": "relation1314505826",
+			"maxSelect": 1,
+			"minSelect": 0,
+			"name": "tenant",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "relation"
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("_pb_users_auth_")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"createRule": "",
+			"listRule": "id = @request.auth.id",
+			"updateRule": "id = @request.auth.id",
+			"viewRule": "id = @request.auth.id"
+		}`), &collection); err != nil {
+			return err
+		}
+
+		// remove field
+		collection.Fields.RemoveById("relation1314505826")
+
+		return app.Save(collection)
+	})
+}
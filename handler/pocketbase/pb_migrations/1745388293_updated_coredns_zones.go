@@ -0,0 +1,74 @@
+package pb_migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_962678492")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"createRule": "@request.auth.collectionName = \"users\" && @request.auth.tenant != \"\"",
+			"deleteRule": "@collection.coredns_zone_members:access.zone ?= id && @collection.coredns_zone_members:access.user ?= @request.auth.id && @collection.coredns_zone_members:access.role ?= \"owner\"",
+			"listRule": "@collection.coredns_zone_members:access.zone ?= id && @collection.coredns_zone_members:access.user ?= @request.auth.id",
+			"updateRule": "@collection.coredns_zone_members:access.zone ?= id && @collection.coredns_zone_members:access.user ?= @request.auth.id && @collection.coredns_zone_members:access.role ?= \"owner\" && @request.body.tenant:isset = false",
+			"viewRule": "@collection.coredns_zone_members:access.zone ?= id && @collection.coredns_zone_members:access.user ?= @request.auth.id"
+		}`), &collection); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(15, []byte(`{
+			"cascadeDelete": false,
+			"collectionId": "pbc_358067745",
+			"hidden": false,
+			"id": "relation1314505826",
+			"maxSelect": 1,
+			"minSelect": 0,
+			"name": "tenant",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "relation"
+		}`)); err != nil {
+			return err
+		}
+
+		// add index
+		collection.AddIndex("idx_coredns_zones_tenant", false, "`tenant`", "")
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_962678492")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"createRule": null,
+			"deleteRule": null,
+			"listRule": null,
+			"updateRule": null,
+			"viewRule": null
+		}`), &collection); err != nil {
+			return err
+		}
+
+		// remove index
+		collection.RemoveIndex("idx_coredns_zones_tenant")
+
+		// remove field
+		collection.Fields.RemoveById("relation1314505826")
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,108 @@
+package pb_migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Rules granting the members of a zone access to its records, history, snapshots and change sets:
+// viewers may read them, editors and owners may change them as well. The members are joined by
+// @collection, as back relations do not match the numeric record ids.
+const (
+	memberAccess = `@collection.coredns_zone_members:access`
+	editorRole   = ` && (` + memberAccess + `.role ?= "owner" || ` + memberAccess + `.role ?= "editor")`
+
+	recordMemberRule = memberAccess + `.zone ?= zone_id && ` + memberAccess + `.user ?= @request.auth.id`
+	recordEditorRule = recordMemberRule + editorRole
+
+	zoneNameMemberRule = memberAccess + `.zone.name ?= zone && ` + memberAccess + `.user ?= @request.auth.id`
+	zoneNameEditorRule = zoneNameMemberRule + editorRole
+
+	changeSetMemberRule = memberAccess + `.zone.name ?= change_set.zone && ` + memberAccess + `.user ?= @request.auth.id`
+	changeSetEditorRule = changeSetMemberRule + editorRole
+)
+
+func init() {
+	rules := map[string]map[string]string{
+		// coredns_records, records can not be moved to another zone
+		"pbc_186858105": {
+			"listRule":   recordMemberRule,
+			"viewRule":   recordMemberRule,
+			"createRule": recordEditorRule,
+			"updateRule": recordEditorRule + ` && @request.body.zone:isset = false && @request.body.zone_id:isset = false`,
+			"deleteRule": recordEditorRule,
+		},
+		// coredns_record_history
+		"pbc_2332210139": {
+			"listRule": zoneNameMemberRule,
+			"viewRule": zoneNameMemberRule,
+		},
+		// coredns_zone_snapshots
+		"pbc_3630225061": {
+			"listRule": zoneNameMemberRule,
+			"viewRule": zoneNameMemberRule,
+		},
+		// coredns_change_sets
+		"pbc_3930784757": {
+			"listRule":   zoneNameMemberRule,
+			"viewRule":   zoneNameMemberRule,
+			"createRule": zoneNameEditorRule,
+			"updateRule": zoneNameEditorRule,
+			"deleteRule": zoneNameEditorRule,
+		},
+		// coredns_staged_changes
+		"pbc_3951821007": {
+			"listRule":   changeSetMemberRule,
+			"viewRule":   changeSetMemberRule,
+			"createRule": changeSetEditorRule,
+			"updateRule": changeSetEditorRule,
+			"deleteRule": changeSetEditorRule,
+		},
+	}
+
+	m.Register(func(app core.App) error {
+		for id, collRules := range rules {
+			collection, err := app.FindCollectionByNameOrId(id)
+			if err != nil {
+				return err
+			}
+			for name, rule := range collRules {
+				setRule(collection, name, &rule)
+			}
+			if err = app.Save(collection); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(app core.App) error {
+		for id, collRules := range rules {
+			collection, err := app.FindCollectionByNameOrId(id)
+			if err != nil {
+				return err
+			}
+			for name := range collRules {
+				setRule(collection, name, nil)
+			}
+			if err = app.Save(collection); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// setRule sets the API rule of the collection by its name, nil restricts the action to superusers.
+func setRule(collection *core.Collection, name string, rule *string) {
+	switch name {
+	case "listRule":
+		collection.ListRule = rule
+	case "viewRule":
+		collection.ViewRule = rule
+	case "createRule":
+		collection.CreateRule = rule
+	case "updateRule":
+		collection.UpdateRule = rule
+	case "deleteRule":
+		collection.DeleteRule = rule
+	}
+}
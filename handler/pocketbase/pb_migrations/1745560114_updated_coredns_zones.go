@@ -0,0 +1,41 @@
+package pb_migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_962678492")
+		if err != nil {
+			return err
+		}
+
+		// zones are claimed through verified zone claims, and can not be renamed by their owners
+		if err := json.Unmarshal([]byte(`{
+			"createRule": null,
+			"updateRule": "@collection.coredns_zone_members:access.zone ?= id && @collection.coredns_zone_members:access.user ?= @request.auth.id && @collection.coredns_zone_members:access.role ?= \"owner\" && @request.body.tenant:isset = false && @request.body.name:isset = false"
+		}`), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_962678492")
+		if err != nil {
+			return err
+		}
+
+		if err := json.Unmarshal([]byte(`{
+			"createRule": "@request.auth.collectionName = \"users\" && @request.auth.tenant != \"\"",
+			"updateRule": "@collection.coredns_zone_members:access.zone ?= id && @collection.coredns_zone_members:access.user ?= @request.auth.id && @collection.coredns_zone_members:access.role ?= \"owner\" && @request.body.tenant:isset = false"
+		}`), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	})
+}
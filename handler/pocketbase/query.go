@@ -1,10 +1,13 @@
 package pocketbase
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
+	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/pkg/log"
 	"github.com/miekg/dns"
 	"github.com/pocketbase/dbx"
@@ -46,7 +49,7 @@ func (inst *Instance) FetchRecords(zone string, name string, types ...string) (r
 
 // fetchSingleTypeRecords retrieves DNS records of a single type from PocketBase for a given zone and name.
 func (inst *Instance) fetchSingleTypeRecords(coll *core.Collection, zone string, name string, recordType string) (recs []*m.Record, err error) {
-	recs = inst.doFetchSingleTypeRecords(coll, zone, name, recordType)
+	recs = inst.fetchNameRecords(coll, zone, name, recordType)
 	// If no records found, chase cname records.
 	if len(recs) == 0 && recordType != "CNAME" {
 		log.Debugf("No records found in db, zone: [%s], name: [%s], will try chase CNAME", zone, name)
@@ -60,6 +63,15 @@ func (inst *Instance) fetchSingleTypeRecords(coll *core.Collection, zone string,
 	return recs, err
 }
 
+// fetchNameRecords retrieves the records of a single type at name, or the records generated by the template records
+// of the zone if there are none.
+func (inst *Instance) fetchNameRecords(coll *core.Collection, zone string, name string, recordType string) []*m.Record {
+	if recs := inst.doFetchSingleTypeRecords(coll, zone, name, recordType); len(recs) > 0 {
+		return recs
+	}
+	return inst.fetchTemplateRecords(coll, zone, name, recordType)
+}
+
 func (inst *Instance) doFetchSingleTypeRecords(coll *core.Collection, zone string, name string, recordType string) (recs []*m.Record) {
 	// if cache is enabled, try to get from cache
 	if inst.cacheCapacity > 0 {
@@ -74,7 +86,8 @@ func (inst *Instance) doFetchSingleTypeRecords(coll *core.Collection, zone strin
 		Select("name", "zone", "ttl", "record_type", "content").
 		Where(dbx.NewExp("zone = {:zone}", dbx.Params{"zone": zone})).
 		AndWhere(dbx.NewExp("name = {:name}", dbx.Params{"name": name})).
-		AndWhere(dbx.NewExp("record_type = {:record_type}", dbx.Params{"record_type": recordType}))
+		AndWhere(dbx.NewExp("record_type = {:record_type}", dbx.Params{"record_type": recordType})).
+		AndWhere(dbx.HashExp{"disabled": false})
 
 	err := q.All(&recs)
 	if err != nil {
@@ -95,7 +108,7 @@ func (inst *Instance) doFetchSingleTypeRecords(coll *core.Collection, zone strin
 func (inst *Instance) resolveCNAMEs(coll *core.Collection, zone string, name string, recordType string) (recs []*m.Record) {
 	cnameZone, cname := zone, name
 	for { // First get CNAME records for the name
-		cnameRecs := inst.doFetchSingleTypeRecords(coll, cnameZone, cname, "CNAME")
+		cnameRecs := inst.fetchNameRecords(coll, cnameZone, cname, "CNAME")
 
 		// If no CNAME records found, return empty slice
 		if len(cnameRecs) == 0 {
@@ -114,17 +127,29 @@ func (inst *Instance) resolveCNAMEs(coll *core.Collection, zone string, name str
 			break
 		}
 		targetName, targetZone := cnameRecord.Host, cnameRecord.Zone
-		if targetName == "" || targetZone == "" {
+		if targetName == "" {
 			log.Errorf("Invalid CNAME record, zone: %s, name: %s, content: %s",
 				cnameRec.Zone, cnameRec.Name, cnameRec.Content)
 			break
 		}
+		// the zone is empty for targets outside the served zones when the CNAME was saved
+		if targetZone == "" {
+			var err error
+			if targetZone, err = inst.MatchZone(targetName); err != nil {
+				log.Errorf("Failed to match zone of CNAME target, target name: %s, err: %+v", targetName, err)
+				break
+			}
+		}
 		recs = append(recs, cnameRec)
+		if targetZone == "" {
+			log.Debugf("CNAME target is outside the served zones, name: [%s], target name: [%s]", name, targetName)
+			break
+		}
 
 		log.Debugf("Resolved CNAME, name: [%s], target name: [%s], target zone: [%s]",
 			name, targetName, targetZone)
 
-		targetRecs := inst.doFetchSingleTypeRecords(coll, targetZone, targetName, recordType)
+		targetRecs := inst.fetchNameRecords(coll, targetZone, targetName, recordType)
 
 		if len(targetRecs) == 0 {
 			cnameZone, cname = targetZone, targetName
@@ -156,7 +181,7 @@ func (inst *Instance) fetchWildCardRecords(coll *core.Collection, zone string, n
 	return inst.fetchSingleTypeRecords(coll, zone, target, recordType)
 }
 
-// FetchZones retrieves all unique DNS zones from PocketBase.
+// FetchZones retrieves all DNS zones from PocketBase, whatever their state, see FetchZone for the state of a zone.
 // It first checks the cache if enabled, then queries the database if not found in cache.
 // Returns a slice of zone names and any error encountered.
 func (inst *Instance) FetchZones() (zones []string, err error) {
@@ -180,33 +205,107 @@ func (inst *Instance) FetchZones() (zones []string, err error) {
 	return
 }
 
-func (inst *Instance) fetchZonesFromDb() (zones []string, err error) {
+// MatchZone returns the most specific served zone the name belongs to,
+// or an empty string if the name is not within any served zone.
+func (inst *Instance) MatchZone(name string) (string, error) {
+	zones, err := inst.FetchZones()
+	if err != nil {
+		return "", err
+	}
+	return plugin.Zones(zones).Matches(dns.Fqdn(name)), nil
+}
+
+// FetchZoneRecords retrieves all enabled DNS records of a zone, ordered by name and record type.
+// The cache is bypassed as the result is not used to answer queries.
+func (inst *Instance) FetchZoneRecords(zone string) (recs []*m.Record, err error) {
 	coll, err := inst.pb.FindCollectionByNameOrId(recordCollectionName)
 	if err != nil {
 		log.Errorf("Failed fetching collection [%s], err: %+v", recordCollectionName, err)
 		return nil, err
 	}
+	err = inst.pb.RecordQuery(coll).
+		Select("name", "zone", "ttl", "record_type", "content", "comment", "owner").
+		Where(dbx.NewExp("zone = {:zone}", dbx.Params{"zone": zone})).
+		AndWhere(dbx.HashExp{"disabled": false}).
+		OrderBy("name", "record_type", "created").
+		All(&recs)
+	if err != nil {
+		log.Errorf("Failed to fetch zone records from db, zone: [%s], err: %+v", zone, err)
+		return nil, err
+	}
+	return recs, nil
+}
+
+func (inst *Instance) fetchZonesFromDb() (zones []string, err error) {
+	coll, err := inst.pb.FindCollectionByNameOrId(zoneCollectionName)
+	if err != nil {
+		log.Errorf("Failed fetching collection [%s], err: %+v", zoneCollectionName, err)
+		return nil, err
+	}
 	var zonesContainer []struct {
-		Zone string `db:"zone" json:"zone"`
+		Name string `db:"name" json:"name"`
 	}
 	err = inst.pb.RecordQuery(coll).
-		Select("zone").
-		Distinct(true).
+		Select("name").
+		OrderBy("name").
 		All(&zonesContainer)
 	if err == nil {
 		for _, z := range zonesContainer {
-			log.Debugf("Found zone in db, zone: %s", z.Zone)
-			zones = append(zones, z.Zone)
+			log.Debugf("Found zone in db, zone: %s", z.Name)
+			zones = append(zones, z.Name)
 		}
 	}
 	return
 }
 
+// FetchZone retrieves the settings of a zone from PocketBase.
+// It first checks the cache if enabled, then queries the database if not found in cache.
+// Returns nil if the zone does not exist.
+func (inst *Instance) FetchZone(zone string) (*m.Zone, error) {
+	// if cache is enabled, try to get from cache
+	if inst.cacheCapacity > 0 {
+		z, ok := inst.zoneSettingsCache.Get(zone)
+		if ok {
+			log.Debugf("Found zone settings in cache, zone: %s", zone)
+			return z, nil
+		}
+	}
+	coll, err := inst.pb.FindCollectionByNameOrId(zoneCollectionName)
+	if err != nil {
+		log.Errorf("Failed fetching collection [%s], err: %+v", zoneCollectionName, err)
+		return nil, err
+	}
+	z := &m.Zone{}
+	err = inst.pb.RecordQuery(coll).
+		Select("id", "name", "default_ttl", "min_ttl", "max_ttl", "soa_ns", "soa_mbox", "soa_refresh", "soa_retry", "soa_expire",
+			"soa_minttl", "serial", "state", "COALESCE(transfer_acl, '') AS transfer_acl", "owner").
+		Where(dbx.HashExp{"name": zone}).
+		Limit(1).
+		One(z)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Errorf("Failed to fetch zone from db, zone: [%s], err: %+v", zone, err)
+		return nil, err
+	}
+	// if cache is enabled, set to cache
+	if inst.cacheCapacity > 0 {
+		inst.zoneSettingsCache.Set(zone, z)
+	}
+	return z, nil
+}
+
 // Hosts retrieves and composes DNS resource records for a given zone and name.
 // It supports A, AAAA, and CNAME record types.
 // Returns a slice of DNS resource records and any error encountered.
 func (inst *Instance) Hosts(zone string, name string) (answers []dns.RR, err error) {
-	recs, err := inst.FetchRecords(zone, name, "A", "AAAA", "CNAME")
+	return inst.composer.Hosts(zone, name)
+}
+
+// Hosts retrieves and composes DNS resource records for a given zone and name, see Instance.Hosts.
+func (c *Composer) Hosts(zone string, name string) (answers []dns.RR, err error) {
+	recs, err := c.inst.FetchRecords(zone, name, "A", "AAAA", "CNAME")
 	if err != nil {
 		log.Errorf("Failed to fetch records, zone: [%s], name: [%s], err: %+v", zone, name, err)
 		return nil, err
@@ -217,21 +316,21 @@ func (inst *Instance) Hosts(zone string, name string) (answers []dns.RR, err err
 	for _, rec := range recs {
 		switch rec.RecordType {
 		case "A":
-			aRec, _, err := inst.ComposeARecord(rec)
+			aRec, _, err := c.ComposeARecord(rec)
 			if err != nil {
 				log.Errorf("Failed to compose A record, zone: [%s], name: [%s], err: %+v", zone, name, err)
 				return nil, err
 			}
 			answers = append(answers, aRec)
 		case "AAAA":
-			aaaaRec, _, err := inst.ComposeAAAARecord(rec)
+			aaaaRec, _, err := c.ComposeAAAARecord(rec)
 			if err != nil {
 				log.Errorf("Failed to compose AAAA record, zone: [%s], name: [%s], err: %+v", zone, name, err)
 				return nil, err
 			}
 			answers = append(answers, aaaaRec)
 		case "CNAME":
-			cnameRec, _, err := inst.ComposeCNAMERecord(rec)
+			cnameRec, _, err := c.ComposeCNAMERecord(rec)
 			if err != nil {
 				log.Errorf("Failed to compose CNAME record, zone: [%s], name: [%s], err: %+v", zone, name, err)
 				return nil, err
@@ -174,3 +174,48 @@ func TestFetchZones(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchTemplateRecords(t *testing.T) {
+	inst := newMigratedInstance(t)
+	importTestZone(t, inst, "example.com.", `$ORIGIN example.com.
+@      3600 IN SOA ns1 hostmaster 1 7200 3600 1209600 300
+@      3600 IN NS  ns1
+ns1    3600 IN A   192.0.2.53
+host-3  300 IN A   192.0.2.3
+*       300 IN A   192.0.2.99
+`)
+	coll, err := inst.pb.FindCollectionByNameOrId(recordCollectionName)
+	require.NoError(t, err)
+	tmpl, err := newRecord(coll, "example.com.", "host-$.example.com.", m.TemplateRecordType, 60,
+		m.TEMPLATERecord{RecordType: "A", Start: 1, Stop: 10, Template: "10.0.1.$"})
+	require.NoError(t, err)
+	require.NoError(t, inst.pb.Save(tmpl))
+
+	tests := []struct {
+		name        string
+		qName       string
+		wantName    string
+		wantContent string
+	}{
+		{name: "generated record", qName: "host-5.example.com.", wantName: "host-5.example.com.", wantContent: `{"ip":"10.0.1.5"}`},
+		{name: "concrete record hides the template", qName: "host-3.example.com.", wantName: "host-3.example.com.",
+			wantContent: `{"ip":"192.0.2.3"}`},
+		{name: "outside the range falls through to the wildcard", qName: "host-50.example.com.",
+			wantContent: `{"ip":"192.0.2.99"}`},
+		{name: "non-matching name falls through to the wildcard", qName: "other-5.example.com.",
+			wantContent: `{"ip":"192.0.2.99"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recs, err := inst.FetchRecords("example.com.", tt.qName, "A")
+			require.NoError(t, err)
+			require.Len(t, recs, 1)
+			assert.Equal(t, "A", recs[0].RecordType)
+			assert.JSONEq(t, tt.wantContent, recs[0].Content)
+			if tt.wantName != "" {
+				assert.Equal(t, tt.wantName, recs[0].Name)
+			}
+		})
+	}
+}
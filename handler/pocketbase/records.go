@@ -0,0 +1,102 @@
+package pocketbase
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// newRecord creates an unsaved record of the records collection
+// with the given zone, name, type, TTL and content.
+func newRecord(coll *core.Collection, zone, name, recordType string, ttl uint32, content any) (*core.Record, error) {
+	rec := core.NewRecord(coll)
+	rec.Set("zone", zone)
+	rec.Set("name", name)
+	rec.Set("record_type", recordType)
+	if err := setRecordContent(rec, ttl, content); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// setRecordContent marshals content to JSON and sets it together with the TTL on the record.
+func setRecordContent(rec *core.Record, ttl uint32, content any) error {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	rec.Set("ttl", ttl)
+	rec.Set("content", string(data))
+	return nil
+}
+
+// findRRSet returns the records of the given zone, name and type, in creation order.
+func findRRSet(app core.App, zone, name, recordType string) ([]*core.Record, error) {
+	return app.FindRecordsByFilter(recordCollectionName,
+		"zone = {:zone} && name = {:name} && record_type = {:type}", "created", 0, 0,
+		dbx.Params{"zone": zone, "name": name, "type": recordType})
+}
+
+// deleteRRSet deletes all records of the given zone, name and type. The zone integrity is checked once all are deleted,
+// unless the context disables the checks.
+func (inst *Instance) deleteRRSet(ctx context.Context, app core.App, zone, name, recordType string) error {
+	existing, err := findRRSet(app, zone, name, recordType)
+	if err != nil {
+		return err
+	}
+	checked := !integrityChecksDisabled(ctx)
+	ctx = WithoutIntegrityChecks(ctx)
+	for _, rec := range existing {
+		if err = app.DeleteWithContext(ctx, rec); err != nil {
+			return err
+		}
+	}
+	if !checked {
+		return nil
+	}
+	return inst.checkRecordIntegrity(app, zone, name)
+}
+
+// replaceRRSet replaces all records of the given zone, name and type with one record per content,
+// reusing the existing records where possible. The zone integrity is checked once the whole RRset is replaced,
+// unless the context disables the checks, so it should be called within a transaction.
+func (inst *Instance) replaceRRSet(ctx context.Context, app core.App, zone, name, recordType string, ttl uint32, contents []any) error {
+	coll, err := app.FindCollectionByNameOrId(recordCollectionName)
+	if err != nil {
+		return err
+	}
+	existing, err := findRRSet(app, zone, name, recordType)
+	if err != nil {
+		return err
+	}
+	checked := !integrityChecksDisabled(ctx)
+	ctx = WithoutIntegrityChecks(ctx)
+	for i, content := range contents {
+		var rec *core.Record
+		if i < len(existing) {
+			rec = existing[i]
+			// the replaced RRset is served
+			rec.Set("disabled", false)
+			err = setRecordContent(rec, ttl, content)
+		} else {
+			rec, err = newRecord(coll, zone, name, recordType, ttl, content)
+		}
+		if err != nil {
+			return err
+		}
+		if err = app.SaveWithContext(ctx, rec); err != nil {
+			return err
+		}
+	}
+	for i := len(contents); i < len(existing); i++ {
+		if err = app.DeleteWithContext(ctx, existing[i]); err != nil {
+			return err
+		}
+	}
+	if !checked {
+		return nil
+	}
+	return inst.checkRecordIntegrity(app, zone, name)
+}
@@ -0,0 +1,495 @@
+package pocketbase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/miekg/dns"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+// zoneFileContentType is the content type of zone file renderings, requested by the Accept header or ?format=zone.
+const zoneFileContentType = "text/dns"
+
+// errPreconditionFailed reports a conditional RRset request whose condition does not hold.
+var errPreconditionFailed = errors.New("precondition failed")
+
+// rrsetRecord is a record of a RRset in the typed API, its content is the JSON object of its record type.
+type rrsetRecord struct {
+	Id       string          `json:"id,omitempty"`
+	Content  json.RawMessage `json:"content"`
+	Disabled bool            `json:"disabled"`
+	Comment  string          `json:"comment"`
+	Owner    string          `json:"owner"`
+	// Managed records are reconciled from the GitOps directory and can not be changed through the API
+	Managed bool `json:"managed,omitempty"`
+}
+
+// rrset is a RRset in the typed API, the records of a RRset share its TTL.
+type rrset struct {
+	Name    string         `json:"name"`
+	Type    string         `json:"type"`
+	Ttl     uint32         `json:"ttl"`
+	Records []*rrsetRecord `json:"records"`
+}
+
+// typedZone is a zone with its RRsets in the typed API.
+type typedZone struct {
+	*m.Zone
+	RRsets []*rrset `json:"rrsets"`
+}
+
+// bindRRsetRoutes registers the typed zone and RRset routes.
+func (inst *Instance) bindRRsetRoutes(g *router.RouterGroup[*core.RequestEvent]) {
+	g.GET("/zones", inst.handleListZones).Bind(apis.RequireAuth())
+	g.GET("/zones/{zone}", inst.handleGetZone).Bind(apis.RequireAuth())
+	g.GET("/zones/{zone}/rrsets", inst.handleGetZone).Bind(apis.RequireAuth())
+	g.GET("/zones/{zone}/rrsets/{name}/{type}", inst.handleGetRRset).Bind(apis.RequireAuth())
+	g.PUT("/zones/{zone}/rrsets/{name}/{type}", inst.handlePutRRset).Bind(apis.RequireAuth())
+	g.DELETE("/zones/{zone}/rrsets/{name}/{type}", inst.handleDeleteRRset).Bind(apis.RequireAuth())
+}
+
+// handleListZones lists the zones the request may read.
+func (inst *Instance) handleListZones(e *core.RequestEvent) error {
+	var recs []*core.Record
+	var err error
+	if e.HasSuperuserAuth() {
+		recs, err = inst.pb.FindRecordsByFilter(zoneCollectionName, "", "name", 0, 0)
+	} else {
+		recs, err = memberZones(inst.pb, e.Auth.Id)
+	}
+	if err != nil {
+		return e.InternalServerError("Failed to fetch zones.", err)
+	}
+	scope, scoped := requestTokenScope(e)
+	zones := make([]*m.Zone, 0, len(recs))
+	for _, rec := range recs {
+		if !scoped || scope.matchZone(rec.GetString("name")) && scope.hasOperations(TokenRead) {
+			zones = append(zones, toModelZone(rec))
+		}
+	}
+	return e.JSON(http.StatusOK, zones)
+}
+
+// handleGetZone returns the zone with its RRsets, or the zone file of its enabled records.
+// Requests authenticated with API tokens only get the RRsets in the scope of the token.
+func (inst *Instance) handleGetZone(e *core.RequestEvent) error {
+	zone := strings.ToLower(dns.Fqdn(e.Request.PathValue("zone")))
+	if err := inst.requireMemberRole(e, zone, zoneReaders); err != nil {
+		return err
+	}
+	zoneRec, err := inst.pb.FindFirstRecordByData(zoneCollectionName, "name", zone)
+	if err != nil {
+		return e.NotFoundError("Zone not found.", err)
+	}
+	scope, scoped := requestTokenScope(e)
+	if scoped && !(scope.matchZone(zone) && scope.hasOperations(TokenRead)) {
+		return e.ForbiddenError("The API token is not allowed to read the zone.", nil)
+	}
+	recs, err := inst.pb.FindRecordsByFilter(recordCollectionName, "zone = {:zone}", "name,record_type,created", 0, 0,
+		dbx.Params{"zone": zone})
+	if err != nil {
+		return e.InternalServerError("Failed to fetch records.", err)
+	}
+	if scoped {
+		recs = filterRecords(recs, func(rec *core.Record) bool {
+			return scope.allowsRecord(TokenRead, zone, rec.GetString("name"), rec.GetString("record_type"))
+		})
+	}
+	if wantsZoneFile(e) {
+		return inst.zoneFileResponse(e, recs)
+	}
+	return e.JSON(http.StatusOK, &typedZone{Zone: toModelZone(zoneRec), RRsets: toRRsets(recs)})
+}
+
+// handleGetRRset returns a RRset with its ETag, or its zone file rendering.
+func (inst *Instance) handleGetRRset(e *core.RequestEvent) error {
+	zone, name, recordType, err := inst.rrsetRequest(e)
+	if err != nil {
+		return err
+	}
+	if err = inst.requireRRsetAccess(e, zone, name, recordType, TokenRead); err != nil {
+		return err
+	}
+	recs, err := findRRSet(inst.pb, zone, name, recordType)
+	if err != nil {
+		return e.InternalServerError("Failed to fetch RRset.", err)
+	}
+	if len(recs) == 0 {
+		return e.NotFoundError("RRset not found.", nil)
+	}
+	e.Response.Header().Set("ETag", rrsetETag(recs))
+	if wantsZoneFile(e) {
+		return inst.zoneFileResponse(e, recs)
+	}
+	return e.JSON(http.StatusOK, toRRsets(recs)[0])
+}
+
+// handlePutRRset replaces the records of a RRset, creating it if it does not exist.
+// If-Match makes the request fail with 412 unless the RRset is unchanged since the ETag was returned,
+// If-None-Match: * makes it fail unless the RRset does not exist yet.
+func (inst *Instance) handlePutRRset(e *core.RequestEvent) error {
+	zone, name, recordType, err := inst.rrsetRequest(e)
+	if err != nil {
+		return err
+	}
+	existing, err := findRRSet(inst.pb, zone, name, recordType)
+	if err != nil {
+		return e.InternalServerError("Failed to fetch RRset.", err)
+	}
+	if err = inst.requireRRsetAccess(e, zone, name, recordType, rrsetSaveOperation(existing)); err != nil {
+		return err
+	}
+	body := &rrset{}
+	if err = e.BindBody(body); err != nil {
+		return e.BadRequestError("Failed to decode RRset.", err)
+	}
+	if body.Name != "" && !strings.EqualFold(dns.Fqdn(body.Name), name) ||
+		body.Type != "" && !strings.EqualFold(body.Type, recordType) {
+		return e.BadRequestError("The name and type of the RRset must match the path.", nil)
+	}
+	if len(body.Records) == 0 {
+		return e.BadRequestError("The RRset has no records, delete it instead.", nil)
+	}
+	for _, rec := range body.Records {
+		err = m.ValidateRecord(&m.Record{Zone: zone, Name: name, RecordType: recordType, Ttl: body.Ttl, Content: string(rec.Content)})
+		if err != nil {
+			return e.BadRequestError("Invalid RRset, "+err.Error()+".", toValidationErrors(err))
+		}
+	}
+
+	ctx := WithActor(e.Request.Context(), requestActor(e))
+	var saved []*core.Record
+	created := false
+	err = inst.pb.RunInTransaction(func(txApp core.App) error {
+		existing, err := findRRSet(txApp, zone, name, recordType)
+		if err != nil {
+			return err
+		}
+		if err = checkRRsetPreconditions(e.Request, existing); err != nil {
+			return err
+		}
+		created = len(existing) == 0
+		// the RRset may have been created or deleted since the access was checked
+		if err = inst.requireRRsetAccess(e, zone, name, recordType, rrsetSaveOperation(existing)); err != nil {
+			return err
+		}
+		saved, err = inst.saveRRset(ctx, txApp, zone, name, recordType, body, existing)
+		return err
+	})
+	if errors.Is(err, errPreconditionFailed) {
+		return e.Error(http.StatusPreconditionFailed, "The RRset was changed since it was fetched.", nil)
+	}
+	if err != nil {
+		return firstApiError(e, err, "Failed to save RRset.")
+	}
+	log.Infof("Saved RRset, zone: %s, name: %s, type: %s, records: %d", zone, name, recordType, len(saved))
+
+	e.Response.Header().Set("ETag", rrsetETag(saved))
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	return e.JSON(status, toRRsets(saved)[0])
+}
+
+// handleDeleteRRset deletes the records of a RRset, If-Match makes the deletion conditional like for PUT.
+func (inst *Instance) handleDeleteRRset(e *core.RequestEvent) error {
+	zone, name, recordType, err := inst.rrsetRequest(e)
+	if err != nil {
+		return err
+	}
+	if err = inst.requireRRsetAccess(e, zone, name, recordType, TokenDelete); err != nil {
+		return err
+	}
+	ctx := WithActor(e.Request.Context(), requestActor(e))
+	err = inst.pb.RunInTransaction(func(txApp core.App) error {
+		existing, err := findRRSet(txApp, zone, name, recordType)
+		if err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			return apis.NewNotFoundError("RRset not found.", nil)
+		}
+		if err = checkRRsetPreconditions(e.Request, existing); err != nil {
+			return err
+		}
+		return inst.deleteRRSet(ctx, txApp, zone, name, recordType)
+	})
+	if errors.Is(err, errPreconditionFailed) {
+		return e.Error(http.StatusPreconditionFailed, "The RRset was changed since it was fetched.", nil)
+	}
+	if err != nil {
+		return firstApiError(e, err, "Failed to delete RRset.")
+	}
+	log.Infof("Deleted RRset, zone: %s, name: %s, type: %s", zone, name, recordType)
+	return e.NoContent(http.StatusNoContent)
+}
+
+// rrsetRequest returns the zone, name and type of a RRset route, after checking the zone exists.
+func (inst *Instance) rrsetRequest(e *core.RequestEvent) (zone string, name string, recordType string, err error) {
+	zone = strings.ToLower(dns.Fqdn(e.Request.PathValue("zone")))
+	name, err = rrsetName(zone, e.Request.PathValue("name"))
+	if err != nil {
+		return "", "", "", e.BadRequestError(err.Error(), nil)
+	}
+	recordType = strings.ToUpper(e.Request.PathValue("type"))
+	if !m.IsSupportedRecordType(recordType) {
+		return "", "", "", e.BadRequestError(fmt.Sprintf("Unsupported record type %s.", recordType), nil)
+	}
+	if _, err = inst.pb.FindFirstRecordByData(zoneCollectionName, "name", zone); err != nil {
+		return "", "", "", e.NotFoundError("Zone not found.", err)
+	}
+	return zone, name, recordType, nil
+}
+
+// requireRRsetAccess returns an error unless the request may apply the operation to the RRset,
+// readers of the zone may read it and editors may change it, within the scope of the API token of the request.
+func (inst *Instance) requireRRsetAccess(e *core.RequestEvent, zone, name, recordType, op string) error {
+	if scope, ok := requestTokenScope(e); ok && !scope.allowsRecord(op, zone, name, recordType) {
+		return e.ForbiddenError(fmt.Sprintf("The API token is not allowed to %s the RRset.", op), nil)
+	}
+	roles := zoneEditors
+	if op == TokenRead {
+		roles = zoneReaders
+	}
+	return inst.requireMemberRole(e, zone, roles)
+}
+
+// rrsetSaveOperation returns the operation saving a RRset with the existing records is.
+func rrsetSaveOperation(existing []*core.Record) string {
+	if len(existing) == 0 {
+		return TokenCreate
+	}
+	return TokenUpdate
+}
+
+// saveRRset replaces the existing records of a RRset with the records of the payload, reusing the existing records
+// in order. The zone integrity is checked once the whole RRset is saved, unless the context disables the checks
+// for a caller checking the whole zone.
+func (inst *Instance) saveRRset(ctx context.Context, app core.App, zone, name, recordType string, set *rrset,
+	existing []*core.Record) ([]*core.Record, error) {
+	coll, err := app.FindCollectionByNameOrId(recordCollectionName)
+	if err != nil {
+		return nil, err
+	}
+	checked := !integrityChecksDisabled(ctx)
+	ctx = WithoutIntegrityChecks(ctx)
+	saved := make([]*core.Record, 0, len(set.Records))
+	for i, r := range set.Records {
+		var rec *core.Record
+		if i < len(existing) {
+			rec = existing[i]
+			err = setRecordContent(rec, set.Ttl, r.Content)
+		} else {
+			rec, err = newRecord(coll, zone, name, recordType, set.Ttl, r.Content)
+		}
+		if err != nil {
+			return nil, err
+		}
+		rec.Set("disabled", r.Disabled)
+		rec.Set("comment", r.Comment)
+		rec.Set("owner", r.Owner)
+		if err = app.SaveWithContext(ctx, rec); err != nil {
+			return nil, err
+		}
+		saved = append(saved, rec)
+	}
+	for i := len(set.Records); i < len(existing); i++ {
+		if err = app.DeleteWithContext(ctx, existing[i]); err != nil {
+			return nil, err
+		}
+	}
+	if !checked {
+		return saved, nil
+	}
+	return saved, inst.checkRecordIntegrity(app, zone, name)
+}
+
+// checkRRsetPreconditions returns errPreconditionFailed unless the If-Match and If-None-Match headers of the request
+// hold for the existing records of the RRset.
+func checkRRsetPreconditions(r *http.Request, existing []*core.Record) error {
+	etag := ""
+	if len(existing) > 0 {
+		etag = rrsetETag(existing)
+	}
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && !matchETag(ifMatch, etag) {
+		return errPreconditionFailed
+	}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && matchETag(ifNoneMatch, etag) {
+		return errPreconditionFailed
+	}
+	return nil
+}
+
+// matchETag reports whether the list of entity tags of a conditional header matches the ETag,
+// "*" matches any existing RRset.
+func matchETag(header string, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// rrsetETag returns the entity tag of the records of a RRset, which changes whenever a field of a record changes.
+func rrsetETag(recs []*core.Record) string {
+	keys := make([]string, 0, len(recs))
+	for _, rec := range recs {
+		keys = append(keys, canonicalRecord(recordSnapshot(rec)))
+	}
+	sort.Strings(keys)
+	sum := sha256.Sum256([]byte(strings.Join(keys, "\n")))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// rrsetName returns the fully qualified name of a RRset within the zone, from an absolute name,
+// a name relative to the zone, or "@" for the apex of the zone.
+func rrsetName(zone string, name string) (string, error) {
+	name = strings.ToLower(name)
+	switch {
+	case name == "" || name == "@":
+		return zone, nil
+	case !dns.IsFqdn(name):
+		name = name + "." + zone
+	}
+	if _, ok := dns.IsDomainName(name); !ok {
+		return "", fmt.Errorf("the name %s is not a valid domain name", name)
+	}
+	if !dns.IsSubDomain(zone, name) {
+		return "", fmt.Errorf("the name %s is not within zone %s", name, zone)
+	}
+	return name, nil
+}
+
+// toRRsets groups records ordered by name and type into RRsets.
+func toRRsets(recs []*core.Record) []*rrset {
+	sets := make([]*rrset, 0)
+	var last *rrset
+	for _, rec := range recs {
+		if last == nil || last.Name != rec.GetString("name") || last.Type != rec.GetString("record_type") {
+			last = &rrset{Name: rec.GetString("name"), Type: rec.GetString("record_type"), Ttl: uint32(rec.GetInt("ttl"))}
+			sets = append(sets, last)
+		}
+		last.Records = append(last.Records, &rrsetRecord{
+			Id:       rec.Id,
+			Content:  json.RawMessage(rec.GetString("content")),
+			Disabled: rec.GetBool("disabled"),
+			Comment:  rec.GetString("comment"),
+			Owner:    rec.GetString("owner"),
+			Managed:  rec.GetBool("managed"),
+		})
+	}
+	return sets
+}
+
+// toModelZone converts a record of the zones collection into its model.
+func toModelZone(rec *core.Record) *m.Zone {
+	transferAcl := rec.GetString("transfer_acl")
+	if transferAcl == "null" {
+		transferAcl = ""
+	}
+	return &m.Zone{
+		Id:          rec.Id,
+		Name:        rec.GetString("name"),
+		DefaultTtl:  uint32(rec.GetInt("default_ttl")),
+		MinTtl:      uint32(rec.GetInt("min_ttl")),
+		MaxTtl:      uint32(rec.GetInt("max_ttl")),
+		SoaNs:       rec.GetString("soa_ns"),
+		SoaMBox:     rec.GetString("soa_mbox"),
+		SoaRefresh:  uint32(rec.GetInt("soa_refresh")),
+		SoaRetry:    uint32(rec.GetInt("soa_retry")),
+		SoaExpire:   uint32(rec.GetInt("soa_expire")),
+		SoaMinTtl:   uint32(rec.GetInt("soa_minttl")),
+		Serial:      uint32(rec.GetInt("serial")),
+		State:       rec.GetString("state"),
+		TransferAcl: transferAcl,
+		Owner:       rec.GetString("owner"),
+	}
+}
+
+// memberZones returns the zones the user is a member of, ordered by name.
+func memberZones(app core.App, userId string) ([]*core.Record, error) {
+	members, err := app.FindRecordsByFilter(zoneMemberCollectionName, "user = {:user}", "", 0, 0,
+		dbx.Params{"user": userId})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.GetString("zone"))
+	}
+	zones, err := app.FindRecordsByIds(zoneCollectionName, ids)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(zones, func(i, j int) bool { return zones[i].GetString("name") < zones[j].GetString("name") })
+	return zones, nil
+}
+
+// filterRecords returns the records for which keep returns true.
+func filterRecords(recs []*core.Record, keep func(rec *core.Record) bool) []*core.Record {
+	kept := make([]*core.Record, 0, len(recs))
+	for _, rec := range recs {
+		if keep(rec) {
+			kept = append(kept, rec)
+		}
+	}
+	return kept
+}
+
+// wantsZoneFile reports whether the request asks for a zone file rendering instead of JSON.
+func wantsZoneFile(e *core.RequestEvent) bool {
+	return e.Request.URL.Query().Get("format") == "zone" ||
+		strings.Contains(e.Request.Header.Get("Accept"), zoneFileContentType)
+}
+
+// zoneFileResponse answers with the enabled records as they are served, in zone file format.
+func (inst *Instance) zoneFileResponse(e *core.RequestEvent, recs []*core.Record) error {
+	var sb strings.Builder
+	for _, rec := range recs {
+		if rec.GetBool("disabled") {
+			continue
+		}
+		rrs, err := inst.composeRecordRRs(toModelRecord(rec))
+		if err != nil {
+			log.Errorf("Failed to compose record, id: %s, err: %+v", rec.Id, err)
+			continue
+		}
+		for _, rr := range rrs {
+			sb.WriteString(rr.String())
+			sb.WriteString("\n")
+		}
+	}
+	return e.Blob(http.StatusOK, zoneFileContentType+"; charset=utf-8", []byte(sb.String()))
+}
+
+// firstApiError answers with err if it is an API error, or with an internal server error with the message otherwise.
+func firstApiError(e *core.RequestEvent, err error, message string) error {
+	var apiErr *router.ApiError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	var vErrs interface{ Filter() error }
+	if errors.As(err, &vErrs) {
+		return e.BadRequestError(message, err)
+	}
+	return e.InternalServerError(message, err)
+}
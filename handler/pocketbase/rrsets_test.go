@@ -0,0 +1,67 @@
+package pocketbase
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRRsetName(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{name: "apex", input: "@", expected: "example.com."},
+		{name: "relative", input: "WWW", expected: "www.example.com."},
+		{name: "absolute", input: "mail.example.com.", expected: "mail.example.com."},
+		{name: "absolute apex", input: "example.com.", expected: "example.com."},
+		{name: "outside zone", input: "www.example.org.", wantErr: true},
+		{name: "invalid", input: "a..b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, err := rrsetName("example.com.", tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, name)
+		})
+	}
+}
+
+func TestRRsetPreconditions(t *testing.T) {
+	coll := core.NewBaseCollection(recordCollectionName)
+	coll.Fields.Add(&core.TextField{Name: "name"}, &core.TextField{Name: "content"}, &core.NumberField{Name: "ttl"})
+	rec := core.NewRecord(coll)
+	rec.Set("name", "www.example.com.")
+	rec.Set("content", `{"ip": "192.0.2.1"}`)
+	rec.Set("ttl", 300)
+	existing := []*core.Record{rec}
+	etag := rrsetETag(existing)
+
+	conditional := func(header, value string) *http.Request {
+		r, _ := http.NewRequest(http.MethodPut, "/", nil)
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		return r
+	}
+	assert.NoError(t, checkRRsetPreconditions(conditional("", ""), existing))
+	assert.NoError(t, checkRRsetPreconditions(conditional("If-Match", etag), existing))
+	assert.NoError(t, checkRRsetPreconditions(conditional("If-Match", `"other", W/`+etag), existing))
+	assert.NoError(t, checkRRsetPreconditions(conditional("If-Match", "*"), existing))
+	assert.ErrorIs(t, checkRRsetPreconditions(conditional("If-Match", `"other"`), existing), errPreconditionFailed)
+	assert.ErrorIs(t, checkRRsetPreconditions(conditional("If-Match", "*"), nil), errPreconditionFailed)
+	assert.ErrorIs(t, checkRRsetPreconditions(conditional("If-None-Match", "*"), existing), errPreconditionFailed)
+	assert.NoError(t, checkRRsetPreconditions(conditional("If-None-Match", "*"), nil))
+
+	rec.Set("ttl", 600)
+	assert.NotEqual(t, etag, rrsetETag(existing))
+}
@@ -0,0 +1,80 @@
+package pocketbase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+)
+
+// seedActor is the actor of the records seeded at startup.
+var seedActor = Actor{Type: ActorSystem, Name: "seed"}
+
+// zoneSeed is the records of a zone declared in the Corefile, or the seed file of a zone.
+type zoneSeed struct {
+	zone string
+	// records are in master file format, relative names are within the zone
+	records []string
+	// file is a zone file, or an octoDNS YAML file with the .yaml or .yml extension
+	file string
+}
+
+// zoneFileFormatOf returns the format of a zone definition from its file extension,
+// octoDNS YAML for .yaml and .yml files and the master file format otherwise.
+func zoneFileFormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return octodnsFormat
+	default:
+		return zoneFileFormat
+	}
+}
+
+// seedZones imports the seeds like zone files, each zone within its own transaction: absent RRsets are created,
+// equal ones are left untouched and differing ones are kept, or replaced with seedOverwrite.
+// Seeding again at the next startup changes nothing, failing seeds are logged and skipped.
+func (inst *Instance) seedZones() {
+	if len(inst.seeds) == 0 {
+		return
+	}
+	log.Infof("Seeding zones, seeds: %d, overwrite: %t", len(inst.seeds), inst.seedOverwrite)
+	ctx := WithActor(context.Background(), seedActor)
+	opts := zoneImportOptions{Overwrite: inst.seedOverwrite, CreateZone: true}
+	for _, seed := range inst.seeds {
+		var report *zoneImportReport
+		var err error
+		if seed.file != "" {
+			report, err = inst.importZoneDefinition(ctx, seed.zone, seed.file, zoneFileFormatOf(seed.file), opts)
+		} else {
+			report, err = inst.importSeedRecords(ctx, seed, opts)
+		}
+		if err != nil {
+			log.Errorf("Failed to seed zone, zone: %s, err: %+v", seed.zone, err)
+			continue
+		}
+		if len(report.Conflicts) > 0 {
+			log.Infof("Kept the existing RRsets differing from the seeds, zone: %s, conflicts: %d", seed.zone,
+				len(report.Conflicts))
+		}
+		for _, issue := range report.Unsupported {
+			log.Warningf("Skipped seeded RRset, zone: %s, name: %s, type: %s, reason: %s", seed.zone, issue.Name,
+				issue.Type, issue.Reason)
+		}
+	}
+}
+
+// importSeedRecords imports the records of a zone declared in the Corefile.
+func (inst *Instance) importSeedRecords(ctx context.Context, seed *zoneSeed, opts zoneImportOptions) (*zoneImportReport, error) {
+	rrs, err := parseZoneFile(strings.NewReader(strings.Join(seed.records, "\n")), seed.zone, "", false)
+	if err != nil {
+		return nil, err
+	}
+	report, err := inst.importZoneFile(ctx, seed.zone, rrs, opts)
+	if errors.Is(err, errZoneImportFailed) {
+		err = fmt.Errorf("%w: %s", err, strings.Join(report.Errors, "; "))
+	}
+	return report, err
+}
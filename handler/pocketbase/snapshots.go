@@ -0,0 +1,410 @@
+package pocketbase
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/miekg/dns"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+const zoneSnapshotCollectionName = "coredns_zone_snapshots"
+
+// Snapshot triggers, manual snapshots are taken through the API,
+// automatic snapshots before bulk operations replacing many records of a zone.
+const (
+	SnapshotManual = "manual"
+	SnapshotAuto   = "auto"
+)
+
+// autoSnapshotRetention is the number of automatic snapshots kept per zone, older ones are deleted.
+const autoSnapshotRetention = 20
+
+// snapshotLive is the snapshot id standing for the live records of a zone in diffs.
+const snapshotLive = "live"
+
+// rrsetState is the records of a RRset in a state of a zone.
+type rrsetState struct {
+	Name       string           `json:"name"`
+	RecordType string           `json:"record_type"`
+	Records    []map[string]any `json:"records"`
+}
+
+// rrsetChange is a RRset which differs between two states of a zone.
+type rrsetChange struct {
+	Name       string           `json:"name"`
+	RecordType string           `json:"record_type"`
+	Before     []map[string]any `json:"before"`
+	After      []map[string]any `json:"after"`
+}
+
+// zoneDiff is the difference between two states of a zone, by RRset.
+type zoneDiff struct {
+	Zone    string         `json:"zone"`
+	From    string         `json:"from"`
+	To      string         `json:"to"`
+	Added   []*rrsetState  `json:"added"`
+	Removed []*rrsetState  `json:"removed"`
+	Changed []*rrsetChange `json:"changed"`
+}
+
+// bindSnapshotRoutes registers the zone snapshot routes.
+func (inst *Instance) bindSnapshotRoutes(g *router.RouterGroup[*core.RequestEvent]) {
+	g.POST("/zones/{zone}/snapshots", inst.handleCreateSnapshot).Bind(apis.RequireAuth())
+	g.GET("/snapshots/{id}/diff", inst.handleDiffSnapshot).Bind(apis.RequireAuth())
+	g.POST("/snapshots/{id}/restore", inst.handleRestoreSnapshot).Bind(apis.RequireAuth())
+}
+
+// handleCreateSnapshot takes a manual snapshot of a zone, with an optional label.
+func (inst *Instance) handleCreateSnapshot(e *core.RequestEvent) error {
+	zone := dns.Fqdn(e.Request.PathValue("zone"))
+	if err := inst.requireZoneRole(e, zone, zoneEditors); err != nil {
+		return err
+	}
+	if _, err := inst.pb.FindFirstRecordByData(zoneCollectionName, "name", zone); err != nil {
+		return e.NotFoundError("Zone not found.", err)
+	}
+	body := struct {
+		Label string `json:"label"`
+	}{}
+	if err := e.BindBody(&body); err != nil {
+		return e.BadRequestError("Failed to decode snapshot.", err)
+	}
+
+	ctx := WithActor(e.Request.Context(), requestActor(e))
+	snapshot, err := inst.createZoneSnapshot(ctx, inst.pb, zone, SnapshotManual, body.Label)
+	if err != nil {
+		log.Errorf("Failed to create zone snapshot, zone: %s, err: %+v", zone, err)
+		return e.InternalServerError("Failed to create zone snapshot.", err)
+	}
+	return e.JSON(http.StatusCreated, snapshot)
+}
+
+// handleDiffSnapshot returns the difference between a snapshot and another snapshot of the same zone,
+// given by the "to" query parameter, or the live records of the zone if it is empty or "live".
+func (inst *Instance) handleDiffSnapshot(e *core.RequestEvent) error {
+	from, err := inst.pb.FindRecordById(zoneSnapshotCollectionName, e.Request.PathValue("id"))
+	if err != nil {
+		return e.NotFoundError("Snapshot not found.", err)
+	}
+	if err = inst.requireZoneRole(e, from.GetString("zone"), zoneReaders); err != nil {
+		return err
+	}
+	fromRecs, err := snapshotRecords(from)
+	if err != nil {
+		return e.InternalServerError("Failed to read snapshot.", err)
+	}
+
+	zone, toId := from.GetString("zone"), e.Request.URL.Query().Get("to")
+	var toRecs []map[string]any
+	if toId == "" || toId == snapshotLive {
+		toId = snapshotLive
+		toRecs, err = zoneSnapshotRecords(inst.pb, zone)
+		if err != nil {
+			return e.InternalServerError("Failed to fetch records.", err)
+		}
+	} else {
+		to, err := inst.pb.FindRecordById(zoneSnapshotCollectionName, toId)
+		if err != nil {
+			return e.NotFoundError("Snapshot not found.", err)
+		}
+		if to.GetString("zone") != zone {
+			return e.BadRequestError("Snapshots of different zones can not be compared.", nil)
+		}
+		if toRecs, err = snapshotRecords(to); err != nil {
+			return e.InternalServerError("Failed to read snapshot.", err)
+		}
+	}
+
+	diff := diffZoneRecords(fromRecs, toRecs)
+	diff.Zone, diff.From, diff.To = zone, from.Id, toId
+	return e.JSON(http.StatusOK, diff)
+}
+
+// handleRestoreSnapshot replaces the records of a zone with the records of a snapshot,
+// after taking an automatic snapshot of the records being replaced.
+func (inst *Instance) handleRestoreSnapshot(e *core.RequestEvent) error {
+	snapshot, err := inst.pb.FindRecordById(zoneSnapshotCollectionName, e.Request.PathValue("id"))
+	if err != nil {
+		return e.NotFoundError("Snapshot not found.", err)
+	}
+	if err = inst.requireZoneRole(e, snapshot.GetString("zone"), zoneEditors); err != nil {
+		return err
+	}
+
+	ctx := WithActor(e.Request.Context(), requestActor(e))
+	var backup *core.Record
+	err = inst.pb.RunInTransaction(func(txApp core.App) error {
+		backup, err = inst.createZoneSnapshot(ctx, txApp, snapshot.GetString("zone"), SnapshotAuto,
+			"before restore of snapshot "+snapshot.Id)
+		if err != nil {
+			return err
+		}
+		return inst.restoreZoneSnapshot(ctx, txApp, snapshot)
+	})
+	if err != nil {
+		log.Errorf("Failed to restore zone snapshot, snapshot: %s, err: %+v", snapshot.Id, err)
+		return e.BadRequestError("Failed to restore zone snapshot: "+err.Error(), err)
+	}
+	log.Infof("Restored zone snapshot, zone: %s, snapshot: %s, backup: %s",
+		snapshot.GetString("zone"), snapshot.Id, backup.Id)
+	return e.JSON(http.StatusOK, map[string]any{
+		"zone":     snapshot.GetString("zone"),
+		"snapshot": snapshot.Id,
+		"backup":   backup.Id,
+	})
+}
+
+// createZoneSnapshot saves a snapshot of all records of the zone, attributed to the actor of the context.
+// Automatic snapshots beyond the retention of the zone are deleted.
+func (inst *Instance) createZoneSnapshot(ctx context.Context, app core.App, zone string, trigger string, label string) (*core.Record, error) {
+	snapshot, err := inst.newZoneSnapshot(ctx, app, zone, trigger, label)
+	if err != nil {
+		return nil, err
+	}
+	if err = saveZoneSnapshot(app, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// newZoneSnapshot returns a snapshot of all records of the zone, attributed to the actor of the context,
+// without saving it, see saveZoneSnapshot.
+func (inst *Instance) newZoneSnapshot(ctx context.Context, app core.App, zone string, trigger string, label string) (*core.Record, error) {
+	coll, err := app.FindCollectionByNameOrId(zoneSnapshotCollectionName)
+	if err != nil {
+		return nil, err
+	}
+	recs, err := zoneSnapshotRecords(app, zone)
+	if err != nil {
+		return nil, err
+	}
+	var serial int
+	if zoneRec, err := app.FindFirstRecordByData(zoneCollectionName, "name", zone); err == nil {
+		serial = zoneRec.GetInt("serial")
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	actor := inst.actors.actorOf(ctx, nil)
+	snapshot := core.NewRecord(coll)
+	snapshot.Set("zone", zone)
+	snapshot.Set("label", label)
+	snapshot.Set("trigger", trigger)
+	snapshot.Set("actor_type", actor.Type)
+	snapshot.Set("actor", actor.Name)
+	snapshot.Set("serial", serial)
+	snapshot.Set("record_count", len(recs))
+	snapshot.Set("records", recs)
+	return snapshot, nil
+}
+
+// saveZoneSnapshot saves a snapshot of newZoneSnapshot, pruning the oldest automatic snapshots of its zone.
+func saveZoneSnapshot(app core.App, snapshot *core.Record) error {
+	if err := app.Save(snapshot); err != nil {
+		return err
+	}
+	log.Infof("Created zone snapshot, zone: %s, id: %s, trigger: %s, records: %d", snapshot.GetString("zone"),
+		snapshot.Id, snapshot.GetString("trigger"), snapshot.GetInt("record_count"))
+
+	if snapshot.GetString("trigger") == SnapshotAuto {
+		return pruneAutoSnapshots(app, snapshot.GetString("zone"))
+	}
+	return nil
+}
+
+// restoreZoneSnapshot replaces the records of the zone of the snapshot with its records,
+// keeping the ids of the records so that their history continues. The records are checked
+// against the zone integrity rules and the serial of the zone is bumped once all are replaced,
+// so it should be called within a transaction.
+func (inst *Instance) restoreZoneSnapshot(ctx context.Context, app core.App, snapshot *core.Record) error {
+	zone := snapshot.GetString("zone")
+	recs, err := snapshotRecords(snapshot)
+	if err != nil {
+		return err
+	}
+	coll, err := app.FindCollectionByNameOrId(recordCollectionName)
+	if err != nil {
+		return err
+	}
+	live, err := app.FindAllRecords(recordCollectionName, dbx.HashExp{"zone": zone})
+	if err != nil {
+		return err
+	}
+
+	ctx = withoutSerialBumps(WithoutIntegrityChecks(ctx))
+	liveById := make(map[string]*core.Record, len(live))
+	for _, rec := range live {
+		liveById[rec.Id] = rec
+	}
+	restored := make(map[string]bool, len(recs))
+	for _, snap := range recs {
+		id, _ := snap["id"].(string)
+		rec, ok := liveById[id]
+		if !ok {
+			// the record was deleted or moved to another zone since the snapshot
+			rec, err = app.FindRecordById(recordCollectionName, id)
+			if errors.Is(err, sql.ErrNoRows) {
+				rec = core.NewRecord(coll)
+				rec.Id = id
+			} else if err != nil {
+				return err
+			}
+		}
+		for _, field := range historyFields {
+			rec.Set(field, snap[field])
+		}
+		if err = app.SaveWithContext(ctx, rec); err != nil {
+			return err
+		}
+		restored[rec.Id] = true
+	}
+	for _, rec := range live {
+		if restored[rec.Id] {
+			continue
+		}
+		if err = app.DeleteWithContext(ctx, rec); err != nil {
+			return err
+		}
+	}
+
+	if err = inst.checkZoneIntegrity(app, zone); err != nil {
+		return err
+	}
+	return bumpZoneSerial(app, zone)
+}
+
+// pruneAutoSnapshots deletes the automatic snapshots of the zone beyond the retention.
+func pruneAutoSnapshots(app core.App, zone string) error {
+	expired, err := app.FindRecordsByFilter(zoneSnapshotCollectionName,
+		"zone = {:zone} && trigger = {:trigger}", "-created,-id", 0, autoSnapshotRetention,
+		dbx.Params{"zone": zone, "trigger": SnapshotAuto})
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range expired {
+		if err = app.Delete(snapshot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zoneSnapshotRecords returns the history fields and the id of all records of the zone, disabled ones included.
+func zoneSnapshotRecords(app core.App, zone string) ([]map[string]any, error) {
+	recs, err := app.FindRecordsByFilter(recordCollectionName, "zone = {:zone}", "name,record_type,created", 0, 0,
+		dbx.Params{"zone": zone})
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]map[string]any, 0, len(recs))
+	for _, rec := range recs {
+		snapshot := recordSnapshot(rec)
+		snapshot["id"] = rec.Id
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// snapshotRecords returns the records saved in a snapshot.
+func snapshotRecords(snapshot *core.Record) ([]map[string]any, error) {
+	var recs []map[string]any
+	if err := snapshot.UnmarshalJSONField("records", &recs); err != nil {
+		return nil, err
+	}
+	return recs, nil
+}
+
+// diffZoneRecords compares two states of a zone by RRset. Records are compared by their fields except their ids,
+// so that a record deleted and created again with the same fields is no change.
+func diffZoneRecords(from, to []map[string]any) *zoneDiff {
+	fromSets, fromKeys := groupRRsets(from)
+	toSets, toKeys := groupRRsets(to)
+
+	diff := &zoneDiff{Added: []*rrsetState{}, Removed: []*rrsetState{}, Changed: []*rrsetChange{}}
+	for _, key := range fromKeys {
+		before := fromSets[key]
+		after, ok := toSets[key]
+		if !ok {
+			diff.Removed = append(diff.Removed, before)
+			continue
+		}
+		if !equalRecords(before.Records, after.Records) {
+			diff.Changed = append(diff.Changed, &rrsetChange{
+				Name: before.Name, RecordType: before.RecordType, Before: before.Records, After: after.Records,
+			})
+		}
+	}
+	for _, key := range toKeys {
+		if _, ok := fromSets[key]; !ok {
+			diff.Added = append(diff.Added, toSets[key])
+		}
+	}
+	return diff
+}
+
+// groupRRsets groups records by name and type, returning the RRsets and their keys in sorted order.
+func groupRRsets(recs []map[string]any) (map[string]*rrsetState, []string) {
+	sets := make(map[string]*rrsetState)
+	keys := make([]string, 0)
+	for _, rec := range recs {
+		name, _ := rec["name"].(string)
+		recordType, _ := rec["record_type"].(string)
+		key := name + "/" + recordType
+		set, ok := sets[key]
+		if !ok {
+			set = &rrsetState{Name: name, RecordType: recordType}
+			sets[key] = set
+			keys = append(keys, key)
+		}
+		set.Records = append(set.Records, rec)
+	}
+	sort.Strings(keys)
+	return sets, keys
+}
+
+// equalRecords reports whether two RRsets have the same records, in any order.
+func equalRecords(a, b []map[string]any) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := make(map[string]int, len(a))
+	for _, rec := range a {
+		count[canonicalRecord(rec)]++
+	}
+	for _, rec := range b {
+		key := canonicalRecord(rec)
+		if count[key] == 0 {
+			return false
+		}
+		count[key]--
+	}
+	return true
+}
+
+// canonicalRecord returns the JSON encoding of the record fields except the id,
+// with the content decoded and encoded again so that equal contents encode equally.
+func canonicalRecord(rec map[string]any) string {
+	fields := make(map[string]any, len(historyFields))
+	for _, field := range historyFields {
+		fields[field] = rec[field]
+	}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return ""
+	}
+	var normalized any
+	if err = json.Unmarshal(b, &normalized); err != nil {
+		return string(b)
+	}
+	b, _ = json.Marshal(normalized)
+	return string(b)
+}
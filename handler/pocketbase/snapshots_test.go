@@ -0,0 +1,53 @@
+package pocketbase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffZoneRecords(t *testing.T) {
+	rec := func(id string, name string, recordType string, ttl float64, content any) map[string]any {
+		return map[string]any{"id": id, "zone": "example.com.", "name": name, "record_type": recordType,
+			"ttl": ttl, "content": content, "disabled": false, "comment": "", "owner": ""}
+	}
+	from := []map[string]any{
+		rec("1", "www.example.com.", "A", 300, types.JSONRaw(`{"ip": "192.0.2.1"}`)),
+		rec("2", "www.example.com.", "A", 300, types.JSONRaw(`{"ip": "192.0.2.2"}`)),
+		rec("3", "mail.example.com.", "A", 300, types.JSONRaw(`{"ip": "192.0.2.3"}`)),
+		rec("4", "example.com.", "TXT", 300, types.JSONRaw(`{"text": "v=spf1 -all"}`)),
+	}
+	to := []map[string]any{
+		// same records in another order, with new ids and differently encoded contents
+		rec("5", "www.example.com.", "A", 300, map[string]any{"ip": "192.0.2.2"}),
+		rec("1", "www.example.com.", "A", 300, map[string]any{"ip": "192.0.2.1"}),
+		rec("4", "example.com.", "TXT", 600, map[string]any{"text": "v=spf1 -all"}),
+		rec("6", "ftp.example.com.", "CNAME", 300, map[string]any{"host": "www.example.com.", "zone": "example.com."}),
+	}
+
+	diff := diffZoneRecords(from, to)
+	if assert.Len(t, diff.Added, 1) {
+		assert.Equal(t, "ftp.example.com.", diff.Added[0].Name)
+		assert.Equal(t, "CNAME", diff.Added[0].RecordType)
+	}
+	if assert.Len(t, diff.Removed, 1) {
+		assert.Equal(t, "mail.example.com.", diff.Removed[0].Name)
+	}
+	if assert.Len(t, diff.Changed, 1) {
+		assert.Equal(t, "example.com.", diff.Changed[0].Name)
+		assert.Equal(t, "TXT", diff.Changed[0].RecordType)
+		assert.Equal(t, float64(600), diff.Changed[0].After[0]["ttl"])
+	}
+
+	diff = diffZoneRecords(from, from)
+	assert.Empty(t, diff.Added)
+	assert.Empty(t, diff.Removed)
+	assert.Empty(t, diff.Changed)
+}
+
+func TestSerialBumpsDisabled(t *testing.T) {
+	assert.False(t, serialBumpsDisabled(context.Background()))
+	assert.True(t, serialBumpsDisabled(withoutSerialBumps(context.Background())))
+}
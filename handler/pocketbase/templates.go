@@ -0,0 +1,213 @@
+package pocketbase
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/miekg/dns"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+// zoneTemplates caches the enabled template records of the zones whatever the cache capacity, as they are matched
+// against every name without records of its own. The cache of a zone is dropped on any change of its template records.
+type zoneTemplates struct {
+	mu    sync.Mutex
+	zones map[string][]*m.Record
+	// generation is incremented by each invalidation, telling apart the lists fetched before
+	generation uint64
+}
+
+// get returns the cached template records of the zone, with the generation of the cache to set a fetched list with.
+func (t *zoneTemplates) get(zone string) (recs []*m.Record, ok bool, generation uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	recs, ok = t.zones[zone]
+	return recs, ok, t.generation
+}
+
+// set caches the template records of the zone unless the cache was invalidated since the generation.
+func (t *zoneTemplates) set(zone string, recs []*m.Record, generation uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if generation != t.generation {
+		return
+	}
+	if t.zones == nil {
+		t.zones = make(map[string][]*m.Record)
+	}
+	t.zones[zone] = recs
+}
+
+// invalidate drops the cached template records of the zones.
+func (t *zoneTemplates) invalidate(zones ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.generation++
+	for _, zone := range zones {
+		delete(t.zones, zone)
+	}
+}
+
+// bindTemplateEvents drops the cached template records of the zones whose template records change, whatever the
+// write path and even within batches.
+func (inst *Instance) bindTemplateEvents() {
+	log.Debug("Bind template record events...")
+
+	invalidateFunc := func(e *core.RecordEvent) error {
+		for _, rec := range []*core.Record{e.Record, e.Record.Original()} {
+			if rec.GetString("record_type") == m.TemplateRecordType {
+				log.Debugf("Deleting template records cache, zone: %s", rec.GetString("zone"))
+				inst.templates.invalidate(rec.GetString("zone"))
+			}
+		}
+		return e.Next()
+	}
+	inst.pb.OnRecordAfterCreateSuccess(recordCollectionName).BindFunc(invalidateFunc)
+	inst.pb.OnRecordAfterUpdateSuccess(recordCollectionName).BindFunc(invalidateFunc)
+	inst.pb.OnRecordAfterDeleteSuccess(recordCollectionName).BindFunc(invalidateFunc)
+}
+
+// fetchZoneTemplates retrieves the enabled template records of the zone, from the templates cache.
+func (inst *Instance) fetchZoneTemplates(coll *core.Collection, zone string) (recs []*m.Record) {
+	recs, ok, generation := inst.templates.get(zone)
+	if ok {
+		return recs
+	}
+	err := inst.pb.RecordQuery(coll).
+		Select("name", "zone", "ttl", "record_type", "content").
+		Where(dbx.HashExp{"zone": zone, "record_type": m.TemplateRecordType, "disabled": false}).
+		OrderBy("name", "created").
+		All(&recs)
+	if err != nil {
+		log.Errorf("Fetching template records from db failed, zone: [%s], err: %+v", zone, err)
+		return nil
+	}
+	log.Debugf("Template records [%d] fetched from db, zone: [%s]", len(recs), zone)
+	inst.templates.set(zone, recs, generation)
+	return recs
+}
+
+// fetchTemplateRecords expands the template records of the zone generating records of the type at name,
+// the records are virtual: they exist at lookup time only.
+func (inst *Instance) fetchTemplateRecords(coll *core.Collection, zone string, name string, recordType string) (recs []*m.Record) {
+	for _, tmpl := range inst.fetchZoneTemplates(coll, zone) {
+		var t m.TEMPLATERecord
+		if err := json.Unmarshal([]byte(tmpl.Content), &t); err != nil {
+			log.Errorf("Failed to unmarshal TEMPLATE record, zone: %s, name: %s, err: %+v", tmpl.Zone, tmpl.Name, err)
+			continue
+		}
+		if t.RecordType != recordType {
+			continue
+		}
+		value, ok := m.MatchTemplateName(tmpl.Name, &t, name)
+		if !ok {
+			continue
+		}
+		rec, err := inst.templateModelRecord(tmpl, &t, value)
+		if err != nil {
+			log.Errorf("Failed to expand TEMPLATE record, zone: %s, name: %s, value: %d, err: %+v", tmpl.Zone, tmpl.Name,
+				value, err)
+			continue
+		}
+		log.Debugf("Expanded TEMPLATE record, zone: [%s], name: [%s], value: %d", zone, name, value)
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+// expandTemplateRecords returns all the records the template record expands into.
+func (inst *Instance) expandTemplateRecords(tmpl *m.Record) ([]*m.Record, error) {
+	var t m.TEMPLATERecord
+	if err := json.Unmarshal([]byte(tmpl.Content), &t); err != nil {
+		return nil, err
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	recs := make([]*m.Record, 0, t.Len())
+	for i, n := 0, t.Len(); i < n; i++ {
+		rec, err := inst.templateModelRecord(tmpl, &t, t.Value(i))
+		if err != nil {
+			return nil, fmt.Errorf("value %d: %w", t.Value(i), err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// templateModelRecord returns the record the template record generates for the value.
+func (inst *Instance) templateModelRecord(tmpl *m.Record, t *m.TEMPLATERecord, value uint32) (*m.Record, error) {
+	rr, err := m.ExpandTemplateRecord(tmpl.Zone, tmpl.Name, tmpl.Ttl, t, value)
+	if err != nil {
+		return nil, err
+	}
+	content, err := inst.importContentFromRR(tmpl.Zone, rr)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	return &m.Record{
+		Zone:       tmpl.Zone,
+		Name:       rr.Header().Name,
+		RecordType: t.RecordType,
+		Ttl:        tmpl.Ttl,
+		Content:    string(data),
+	}, nil
+}
+
+// composeRecordRRs composes the DNS resource records of a PocketBase record, all the records of a template record.
+func (inst *Instance) composeRecordRRs(rec *m.Record) ([]dns.RR, error) {
+	recs := []*m.Record{rec}
+	if rec.RecordType == m.TemplateRecordType {
+		var err error
+		if recs, err = inst.expandTemplateRecords(rec); err != nil {
+			return nil, err
+		}
+	}
+	rrs := make([]dns.RR, 0, len(recs))
+	for _, r := range recs {
+		rr, _, err := inst.ComposeRecord(r)
+		if err != nil {
+			return nil, err
+		}
+		if rr != nil {
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs, nil
+}
+
+// composeZoneRRs composes the records of a zone as they are served: template records are expanded, except for the
+// RRsets which have records of their own. Records failing to compose are skipped.
+func (inst *Instance) composeZoneRRs(recs []*core.Record) []dns.RR {
+	concrete := make(map[string]bool, len(recs))
+	for _, rec := range recs {
+		if rec.GetString("record_type") != m.TemplateRecordType {
+			concrete[strings.ToLower(rec.GetString("name"))+" "+rec.GetString("record_type")] = true
+		}
+	}
+	rrs := make([]dns.RR, 0, len(recs))
+	for _, rec := range recs {
+		composed, err := inst.composeRecordRRs(toModelRecord(rec))
+		if err != nil {
+			log.Errorf("Failed to compose record, id: %s, err: %+v", rec.Id, err)
+			continue
+		}
+		for _, rr := range composed {
+			key := strings.ToLower(rr.Header().Name) + " " + dns.TypeToString[rr.Header().Rrtype]
+			if rec.GetString("record_type") == m.TemplateRecordType && concrete[key] {
+				continue
+			}
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs
+}
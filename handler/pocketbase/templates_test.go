@@ -0,0 +1,29 @@
+package pocketbase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+func TestZoneTemplates(t *testing.T) {
+	var templates zoneTemplates
+	recs := []*m.Record{{Zone: "example.com.", Name: "host-$.example.com.", RecordType: m.TemplateRecordType}}
+
+	_, ok, generation := templates.get("example.com.")
+	assert.False(t, ok)
+	templates.set("example.com.", recs, generation)
+	got, ok, _ := templates.get("example.com.")
+	assert.True(t, ok)
+	assert.Equal(t, recs, got)
+
+	// a list fetched before an invalidation is not cached
+	_, _, generation = templates.get("example.org.")
+	templates.invalidate("example.com.")
+	templates.set("example.org.", nil, generation)
+	_, ok, _ = templates.get("example.org.")
+	assert.False(t, ok)
+	_, ok, _ = templates.get("example.com.")
+	assert.False(t, ok)
+}
@@ -0,0 +1,199 @@
+package pocketbase
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	tenantCollectionName     = "coredns_tenants"
+	zoneMemberCollectionName = "coredns_zone_members"
+)
+
+// Roles of the members of a zone, viewers may read the zone, editors may change its records as well,
+// owners may also change the zone itself and its members.
+const (
+	ZoneRoleOwner  = "owner"
+	ZoneRoleEditor = "editor"
+	ZoneRoleViewer = "viewer"
+)
+
+var (
+	// zoneReaders are the roles allowed to read a zone.
+	zoneReaders = []string{ZoneRoleOwner, ZoneRoleEditor, ZoneRoleViewer}
+	// zoneEditors are the roles allowed to change the records of a zone.
+	zoneEditors = []string{ZoneRoleOwner, ZoneRoleEditor}
+)
+
+// bindTenantEvents keeps the zones of the tenants consistent with their members and quotas,
+// zones are bound to the tenants by verified zone claims:
+//   - the zones and records of a tenant are limited by its quotas, whoever creates them,
+//   - the last owner of a zone can not be removed or downgraded through the API.
+func (inst *Instance) bindTenantEvents() {
+	log.Debug("Bind tenant events...")
+
+	inst.pb.OnRecordCreate(zoneCollectionName).BindFunc(func(e *core.RecordEvent) error {
+		if err := checkZoneQuota(e.App, e.Record.GetString("tenant")); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+	inst.pb.OnRecordCreate(recordCollectionName).BindFunc(func(e *core.RecordEvent) error {
+		if err := checkRecordQuota(e.App, e.Record.GetString("zone_id")); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+
+	lastOwnerFunc := func(e *core.RecordRequestEvent) error {
+		original := e.Record.Original()
+		if original.GetString("role") != ZoneRoleOwner ||
+			e.Request.Method != http.MethodDelete && e.Record.GetString("role") == ZoneRoleOwner {
+			return e.Next()
+		}
+		owners, err := e.App.CountRecords(zoneMemberCollectionName,
+			dbx.HashExp{"zone": original.GetString("zone"), "role": ZoneRoleOwner})
+		if err != nil {
+			return e.InternalServerError("Failed to count zone owners.", err)
+		}
+		if owners <= 1 {
+			return e.BadRequestError("The last owner of a zone can not be removed.", nil)
+		}
+		return e.Next()
+	}
+	inst.pb.OnRecordUpdateRequest(zoneMemberCollectionName).BindFunc(lastOwnerFunc)
+	inst.pb.OnRecordDeleteRequest(zoneMemberCollectionName).BindFunc(lastOwnerFunc)
+}
+
+// addZoneMember adds the user to the members of the zone with the role.
+func addZoneMember(app core.App, zoneId string, userId string, role string) error {
+	coll, err := app.FindCollectionByNameOrId(zoneMemberCollectionName)
+	if err != nil {
+		return err
+	}
+	member := core.NewRecord(coll)
+	member.Set("zone", zoneId)
+	member.Set("user", userId)
+	member.Set("role", role)
+	return app.Save(member)
+}
+
+// zoneRole returns the role of the user in the zone, or an empty string if the user is not a member of the zone.
+func zoneRole(app core.App, zone string, userId string) (string, error) {
+	var role string
+	err := app.DB().Select("m.role").
+		From(zoneMemberCollectionName+" m").
+		InnerJoin(zoneCollectionName+" z", dbx.NewExp("z.id = m.zone")).
+		Where(dbx.HashExp{"z.name": zone, "m.user": userId}).
+		Limit(1).
+		Row(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return role, err
+}
+
+// requireZoneRole returns an error unless the request is made by a superuser
+// or by a member of the zone with one of the roles.
+// Requests authenticated with API tokens also require the token to cover all records of the zone.
+func (inst *Instance) requireZoneRole(e *core.RequestEvent, zone string, roles []string) error {
+	if scope, ok := requestTokenScope(e); ok {
+		ops := tokenWrites
+		if slices.Contains(roles, ZoneRoleViewer) {
+			ops = []string{TokenRead}
+		}
+		if !scope.allowsZone(zone, ops...) {
+			return e.ForbiddenError(fmt.Sprintf("The API token does not allow %v on all records of zone %s.", ops, zone), nil)
+		}
+	}
+	return inst.requireMemberRole(e, zone, roles)
+}
+
+// requireMemberRole returns an error unless the request is made by a superuser
+// or by a member of the zone with one of the roles, regardless of the API token of the request.
+func (inst *Instance) requireMemberRole(e *core.RequestEvent, zone string, roles []string) error {
+	if e.HasSuperuserAuth() {
+		return nil
+	}
+	if e.Auth == nil {
+		return e.UnauthorizedError("The request requires valid authorization.", nil)
+	}
+	role, err := zoneRole(inst.pb, zone, e.Auth.Id)
+	if err != nil {
+		return e.InternalServerError("Failed to fetch zone role.", err)
+	}
+	if !slices.Contains(roles, role) {
+		return e.ForbiddenError(fmt.Sprintf("The request requires one of the roles %v in zone %s.", roles, zone), nil)
+	}
+	return nil
+}
+
+// checkZoneQuota returns an error if the tenant has as many zones as its quota allows,
+// zones without tenant and tenants without quota are not limited.
+func checkZoneQuota(app core.App, tenantId string) error {
+	if tenantId == "" {
+		return nil
+	}
+	tenant, err := app.FindRecordById(tenantCollectionName, tenantId)
+	if err != nil {
+		return err
+	}
+	maxZones := tenant.GetInt("max_zones")
+	if maxZones <= 0 {
+		return nil
+	}
+	zones, err := app.CountRecords(zoneCollectionName, dbx.HashExp{"tenant": tenantId})
+	if err != nil {
+		return err
+	}
+	if zones >= int64(maxZones) {
+		return quotaError("name", "tenant %s has reached its quota of %d zones", tenant.GetString("name"), maxZones)
+	}
+	return nil
+}
+
+// checkRecordQuota returns an error if the tenant of the zone has as many records as its quota allows,
+// records of zones without tenant and tenants without quota are not limited.
+func checkRecordQuota(app core.App, zoneId string) error {
+	if zoneId == "" {
+		return nil
+	}
+	zone, err := app.FindRecordById(zoneCollectionName, zoneId)
+	if err != nil || zone.GetString("tenant") == "" {
+		return err
+	}
+	tenant, err := app.FindRecordById(tenantCollectionName, zone.GetString("tenant"))
+	if err != nil {
+		return err
+	}
+	maxRecords := tenant.GetInt("max_records")
+	if maxRecords <= 0 {
+		return nil
+	}
+	var records int
+	err = app.DB().Select("COUNT(*)").
+		From(recordCollectionName+" r").
+		InnerJoin(zoneCollectionName+" z", dbx.NewExp("z.id = r.zone_id")).
+		Where(dbx.HashExp{"z.tenant": tenant.Id}).
+		Row(&records)
+	if err != nil {
+		return err
+	}
+	if records >= maxRecords {
+		return quotaError("zone", "tenant %s has reached its quota of %d records", tenant.GetString("name"), maxRecords)
+	}
+	return nil
+}
+
+// quotaError reports an exceeded tenant quota as a field error.
+func quotaError(field string, format string, args ...any) error {
+	return validation.Errors{field: validation.NewError("validation_quota_exceeded", fmt.Sprintf(format, args...))}
+}
@@ -0,0 +1,20 @@
+package pocketbase
+
+import (
+	"testing"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotaError(t *testing.T) {
+	err := quotaError("zone", "tenant %s has reached its quota of %d records", "acme", 100)
+	var vErrs validation.Errors
+	if assert.ErrorAs(t, err, &vErrs) {
+		var vErr validation.Error
+		if assert.ErrorAs(t, vErrs["zone"], &vErr) {
+			assert.Equal(t, "validation_quota_exceeded", vErr.Code())
+			assert.Equal(t, "tenant acme has reached its quota of 100 records", vErr.Message())
+		}
+	}
+}
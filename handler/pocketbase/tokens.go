@@ -0,0 +1,465 @@
+package pocketbase
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/ganigeorgiev/fexpr"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/miekg/dns"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+	"github.com/pocketbase/pocketbase/tools/router"
+	"github.com/pocketbase/pocketbase/tools/types"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+const (
+	apiTokenCollectionName = "coredns_api_tokens"
+	// apiTokenPrefix starts the API tokens, telling them apart from the PocketBase auth tokens.
+	apiTokenPrefix = "cdns_"
+	// apiTokenHintLength is the length of the beginning of a token kept in clear to recognize it.
+	apiTokenHintLength = len(apiTokenPrefix) + 6
+	// apiTokenStoreKey is the request store key of the API token authenticating the request.
+	apiTokenStoreKey = "corednsApiToken"
+	// apiTokenLastUsedInterval throttles the updates of the last used timestamps of the tokens.
+	apiTokenLastUsedInterval = time.Minute
+)
+
+// Operations granted to API tokens on the records in their scope.
+const (
+	TokenRead   = "read"
+	TokenCreate = "create"
+	TokenUpdate = "update"
+	TokenDelete = "delete"
+)
+
+// tokenWrites are the operations needed to change any record of a zone.
+var tokenWrites = []string{TokenRead, TokenCreate, TokenUpdate, TokenDelete}
+
+// tokenCollections are the collections of the PocketBase record API accessible with API tokens,
+// with the operations allowed on them.
+var tokenCollections = map[string][]string{
+	recordCollectionName:        tokenWrites,
+	zoneCollectionName:          {TokenRead},
+	recordHistoryCollectionName: {TokenRead},
+}
+
+// tokenScope limits what an API token may do on behalf of its owner,
+// empty zones, name patterns and record types do not limit the token.
+type tokenScope struct {
+	Zones        []string `json:"zones"`
+	NamePatterns []string `json:"name_patterns"`
+	RecordTypes  []string `json:"record_types"`
+	Operations   []string `json:"operations"`
+}
+
+// apiTokenScope returns the scope of a record of the API tokens collection.
+func apiTokenScope(token *core.Record) tokenScope {
+	scope := tokenScope{Operations: token.GetStringSlice("operations")}
+	_ = token.UnmarshalJSONField("zones", &scope.Zones)
+	_ = token.UnmarshalJSONField("name_patterns", &scope.NamePatterns)
+	_ = token.UnmarshalJSONField("record_types", &scope.RecordTypes)
+	return scope
+}
+
+// allowsZone reports whether the scope allows the operations on any record of the zone.
+func (s tokenScope) allowsZone(zone string, ops ...string) bool {
+	return s.matchZone(zone) && s.hasOperations(ops...) && len(s.NamePatterns) == 0 && len(s.RecordTypes) == 0
+}
+
+// allowsRecord reports whether the scope allows the operation on the record with the zone, name and type.
+func (s tokenScope) allowsRecord(op string, zone string, name string, recordType string) bool {
+	if !s.matchZone(zone) || !s.hasOperations(op) {
+		return false
+	}
+	if len(s.RecordTypes) > 0 && !slices.Contains(s.RecordTypes, strings.ToUpper(recordType)) {
+		return false
+	}
+	if len(s.NamePatterns) == 0 {
+		return true
+	}
+	name = strings.ToLower(dns.Fqdn(name))
+	return slices.ContainsFunc(s.NamePatterns, func(pattern string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	})
+}
+
+func (s tokenScope) matchZone(zone string) bool {
+	return len(s.Zones) == 0 || slices.Contains(s.Zones, strings.ToLower(dns.Fqdn(zone)))
+}
+
+func (s tokenScope) hasOperations(ops ...string) bool {
+	for _, op := range ops {
+		if !slices.Contains(s.Operations, op) {
+			return false
+		}
+	}
+	return true
+}
+
+// allowsCollectionRecord reports whether the scope allows the operation on a record of a collection
+// accessible with API tokens.
+func (s tokenScope) allowsCollectionRecord(op string, rec *core.Record) bool {
+	if !slices.Contains(tokenCollections[rec.Collection().Name], op) {
+		return false
+	}
+	if rec.Collection().Name == zoneCollectionName {
+		return s.matchZone(rec.GetString("name")) && s.hasOperations(op)
+	}
+	return s.allowsRecord(op, rec.GetString("zone"), rec.GetString("name"), rec.GetString("record_type"))
+}
+
+// normalize lowers the zones and name patterns of the scope to FQDNs and validates the scope.
+func (s *tokenScope) normalize() error {
+	for i, zone := range s.Zones {
+		s.Zones[i] = strings.ToLower(dns.Fqdn(zone))
+		if err := m.ValidateZoneName(s.Zones[i]); err != nil {
+			return validation.Errors{"zones": validation.NewError("validation_invalid_zones", err.Error())}
+		}
+	}
+	for i, pattern := range s.NamePatterns {
+		s.NamePatterns[i] = strings.ToLower(dns.Fqdn(pattern))
+		// the patterns are matched by the list queries too, which only know the * and ? wildcards
+		if _, err := path.Match(s.NamePatterns[i], ""); err != nil || strings.ContainsAny(s.NamePatterns[i], `[]\'"`) {
+			return validation.Errors{"name_patterns": validation.NewError("validation_invalid_name_patterns",
+				fmt.Sprintf("invalid name pattern %q", pattern))}
+		}
+	}
+	for i, recordType := range s.RecordTypes {
+		s.RecordTypes[i] = strings.ToUpper(recordType)
+		if !m.IsSupportedRecordType(s.RecordTypes[i]) {
+			return validation.Errors{"record_types": validation.NewError("validation_invalid_record_types",
+				fmt.Sprintf("unsupported record type %q, must be one of %s", recordType, strings.Join(m.SupportedRecordTypes, ", ")))}
+		}
+	}
+	if len(s.Operations) == 0 {
+		return validation.Errors{"operations": validation.NewError("validation_required", "at least one operation is required")}
+	}
+	return nil
+}
+
+// listFilter returns the PocketBase filter limiting the records of the collection to the scope,
+// empty if the scope does not limit them.
+func (s tokenScope) listFilter(collection string) string {
+	zoneField := "zone"
+	if collection == zoneCollectionName {
+		zoneField = "name"
+	}
+	var filters []string
+	if len(s.Zones) > 0 {
+		filters = append(filters, anyFilter(s.Zones, func(zone string) string {
+			return likeFilter(zoneField, escapeLike(zone))
+		}))
+	}
+	if collection == zoneCollectionName {
+		return strings.Join(filters, " && ")
+	}
+	if len(s.RecordTypes) > 0 {
+		filters = append(filters, anyFilter(s.RecordTypes, func(recordType string) string {
+			return fmt.Sprintf("record_type = '%s'", recordType)
+		}))
+	}
+	if len(s.NamePatterns) > 0 {
+		filters = append(filters, anyFilter(s.NamePatterns, func(pattern string) string {
+			like := strings.NewReplacer("*", "%", "?", "_").Replace(escapeLike(pattern))
+			return likeFilter("name", like)
+		}))
+	}
+	return strings.Join(filters, " && ")
+}
+
+// anyFilter joins the filters of the values with ||.
+func anyFilter(values []string, filter func(string) string) string {
+	filters := make([]string, 0, len(values))
+	for _, v := range values {
+		filters = append(filters, filter(v))
+	}
+	return "(" + strings.Join(filters, " || ") + ")"
+}
+
+// likeFilter returns the filter matching the field case-insensitively against the LIKE pattern. PocketBase wraps
+// the patterns without % in %, the exact matches are expressed as a prefix match without a longer match.
+func likeFilter(field string, like string) string {
+	if strings.Contains(strings.ReplaceAll(like, `\%`, ""), "%") {
+		return fmt.Sprintf("%s ~ '%s'", field, like)
+	}
+	return fmt.Sprintf("(%s ~ '%s%%' && %s !~ '%s_%%')", field, like, field, like)
+}
+
+// escapeLike escapes the LIKE wildcards of the value.
+func escapeLike(value string) string {
+	return strings.NewReplacer("%", `\%`, "_", `\_`).Replace(value)
+}
+
+// setScope stores the scope in a record of the API tokens collection.
+func (s tokenScope) setScope(token *core.Record) {
+	token.Set("zones", s.Zones)
+	token.Set("name_patterns", s.NamePatterns)
+	token.Set("record_types", s.RecordTypes)
+	token.Set("operations", s.Operations)
+}
+
+// requestApiToken returns the API token authenticating the request, if any.
+func requestApiToken(e *core.RequestEvent) (*core.Record, bool) {
+	token, ok := e.Get(apiTokenStoreKey).(*core.Record)
+	return token, ok
+}
+
+// requestTokenScope returns the scope of the API token authenticating the request, if any.
+func requestTokenScope(e *core.RequestEvent) (tokenScope, bool) {
+	token, ok := requestApiToken(e)
+	if !ok {
+		return tokenScope{}, false
+	}
+	return apiTokenScope(token), true
+}
+
+// hashApiToken returns the hash the token is stored as, tokens are random enough for a plain SHA-256.
+func hashApiToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newApiToken generates a random API token.
+func newApiToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiTokenPrefix + hex.EncodeToString(b), nil
+}
+
+// bindApiTokenAuth registers the middleware authenticating requests with API tokens.
+// It runs before the PocketBase auth token middleware, which then keeps the owner of the token as the request auth.
+func (inst *Instance) bindApiTokenAuth(se *core.ServeEvent) {
+	log.Info("Bind API token auth...")
+	se.Router.Bind(&hook.Handler[*core.RequestEvent]{
+		Id:       "corednsApiTokenAuth",
+		Priority: apis.DefaultLoadAuthTokenMiddlewarePriority - 1,
+		Func:     inst.loadApiToken,
+	})
+}
+
+// loadApiToken authenticates the request as the owner of its API token, if the request carries one.
+func (inst *Instance) loadApiToken(e *core.RequestEvent) error {
+	raw := strings.TrimPrefix(e.Request.Header.Get("Authorization"), "Bearer ")
+	if !strings.HasPrefix(raw, apiTokenPrefix) {
+		return e.Next()
+	}
+	token, err := inst.pb.FindFirstRecordByData(apiTokenCollectionName, "token_hash", hashApiToken(raw))
+	if err != nil {
+		return e.UnauthorizedError("Invalid API token.", nil)
+	}
+	if token.GetBool("revoked") {
+		return e.UnauthorizedError("The API token is revoked.", nil)
+	}
+	if expires := token.GetDateTime("expires"); !expires.IsZero() && expires.Time().Before(time.Now()) {
+		return e.UnauthorizedError("The API token is expired.", nil)
+	}
+	if !apiTokenRoute(e.Request.URL.Path) {
+		return e.ForbiddenError("The route does not accept API tokens.", nil)
+	}
+	owner, err := apiTokenOwner(inst.pb, token)
+	if err != nil {
+		return e.UnauthorizedError("The owner of the API token no longer exists.", nil)
+	}
+	e.Auth = owner
+	e.Set(apiTokenStoreKey, token)
+	inst.touchApiToken(token)
+	if err := limitApiTokenList(e, apiTokenScope(token)); err != nil {
+		return err
+	}
+	return e.Next()
+}
+
+// limitApiTokenList adds the scope of the API token to the filter of a records list request, so that the pages and
+// their totals only count the records in scope.
+func limitApiTokenList(e *core.RequestEvent, scope tokenScope) error {
+	parts := strings.Split(strings.Trim(e.Request.URL.Path, "/"), "/")
+	if e.Request.Method != http.MethodGet || len(parts) != 4 || parts[0] != "api" || parts[1] != "collections" {
+		return nil
+	}
+	coll, err := e.App.FindCachedCollectionByNameOrId(parts[2])
+	if err != nil {
+		return nil
+	}
+	scopeFilter := scope.listFilter(coll.Name)
+	if scopeFilter == "" {
+		return nil
+	}
+	query := e.Request.URL.Query()
+	if filter := strings.TrimSpace(query.Get("filter")); filter != "" {
+		// a filter parsing on its own can not close the parentheses it is wrapped in
+		if _, err := fexpr.Parse(filter); err != nil {
+			return e.BadRequestError("Invalid filter.", err)
+		}
+		scopeFilter = "(" + filter + ") && " + scopeFilter
+	}
+	query.Set("filter", scopeFilter)
+	e.Request.URL.RawQuery = query.Encode()
+	return nil
+}
+
+// apiTokenRoute reports whether the route of the path accepts API tokens,
+// i.e. the DNS management API and the record API of the collections accessible with API tokens.
+func apiTokenRoute(urlPath string) bool {
+	if strings.HasPrefix(urlPath, CoreDnsApiPrefix+"/") {
+		return true
+	}
+	parts := strings.Split(strings.Trim(urlPath, "/"), "/")
+	return (len(parts) == 4 || len(parts) == 5) && parts[0] == "api" && parts[1] == "collections" && parts[3] == "records"
+}
+
+// apiTokenOwner returns the user or superuser the API token acts on behalf of.
+func apiTokenOwner(app core.App, token *core.Record) (*core.Record, error) {
+	if id := token.GetString("superuser"); id != "" {
+		return app.FindRecordById(core.CollectionNameSuperusers, id)
+	}
+	return app.FindRecordById("users", token.GetString("user"))
+}
+
+// touchApiToken updates the last used timestamp of the token, at most once per apiTokenLastUsedInterval.
+func (inst *Instance) touchApiToken(token *core.Record) {
+	now := time.Now()
+	if lastUsed := token.GetDateTime("last_used"); !lastUsed.IsZero() && now.Sub(lastUsed.Time()) < apiTokenLastUsedInterval {
+		return
+	}
+	lastUsed, _ := types.ParseDateTime(now)
+	_, err := inst.pb.DB().Update(apiTokenCollectionName, dbx.Params{"last_used": lastUsed.String()},
+		dbx.HashExp{"id": token.Id}).Execute()
+	if err != nil {
+		log.Errorf("Failed to update API token last used, id: %s, err: %+v", token.Id, err)
+	}
+}
+
+// bindApiTokenEvents limits the requests authenticated with API tokens to the scopes of the tokens,
+// on top of the API rules applying to the owners of the tokens, and validates the scopes of the tokens.
+func (inst *Instance) bindApiTokenEvents() {
+	log.Debug("Bind API token events...")
+
+	inst.pb.OnRecordsListRequest().BindFunc(func(e *core.RecordsListRequestEvent) error {
+		scope, ok := requestTokenScope(e.RequestEvent)
+		if !ok {
+			return e.Next()
+		}
+		if !slices.Contains(tokenCollections[e.Collection.Name], TokenRead) {
+			return e.ForbiddenError("The API token is not allowed to read the collection.", nil)
+		}
+		// the records out of scope are filtered out by the list query, see limitApiTokenList
+		if !scope.hasOperations(TokenRead) {
+			return e.ForbiddenError("The API token is not allowed to read records.", nil)
+		}
+		return e.Next()
+	})
+
+	scopeFunc := func(op string) func(e *core.RecordRequestEvent) error {
+		return func(e *core.RecordRequestEvent) error {
+			scope, ok := requestTokenScope(e.RequestEvent)
+			if !ok {
+				return e.Next()
+			}
+			if !slices.Contains(tokenCollections[e.Collection.Name], op) {
+				return e.ForbiddenError(fmt.Sprintf("The API token is not allowed to %s records of the collection.", op), nil)
+			}
+			if op == TokenRead && !scope.allowsCollectionRecord(op, e.Record) {
+				return e.NotFoundError("", nil)
+			}
+			if !scope.allowsCollectionRecord(op, e.Record) ||
+				op == TokenUpdate && !scope.allowsCollectionRecord(op, e.Record.Original()) {
+				return e.ForbiddenError(fmt.Sprintf("The API token is not allowed to %s the record.", op), nil)
+			}
+			return e.Next()
+		}
+	}
+	inst.pb.OnRecordViewRequest().BindFunc(scopeFunc(TokenRead))
+	inst.pb.OnRecordCreateRequest().BindFunc(scopeFunc(TokenCreate))
+	inst.pb.OnRecordUpdateRequest().BindFunc(scopeFunc(TokenUpdate))
+	inst.pb.OnRecordDeleteRequest().BindFunc(scopeFunc(TokenDelete))
+
+	inst.pb.OnRecordUpdateRequest(apiTokenCollectionName).BindFunc(func(e *core.RecordRequestEvent) error {
+		scope := apiTokenScope(e.Record)
+		if err := scope.normalize(); err != nil {
+			return e.BadRequestError("Invalid API token, "+err.Error()+".", err)
+		}
+		scope.setScope(e.Record)
+		return e.Next()
+	})
+}
+
+// bindApiTokenRoutes registers the API token routes.
+func (inst *Instance) bindApiTokenRoutes(g *router.RouterGroup[*core.RequestEvent]) {
+	g.POST("/tokens", inst.handleCreateApiToken).Bind(apis.RequireAuth("users", core.CollectionNameSuperusers))
+}
+
+// handleCreateApiToken issues an API token on behalf of the requesting user or superuser.
+// The token is returned once, only its hash is stored.
+func (inst *Instance) handleCreateApiToken(e *core.RequestEvent) error {
+	if _, ok := requestApiToken(e); ok {
+		return e.ForbiddenError("API tokens can not issue API tokens.", nil)
+	}
+	body := struct {
+		Name    string         `json:"name"`
+		Expires types.DateTime `json:"expires"`
+		tokenScope
+	}{}
+	if err := e.BindBody(&body); err != nil {
+		return e.BadRequestError("Failed to decode API token.", err)
+	}
+	if strings.TrimSpace(body.Name) == "" {
+		return e.BadRequestError("Invalid API token, name is required.",
+			validation.Errors{"name": validation.NewError("validation_required", "name is required")})
+	}
+	if err := body.tokenScope.normalize(); err != nil {
+		return e.BadRequestError("Invalid API token, "+err.Error()+".", err)
+	}
+	if !e.HasSuperuserAuth() {
+		for _, zone := range body.Zones {
+			if err := inst.requireZoneRole(e, zone, zoneReaders); err != nil {
+				return err
+			}
+		}
+	}
+
+	raw, err := newApiToken()
+	if err != nil {
+		return e.InternalServerError("Failed to generate API token.", err)
+	}
+	coll, err := inst.pb.FindCollectionByNameOrId(apiTokenCollectionName)
+	if err != nil {
+		return e.InternalServerError("Failed to find API token collection.", err)
+	}
+	token := core.NewRecord(coll)
+	token.Set("name", body.Name)
+	token.Set("token_hash", hashApiToken(raw))
+	token.Set("prefix", raw[:apiTokenHintLength])
+	token.Set("expires", body.Expires)
+	body.tokenScope.setScope(token)
+	if e.HasSuperuserAuth() {
+		token.Set("superuser", e.Auth.Id)
+	} else {
+		token.Set("user", e.Auth.Id)
+	}
+	if err = inst.pb.Save(token); err != nil {
+		return e.BadRequestError("Failed to save API token.", err)
+	}
+	log.Infof("Issued API token, name: %s, id: %s, by: %s", body.Name, token.Id, requestActor(e).Name)
+
+	return e.JSON(http.StatusOK, map[string]any{
+		"id":      token.Id,
+		"name":    token.GetString("name"),
+		"token":   raw,
+		"expires": token.GetDateTime("expires"),
+	})
+}
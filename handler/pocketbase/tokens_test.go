@@ -0,0 +1,87 @@
+package pocketbase
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenScopeAllowsRecord(t *testing.T) {
+	scope := tokenScope{
+		Zones:        []string{"example.com."},
+		NamePatterns: []string{"_acme-challenge.*"},
+		RecordTypes:  []string{"TXT"},
+		Operations:   []string{TokenRead, TokenCreate},
+	}
+	assert.NoError(t, scope.normalize())
+
+	tests := []struct {
+		name       string
+		op         string
+		zone       string
+		recordName string
+		recordType string
+		allowed    bool
+	}{
+		{name: "in scope", op: TokenCreate, zone: "example.com.", recordName: "_acme-challenge.www.example.com.", recordType: "TXT", allowed: true},
+		{name: "case and fqdn insensitive", op: TokenRead, zone: "Example.com", recordName: "_ACME-challenge.example.com", recordType: "txt", allowed: true},
+		{name: "operation not granted", op: TokenDelete, zone: "example.com.", recordName: "_acme-challenge.example.com.", recordType: "TXT"},
+		{name: "other zone", op: TokenCreate, zone: "example.org.", recordName: "_acme-challenge.example.org.", recordType: "TXT"},
+		{name: "other name", op: TokenCreate, zone: "example.com.", recordName: "www.example.com.", recordType: "TXT"},
+		{name: "other type", op: TokenCreate, zone: "example.com.", recordName: "_acme-challenge.example.com.", recordType: "A"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.allowed, scope.allowsRecord(tt.op, tt.zone, tt.recordName, tt.recordType))
+		})
+	}
+
+	assert.False(t, scope.allowsZone("example.com.", TokenRead))
+	unrestricted := tokenScope{Zones: []string{"example.com."}, Operations: tokenWrites}
+	assert.True(t, unrestricted.allowsZone("example.com.", tokenWrites...))
+	assert.False(t, unrestricted.allowsZone("example.org.", TokenRead))
+}
+
+func TestTokenScopeNormalize(t *testing.T) {
+	assert.Error(t, (&tokenScope{}).normalize())
+	assert.Error(t, (&tokenScope{NamePatterns: []string{"[a-"}, Operations: []string{TokenRead}}).normalize())
+	assert.Error(t, (&tokenScope{RecordTypes: []string{"PTR"}, Operations: []string{TokenRead}}).normalize())
+	assert.Error(t, (&tokenScope{Zones: []string{"example..com"}, Operations: []string{TokenRead}}).normalize())
+}
+
+func TestApiTokenRoute(t *testing.T) {
+	assert.True(t, apiTokenRoute("/api/coredns/zones/example.com./snapshots"))
+	assert.True(t, apiTokenRoute("/api/collections/coredns_records/records"))
+	assert.True(t, apiTokenRoute("/api/collections/coredns_records/records/123"))
+	assert.False(t, apiTokenRoute("/api/collections/users/auth-refresh"))
+	assert.False(t, apiTokenRoute("/api/settings"))
+	assert.False(t, apiTokenRoute("/api/realtime"))
+}
+
+func TestNewApiToken(t *testing.T) {
+	token, err := newApiToken()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, apiTokenPrefix))
+	assert.Len(t, hashApiToken(token), 64)
+	assert.NotEqual(t, token, hashApiToken(token))
+}
+
+func TestTokenScopeListFilter(t *testing.T) {
+	scope := tokenScope{
+		Zones:        []string{"example.com."},
+		NamePatterns: []string{"_acme-challenge.*", "www.example.com.", "host-?.example.com."},
+		RecordTypes:  []string{"TXT", "A"},
+		Operations:   []string{TokenRead},
+	}
+	assert.NoError(t, scope.normalize())
+
+	assert.Equal(t, `((zone ~ 'example.com.%' && zone !~ 'example.com._%')) && (record_type = 'TXT' || record_type = 'A') && `+
+		`(name ~ '\_acme-challenge.%.' || (name ~ 'www.example.com.%' && name !~ 'www.example.com._%') || `+
+		`(name ~ 'host-_.example.com.%' && name !~ 'host-_.example.com._%'))`, scope.listFilter(recordCollectionName))
+	assert.Equal(t, `((name ~ 'example.com.%' && name !~ 'example.com._%'))`, scope.listFilter(zoneCollectionName))
+	assert.Empty(t, (&tokenScope{Operations: []string{TokenRead}}).listFilter(recordCollectionName))
+	assert.Error(t, (&tokenScope{NamePatterns: []string{"[ab].example.com."}, Operations: []string{TokenRead}}).normalize())
+	assert.Error(t, (&tokenScope{NamePatterns: []string{"x'.example.com."}, Operations: []string{TokenRead}}).normalize())
+}
@@ -0,0 +1,85 @@
+package pocketbase
+
+import (
+	"errors"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+// bindRecordValidators validates records created or updated through the PocketBase API and UI,
+// so that invalid content is rejected at write time instead of failing at query time.
+func (inst *Instance) bindRecordValidators() {
+	validateFunc := func(e *core.RecordRequestEvent) error {
+		// the zone may be given by the relation only, or by its name only
+		if ok, err := fillZoneFromRelation(e.App, e.Record); err != nil {
+			return e.BadRequestError("Invalid DNS record, "+err.Error()+".", err)
+		} else if !ok {
+			if err = fillRelationFromZone(e.App, e.Record); err != nil {
+				return e.InternalServerError("Failed to find zone.", err)
+			}
+		}
+		if err := validateRecord(e.Record); err != nil {
+			return e.BadRequestError("Invalid DNS record, "+err.Error()+".", toValidationErrors(err))
+		}
+		return e.Next()
+	}
+	inst.pb.OnRecordCreateRequest(recordCollectionName).BindFunc(validateFunc)
+	inst.pb.OnRecordUpdateRequest(recordCollectionName).BindFunc(validateFunc)
+
+	// whatever the write path, e.g. the typed API or the change sets
+	cnameFunc := func(e *core.RecordEvent) error {
+		if err := inst.fillCnameZone(e.Record); err != nil {
+			return err
+		}
+		return e.Next()
+	}
+	inst.pb.OnRecordCreate(recordCollectionName).BindFunc(cnameFunc)
+	inst.pb.OnRecordUpdate(recordCollectionName).BindFunc(cnameFunc)
+}
+
+// fillCnameZone sets the zone of a CNAME target without zone to the served zone the target belongs to, so that
+// the target is chased at query time. The zone is left empty for targets outside the served zones.
+func (inst *Instance) fillCnameZone(rec *core.Record) error {
+	if rec.GetString("record_type") != "CNAME" {
+		return nil
+	}
+	var cname m.CNAMERecord
+	if err := rec.UnmarshalJSONField("content", &cname); err != nil || cname.Zone != "" || cname.Host == "" {
+		// invalid contents are rejected by the validators
+		return nil
+	}
+	zone, err := inst.MatchZone(cname.Host)
+	if err != nil || zone == "" {
+		return err
+	}
+	cname.Zone = zone
+	rec.Set("content", cname)
+	return nil
+}
+
+// validateRecord validates the zone, name, type and content of a record of the records collection.
+func validateRecord(rec *core.Record) error {
+	return m.ValidateRecord(toModelRecord(rec))
+}
+
+// toModelRecord converts a record of the records collection into its model.
+func toModelRecord(rec *core.Record) *m.Record {
+	return &m.Record{
+		Zone:       rec.GetString("zone"),
+		Name:       rec.GetString("name"),
+		RecordType: rec.GetString("record_type"),
+		Ttl:        uint32(rec.GetInt("ttl")),
+		Content:    rec.GetString("content"),
+	}
+}
+
+// toValidationErrors converts a model validation error into field errors understood by the PocketBase UI.
+func toValidationErrors(err error) error {
+	var vErr *m.ValidationError
+	if errors.As(err, &vErr) {
+		return validation.Errors{vErr.Field: validation.NewError("validation_invalid_"+vErr.Field, vErr.Message)}
+	}
+	return err
+}
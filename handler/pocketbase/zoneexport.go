@@ -0,0 +1,147 @@
+package pocketbase
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+// bindZoneExportRoutes registers the zone file export route.
+func (inst *Instance) bindZoneExportRoutes(g *router.RouterGroup[*core.RequestEvent]) {
+	g.GET("/zones/{zone}/export", inst.handleExportZone).Bind(apis.RequireAuth())
+}
+
+// handleExportZone answers with the zone file of the enabled records of the zone, as exportZoneFile renders it,
+// or with the zone in octoDNS YAML format with ?format=octodns.
+// API tokens must allow reading all records of the zone.
+func (inst *Instance) handleExportZone(e *core.RequestEvent) error {
+	zone := strings.ToLower(dns.Fqdn(e.Request.PathValue("zone")))
+	if err := inst.requireZoneRole(e, zone, zoneReaders); err != nil {
+		return err
+	}
+	if wantsOctodns(e) {
+		data, err := inst.exportOctodnsYaml(inst.pb, zone)
+		if err != nil {
+			return firstApiError(e, err, "Failed to export the octoDNS zone.")
+		}
+		e.Response.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%syaml"`, zone))
+		return e.Blob(http.StatusOK, octodnsContentType+"; charset=utf-8", data)
+	}
+	zoneFile, err := inst.exportZoneFile(inst.pb, zone)
+	if err != nil {
+		return firstApiError(e, err, "Failed to export the zone file.")
+	}
+	e.Response.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%szone"`, zone))
+	return e.Blob(http.StatusOK, zoneFileContentType+"; charset=utf-8", []byte(zoneFile))
+}
+
+// exportZoneFile renders the enabled records of the zone as a master file, as they are served:
+// $ORIGIN and $TTL first, then the SOA record with the serial of the zone and the other records in canonical order.
+// Template records are expanded into the records they generate, see composeServedZone.
+func (inst *Instance) exportZoneFile(app core.App, zone string) (string, error) {
+	zoneRec, soa, rrs, err := inst.composeServedZone(app, zone)
+	if err != nil {
+		return "", err
+	}
+
+	defaultTtl := refillTtl(0, toModelZone(zoneRec), uint32(inst.defaultTtl), uint32(inst.minTtl), uint32(inst.maxTtl))
+	if defaultTtl == 0 {
+		defaultTtl = soa.Hdr.Ttl
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "$ORIGIN %s\n", zone)
+	fmt.Fprintf(&sb, "$TTL %d\n", defaultTtl)
+	sb.WriteString(soa.String())
+	sb.WriteString("\n")
+	for _, rr := range rrs {
+		sb.WriteString(rr.String())
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+// TransferZone returns the records of a zone transfer (AXFR) of the zone: the SOA record, the other served records
+// in canonical order and the SOA record again.
+func (inst *Instance) TransferZone(zone string) ([]dns.RR, error) {
+	_, soa, rrs, err := inst.composeServedZone(inst.pb, zone)
+	if err != nil {
+		return nil, err
+	}
+	transfer := make([]dns.RR, 0, len(rrs)+2)
+	transfer = append(transfer, soa)
+	transfer = append(transfer, rrs...)
+	return append(transfer, soa), nil
+}
+
+// composeServedZone composes the enabled records of the zone as they are served, template records being expanded,
+// see composeZoneRRs. It returns the zone record, the SOA record with the serial of the zone and the other records
+// in canonical order. Zones without SOA record get the SOA record generated from their settings.
+func (inst *Instance) composeServedZone(app core.App, zone string) (*core.Record, *dns.SOA, []dns.RR, error) {
+	zoneRec, err := app.FindFirstRecordByData(zoneCollectionName, "name", zone)
+	if err != nil {
+		return nil, nil, nil, apis.NewNotFoundError("Zone not found.", err)
+	}
+	recs, err := app.FindRecordsByFilter(recordCollectionName, "zone = {:zone} && disabled = false", "name,record_type,created", 0, 0,
+		dbx.Params{"zone": zone})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var soa *dns.SOA
+	rrs := make([]dns.RR, 0, len(recs))
+	for _, rr := range inst.composeZoneRRs(recs) {
+		if r, ok := rr.(*dns.SOA); ok {
+			soa = r
+			continue
+		}
+		rrs = append(rrs, rr)
+	}
+	if soa == nil {
+		rr, _, err := inst.ComposeSOARecord(&m.Record{Zone: zone, Name: zone, RecordType: "SOA", Content: "{}"})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		soa = rr.(*dns.SOA)
+	}
+	// the cached zone settings may lag behind the latest serial bump
+	if serial := uint32(zoneRec.GetInt("serial")); serial != 0 {
+		soa.Serial = serial
+	}
+	sortCanonical(rrs)
+	return zoneRec, soa, rrs, nil
+}
+
+// sortCanonical sorts the records in the canonical order of RFC 4034, section 6.1, by owner name, then by type
+// and then by record data.
+func sortCanonical(rrs []dns.RR) {
+	sort.SliceStable(rrs, func(i, j int) bool {
+		if c := compareCanonicalNames(rrs[i].Header().Name, rrs[j].Header().Name); c != 0 {
+			return c < 0
+		}
+		if rrs[i].Header().Rrtype != rrs[j].Header().Rrtype {
+			return rrs[i].Header().Rrtype < rrs[j].Header().Rrtype
+		}
+		return m.RdataString(rrs[i]) < m.RdataString(rrs[j])
+	})
+}
+
+// compareCanonicalNames compares two names in canonical order, label by label from the root, case-insensitively.
+func compareCanonicalNames(a string, b string) int {
+	la := dns.SplitDomainName(strings.ToLower(a))
+	lb := dns.SplitDomainName(strings.ToLower(b))
+	for i, j := len(la)-1, len(lb)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if c := strings.Compare(la[i], lb[j]); c != 0 {
+			return c
+		}
+	}
+	return len(la) - len(lb)
+}
@@ -0,0 +1,75 @@
+package pocketbase
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+func TestSortCanonical(t *testing.T) {
+	lines := []string{
+		"z.example.com. 300 IN A 192.0.2.1",
+		"example.com. 300 IN MX 10 mx.example.com.",
+		"a.b.example.com. 300 IN A 192.0.2.1",
+		"B.example.com. 300 IN A 192.0.2.1",
+		"example.com. 300 IN NS ns2.example.com.",
+		"example.com. 300 IN NS ns1.example.com.",
+		"www.example.com. 300 IN AAAA 2001:db8::1",
+		"www.example.com. 300 IN A 192.0.2.2",
+		"*.example.com. 300 IN A 192.0.2.1",
+	}
+	rrs := make([]dns.RR, 0, len(lines))
+	for _, line := range lines {
+		rr, err := dns.NewRR(line)
+		require.NoError(t, err)
+		rrs = append(rrs, rr)
+	}
+
+	sortCanonical(rrs)
+	got := make([]string, 0, len(rrs))
+	for _, rr := range rrs {
+		got = append(got, rr.Header().Name+" "+dns.TypeToString[rr.Header().Rrtype]+" "+m.RdataString(rr))
+	}
+	assert.Equal(t, []string{
+		"example.com. NS ns1.example.com.",
+		"example.com. NS ns2.example.com.",
+		"example.com. MX 10 mx.example.com.",
+		"*.example.com. A 192.0.2.1",
+		"B.example.com. A 192.0.2.1",
+		"a.b.example.com. A 192.0.2.1",
+		"www.example.com. A 192.0.2.2",
+		"www.example.com. AAAA 2001:db8::1",
+		"z.example.com. A 192.0.2.1",
+	}, got)
+}
+
+func TestTransferZone(t *testing.T) {
+	inst := newMigratedInstance(t)
+	importTestZone(t, inst, "example.com.", `$ORIGIN example.com.
+@   3600 IN SOA ns1 hostmaster 1 7200 3600 1209600 300
+@   3600 IN NS  ns1
+www  300 IN A   192.0.2.80
+ns1 3600 IN A   192.0.2.53
+`)
+
+	rrs, err := inst.TransferZone("example.com.")
+	require.NoError(t, err)
+	got := make([]string, 0, len(rrs))
+	for _, rr := range rrs {
+		got = append(got, dns.TypeToString[rr.Header().Rrtype]+" "+rr.Header().Name+" "+m.RdataString(rr))
+	}
+	require.Len(t, got, 5)
+	assert.Equal(t, got[0], got[4], "the SOA record is first and last")
+	assert.Contains(t, got[0], "SOA example.com. ns1.example.com. hostmaster.example.com.")
+	assert.Equal(t, []string{
+		"NS example.com. ns1.example.com.",
+		"A ns1.example.com. 192.0.2.53",
+		"A www.example.com. 192.0.2.80",
+	}, got[1:4])
+
+	_, err = inst.TransferZone("example.org.")
+	assert.Error(t, err)
+}
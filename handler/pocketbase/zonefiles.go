@@ -0,0 +1,201 @@
+package pocketbase
+
+import (
+	"database/sql"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+// zoneFileExportMaxDelays bounds the debouncing of the exports of a zone changing continuously,
+// it is exported at the latest after this many delays since its first pending change.
+const zoneFileExportMaxDelays = 10
+
+// zoneFileExports debounces the exports of the zone files of the changed zones.
+type zoneFileExports struct {
+	// mu guards pending
+	mu      sync.Mutex
+	pending map[string]*pendingZoneFileExport
+	// writeMu serializes the writes, so that the last write holds the latest records
+	writeMu sync.Mutex
+}
+
+// pendingZoneFileExport is a scheduled export of a zone file, with the time of the first change it exports.
+type pendingZoneFileExport struct {
+	timer *time.Timer
+	first time.Time
+}
+
+// zoneFileExportPath returns the path of the exported zone file of the zone, named like the files of the export route.
+func zoneFileExportPath(dir string, zone string) string {
+	return filepath.Join(dir, zone+"zone")
+}
+
+// bindZoneFileExportEvents keeps the exported zone files up to date: the zones of the changed records and
+// the changed zones are exported once their changes settle for the export delay.
+func (inst *Instance) bindZoneFileExportEvents() {
+	if inst.exportDir == "" {
+		return
+	}
+	log.Debug("Bind zone file export events...")
+
+	// the zone of the record and the original zone of a moved record
+	recordFunc := func(e *core.RecordEvent) error {
+		inst.scheduleZoneFileExport(e.Record.GetString("zone"))
+		if original := e.Record.Original(); original != nil && original.GetString("zone") != e.Record.GetString("zone") {
+			inst.scheduleZoneFileExport(original.GetString("zone"))
+		}
+		return e.Next()
+	}
+	inst.pb.OnRecordAfterCreateSuccess(recordCollectionName).BindFunc(recordFunc)
+	inst.pb.OnRecordAfterUpdateSuccess(recordCollectionName).BindFunc(recordFunc)
+	inst.pb.OnRecordAfterDeleteSuccess(recordCollectionName).BindFunc(recordFunc)
+
+	// the zone and the original name of a renamed zone
+	zoneFunc := func(e *core.RecordEvent) error {
+		inst.scheduleZoneFileExport(e.Record.GetString("name"))
+		if original := e.Record.Original(); original != nil && original.GetString("name") != e.Record.GetString("name") {
+			inst.scheduleZoneFileExport(original.GetString("name"))
+		}
+		return e.Next()
+	}
+	inst.pb.OnRecordAfterCreateSuccess(zoneCollectionName).BindFunc(zoneFunc)
+	inst.pb.OnRecordAfterUpdateSuccess(zoneCollectionName).BindFunc(zoneFunc)
+	inst.pb.OnRecordAfterDeleteSuccess(zoneCollectionName).BindFunc(zoneFunc)
+}
+
+// scheduleZoneFileExport exports the zone file of the zone once it did not change for the export delay.
+func (inst *Instance) scheduleZoneFileExport(zone string) {
+	if zone == "" {
+		return
+	}
+	exports := &inst.zoneFileExports
+	exports.mu.Lock()
+	defer exports.mu.Unlock()
+	if pending, ok := exports.pending[zone]; ok {
+		if time.Since(pending.first) < zoneFileExportMaxDelays*inst.exportDelay {
+			pending.timer.Reset(inst.exportDelay)
+		}
+		return
+	}
+	if exports.pending == nil {
+		exports.pending = make(map[string]*pendingZoneFileExport)
+	}
+	exports.pending[zone] = &pendingZoneFileExport{
+		first: time.Now(),
+		timer: time.AfterFunc(inst.exportDelay, func() {
+			exports.mu.Lock()
+			delete(exports.pending, zone)
+			exports.mu.Unlock()
+			if err := inst.writeZoneFileExport(zone); err != nil {
+				log.Errorf("Failed to export zone file, zone: %s, err: %+v", zone, err)
+			}
+		}),
+	}
+}
+
+// initZoneFileExports exports the zone files of all served zones at startup and removes the zone files of the zones
+// which are no longer served, the export directory is dedicated to the exported zone files.
+func (inst *Instance) initZoneFileExports() {
+	if inst.exportDir == "" {
+		return
+	}
+	log.Infof("Start zone file exports, dir: %s, delay: %s", inst.exportDir, inst.exportDelay)
+	go func() {
+		zones, err := inst.pb.FindAllRecords(zoneCollectionName)
+		if err != nil {
+			log.Errorf("Failed to list zones for zone file exports, err: %+v", err)
+			return
+		}
+		exported := make(map[string]bool, len(zones))
+		for _, zone := range zones {
+			name := zone.GetString("name")
+			exported[filepath.Base(zoneFileExportPath(inst.exportDir, name))] = true
+			if err = inst.writeZoneFileExport(name); err != nil {
+				log.Errorf("Failed to export zone file, zone: %s, err: %+v", name, err)
+			}
+		}
+
+		entries, err := os.ReadDir(inst.exportDir)
+		if err != nil {
+			log.Errorf("Failed to read zone file export directory, dir: %s, err: %+v", inst.exportDir, err)
+			return
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".zone") || exported[entry.Name()] {
+				continue
+			}
+			log.Infof("Removing zone file of a zone which no longer exists, file: %s", entry.Name())
+			if err = os.Remove(filepath.Join(inst.exportDir, entry.Name())); err != nil {
+				log.Errorf("Failed to remove zone file, file: %s, err: %+v", entry.Name(), err)
+			}
+		}
+	}()
+}
+
+// writeZoneFileExport writes the zone file of the zone atomically, or removes it if the zone does not exist
+// or is disabled.
+func (inst *Instance) writeZoneFileExport(zone string) error {
+	inst.zoneFileExports.writeMu.Lock()
+	defer inst.zoneFileExports.writeMu.Unlock()
+
+	path := zoneFileExportPath(inst.exportDir, zone)
+	zoneRec, err := inst.pb.FindFirstRecordByData(zoneCollectionName, "name", zone)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && zoneRec.GetString("state") == m.ZoneStateDisabled {
+		if err = os.Remove(path); err == nil {
+			log.Infof("Removed zone file of a zone which is not served, zone: %s", zone)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	zoneFile, err := inst.exportZoneFile(inst.pb, zone)
+	if err != nil {
+		return err
+	}
+	if err = writeFileAtomic(path, []byte(zoneFile)); err != nil {
+		return err
+	}
+	log.Debugf("Exported zone file, zone: %s, path: %s", zone, path)
+	return nil
+}
+
+// writeFileAtomic writes the file through a temporary file in the same directory renamed over it,
+// so that readers see either the previous or the new content.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// a no-op once renamed
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,33 @@
+package pocketbase
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := zoneFileExportPath(dir, "example.com.")
+	assert.Equal(t, filepath.Join(dir, "example.com.zone"), path)
+
+	require.NoError(t, writeFileAtomic(path, []byte("first\n")))
+	require.NoError(t, writeFileAtomic(path, []byte("second\n")))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "second\n", string(data))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+
+	// no temporary file is left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	assert.Error(t, writeFileAtomic(filepath.Join(dir, "missing", "example.org.zone"), []byte("x")))
+}
@@ -0,0 +1,482 @@
+package pocketbase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/miekg/dns"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+// zoneFileFormat selects the master file format of zones.
+const zoneFileFormat = "zone"
+
+// zoneImportMaxSize limits the size of zone files uploaded to the import route.
+const zoneImportMaxSize = 16 << 20
+
+// errZoneImportFailed rolls back the transaction of an import with errors.
+var errZoneImportFailed = errors.New("zone import failed")
+
+// zoneImportOptions control how a zone file is imported.
+type zoneImportOptions struct {
+	// DryRun rolls back the import, only reporting what it would change.
+	DryRun bool
+	// Overwrite replaces the existing RRsets differing from the zone file, they are kept and reported as conflicts otherwise.
+	Overwrite bool
+	// CreateZone creates the zone if it does not exist yet.
+	CreateZone bool
+	// Managed marks the imported records as managed by the GitOps directory, they can only be changed by reconciles.
+	// The managed records of the zone which are not in the zone file are released.
+	Managed bool
+	// Prune deletes the RRsets of the zone which are not in the zone file, except the SOA record.
+	Prune bool
+}
+
+// zoneImportIssue is a RRset of a zone file which is not imported.
+type zoneImportIssue struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// zoneImportReport tells what an import of a zone file changed, or would change for a dry run.
+// The counts are of RRsets, except for Records which counts the parsed records.
+type zoneImportReport struct {
+	Zone        string             `json:"zone"`
+	DryRun      bool               `json:"dry_run"`
+	Records     int                `json:"records"`
+	Created     int                `json:"created"`
+	Replaced    int                `json:"replaced"`
+	Unchanged   int                `json:"unchanged"`
+	Unsupported []*zoneImportIssue `json:"unsupported"`
+	Conflicts   []*zoneImportIssue `json:"conflicts"`
+	Errors      []string           `json:"errors"`
+	Warnings    []string           `json:"warnings,omitempty"`
+	// Pruned and Unmanaged are the RRsets of the zone which are not in the zone file, for managed or pruning imports.
+	Pruned    []*zoneImportIssue `json:"pruned,omitempty"`
+	Unmanaged []*zoneImportIssue `json:"unmanaged,omitempty"`
+}
+
+// changed reports whether the import changed the zone.
+func (r *zoneImportReport) changed() bool {
+	return r.Created+r.Replaced+len(r.Pruned) > 0
+}
+
+// zoneFileRRset is a RRset parsed from a zone file, with the TTL of its first record.
+type zoneFileRRset struct {
+	Name string
+	Type string
+	Ttl  uint32
+	RRs  []dns.RR
+}
+
+// bindZoneImportRoutes registers the zone file import route.
+func (inst *Instance) bindZoneImportRoutes(g *router.RouterGroup[*core.RequestEvent]) {
+	g.POST("/zones/{zone}/import", inst.handleImportZone).Bind(apis.RequireAuth())
+}
+
+// handleImportZone imports the zone file in the request body, or in the file field of a multipart form,
+// into the zone, ?format=octodns reads the zone in octoDNS YAML format instead. ?dry_run=true only reports what the import would change, ?overwrite=true replaces
+// the RRsets conflicting with the zone file. Superusers may import zones which do not exist yet.
+func (inst *Instance) handleImportZone(e *core.RequestEvent) error {
+	zone := strings.ToLower(dns.Fqdn(e.Request.PathValue("zone")))
+	if err := m.ValidateZoneName(zone); err != nil {
+		return e.BadRequestError("Invalid zone, "+err.Error()+".", nil)
+	}
+	if err := inst.requireZoneRole(e, zone, zoneEditors); err != nil {
+		return err
+	}
+	opts := zoneImportOptions{
+		DryRun:     e.Request.URL.Query().Get("dry_run") == "true",
+		Overwrite:  e.Request.URL.Query().Get("overwrite") == "true",
+		CreateZone: e.HasSuperuserAuth(),
+	}
+
+	// limits multipart uploads as well, the form is parsed from the limited body
+	e.Request.Body = http.MaxBytesReader(e.Response, e.Request.Body, zoneImportMaxSize)
+	var body io.Reader = e.Request.Body
+	if strings.HasPrefix(e.Request.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := e.Request.FormFile("file")
+		if err != nil {
+			return e.BadRequestError("Failed to read the zone file.", err)
+		}
+		defer file.Close()
+		body = file
+	}
+	ctx := WithActor(e.Request.Context(), requestActor(e))
+	var report *zoneImportReport
+	var err error
+	if wantsOctodns(e) {
+		var oz *octodnsZone
+		if oz, err = parseOctodnsYaml(body, zone); err != nil {
+			return e.BadRequestError("Failed to parse the octoDNS zone, "+err.Error()+".", nil)
+		}
+		report, err = inst.importOctodnsZone(ctx, zone, oz, opts)
+	} else {
+		// files of the server must not be included through uploads
+		var rrs []dns.RR
+		if rrs, err = parseZoneFile(body, zone, "", false); err != nil {
+			return e.BadRequestError("Failed to parse the zone file, "+err.Error()+".", nil)
+		}
+		report, err = inst.importZoneFile(ctx, zone, rrs, opts)
+	}
+	if errors.Is(err, errZoneImportFailed) {
+		return e.JSON(http.StatusBadRequest, report)
+	}
+	if err != nil {
+		return firstApiError(e, err, "Failed to import the zone file.")
+	}
+	return e.JSON(http.StatusOK, report)
+}
+
+// parseZoneFile parses the records of a zone file in master file format, including the $ORIGIN, $TTL,
+// $INCLUDE and $GENERATE directives. Relative names are relative to the zone, and included files
+// relative to the directory of the file. $INCLUDE fails unless includes are allowed.
+func parseZoneFile(r io.Reader, zone string, file string, includeAllowed bool) ([]dns.RR, error) {
+	zp := dns.NewZoneParser(r, zone, file)
+	zp.SetIncludeAllowed(includeAllowed)
+	rrs := make([]dns.RR, 0)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		rrs = append(rrs, rr)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, err
+	}
+	return rrs, nil
+}
+
+// groupZoneFileRRsets groups the records of a zone file into RRsets in the order they appear,
+// reporting the records which can not be imported into the zone as unsupported.
+func groupZoneFileRRsets(zone string, rrs []dns.RR, report *zoneImportReport) []*zoneFileRRset {
+	sets := make([]*zoneFileRRset, 0)
+	index := make(map[string]*zoneFileRRset)
+	unsupported := make(map[string]bool)
+	skip := func(name, recordType, reason string) {
+		if key := name + " " + recordType; !unsupported[key] {
+			unsupported[key] = true
+			report.Unsupported = append(report.Unsupported, &zoneImportIssue{Name: name, Type: recordType, Reason: reason})
+		}
+	}
+	for _, rr := range rrs {
+		hdr := rr.Header()
+		name := strings.ToLower(hdr.Name)
+		recordType := dns.TypeToString[hdr.Rrtype]
+		switch {
+		case hdr.Class != dns.ClassINET:
+			skip(name, recordType, "class "+dns.ClassToString[hdr.Class]+" is not supported")
+			continue
+		case !dns.IsSubDomain(zone, name):
+			skip(name, recordType, "the name is outside the zone "+zone)
+			continue
+		case !m.IsSupportedRecordType(recordType):
+			skip(name, recordType, "the record type is not supported")
+			continue
+		}
+		key := name + " " + recordType
+		set, ok := index[key]
+		if !ok {
+			set = &zoneFileRRset{Name: name, Type: recordType, Ttl: hdr.Ttl}
+			index[key] = set
+			sets = append(sets, set)
+		}
+		set.RRs = append(set.RRs, rr)
+	}
+	return sets
+}
+
+// importZoneFile saves the records of a zone file as RRsets of the zone within one transaction,
+// after taking an automatic snapshot of the zone. RRsets equal to the existing ones are left untouched,
+// differing ones are conflicts unless overwritten. The zone integrity is checked, the serial bumped
+// and the caches invalidated once. The import is rolled back on any error, returning errZoneImportFailed
+// with the report listing the errors.
+func (inst *Instance) importZoneFile(ctx context.Context, zone string, rrs []dns.RR, opts zoneImportOptions) (*zoneImportReport, error) {
+	report := &zoneImportReport{
+		Zone:        zone,
+		DryRun:      opts.DryRun,
+		Records:     len(rrs),
+		Unsupported: []*zoneImportIssue{},
+		Conflicts:   []*zoneImportIssue{},
+		Errors:      []string{},
+	}
+	sets := groupZoneFileRRsets(zone, rrs, report)
+	ctx = deferCacheInvalidation(withoutSerialBumps(ctx))
+	if opts.Managed {
+		ctx = withManagedChanges(ctx, true)
+	}
+
+	err := inst.pb.RunInTransaction(func(txApp core.App) error {
+		snapshot, err := inst.ensureImportZone(ctx, txApp, zone, opts)
+		if err != nil {
+			return err
+		}
+		// pruned first, a pruned CNAME may make way for other types of its name
+		if opts.Managed || opts.Prune {
+			if err := inst.importOtherRRsets(ctx, txApp, zone, sets, opts, report); err != nil {
+				report.Errors = append(report.Errors, err.Error())
+				return errZoneImportFailed
+			}
+		}
+		for _, set := range sets {
+			if err := inst.importZoneFileRRset(ctx, txApp, zone, set, opts, report); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s %s: %s", set.Name, set.Type, err))
+			}
+		}
+		if len(report.Errors) > 0 {
+			return errZoneImportFailed
+		}
+		if err := inst.checkZoneIntegrity(txApp, zone); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			return errZoneImportFailed
+		}
+		if opts.DryRun {
+			return errPreviewRollback
+		}
+		// imports changing nothing keep the serial and take no snapshot, e.g. the seeds imported at every startup
+		if !report.changed() {
+			return nil
+		}
+		if snapshot != nil {
+			if err := saveZoneSnapshot(txApp, snapshot); err != nil {
+				return err
+			}
+		}
+		return bumpZoneSerial(txApp, zone)
+	})
+	if err != nil && !errors.Is(err, errPreviewRollback) {
+		return report, err
+	}
+	if opts.DryRun {
+		return report, nil
+	}
+
+	for _, set := range sets {
+		inst.invalidateRecordCaches(zone, set.Name, set.Type)
+	}
+	for _, issue := range report.Pruned {
+		inst.invalidateRecordCaches(zone, issue.Name, issue.Type)
+	}
+	inst.invalidateZoneCaches(zone)
+	logf := log.Debugf
+	if report.changed() {
+		logf = log.Infof
+	}
+	logf("Imported zone file, zone: %s, created: %d, replaced: %d, unchanged: %d, pruned: %d, conflicts: %d, unsupported: %d",
+		zone, report.Created, report.Replaced, report.Unchanged, len(report.Pruned), len(report.Conflicts), len(report.Unsupported))
+	return report, nil
+}
+
+// importZoneDefinition reads a zone file of the server in the format and imports it into the zone.
+// The files of the server are trusted like the Corefile, they may include other files.
+// Import errors are returned along with the report.
+func (inst *Instance) importZoneDefinition(ctx context.Context, zone string, path string, format string,
+	opts zoneImportOptions) (*zoneImportReport, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var report *zoneImportReport
+	switch format {
+	case octodnsFormat:
+		var oz *octodnsZone
+		if oz, err = parseOctodnsYaml(file, zone); err != nil {
+			return nil, err
+		}
+		report, err = inst.importOctodnsZone(ctx, zone, oz, opts)
+	default:
+		var rrs []dns.RR
+		if rrs, err = parseZoneFile(file, zone, path, true); err != nil {
+			return nil, err
+		}
+		report, err = inst.importZoneFile(ctx, zone, rrs, opts)
+	}
+	if errors.Is(err, errZoneImportFailed) {
+		err = fmt.Errorf("%w: %s", err, strings.Join(report.Errors, "; "))
+	}
+	return report, err
+}
+
+// ensureImportZone checks the zone exists, or creates it if allowed. It returns the snapshot of an existing zone
+// taken before the import, saved once the import changes the zone, nil for dry runs.
+func (inst *Instance) ensureImportZone(ctx context.Context, app core.App, zone string, opts zoneImportOptions) (*core.Record, error) {
+	if _, err := app.FindFirstRecordByData(zoneCollectionName, "name", zone); err == nil {
+		if opts.DryRun {
+			return nil, nil
+		}
+		return inst.newZoneSnapshot(ctx, app, zone, SnapshotAuto, "before zone file import")
+	}
+	if !opts.CreateZone {
+		return nil, apis.NewNotFoundError(fmt.Sprintf("Zone %s not found.", zone), nil)
+	}
+	coll, err := app.FindCollectionByNameOrId(zoneCollectionName)
+	if err != nil {
+		return nil, err
+	}
+	rec := core.NewRecord(coll)
+	rec.Set("name", zone)
+	rec.Set("state", m.ZoneStateActive)
+	log.Infof("Creating zone for zone file import, zone: %s", zone)
+	return nil, app.SaveWithContext(ctx, rec)
+}
+
+// importZoneFileRRset saves a RRset of a zone file, counting it in the report.
+func (inst *Instance) importZoneFileRRset(ctx context.Context, app core.App, zone string, set *zoneFileRRset,
+	opts zoneImportOptions, report *zoneImportReport) error {
+	contents := make([]any, 0, len(set.RRs))
+	for _, rr := range set.RRs {
+		content, err := inst.importContentFromRR(zone, rr)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(content)
+		if err != nil {
+			return err
+		}
+		err = m.ValidateRecord(&m.Record{Zone: zone, Name: set.Name, RecordType: set.Type, Ttl: set.Ttl, Content: string(data)})
+		if err != nil {
+			return err
+		}
+		contents = append(contents, content)
+	}
+
+	existing, err := findRRSet(app, zone, set.Name, set.Type)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(existing) == 0:
+		report.Created++
+	case sameRRsetContents(existing, set.Ttl, contents):
+		report.Unchanged++
+		if opts.Managed {
+			return markManaged(ctx, app, existing)
+		}
+		return nil
+	case !opts.Overwrite:
+		report.Conflicts = append(report.Conflicts, &zoneImportIssue{Name: set.Name, Type: set.Type,
+			Reason: "the RRset differs from the existing one"})
+		return nil
+	default:
+		report.Replaced++
+	}
+	return inst.replaceRRSet(ctx, app, zone, set.Name, set.Type, set.Ttl, contents)
+}
+
+// importOtherRRsets deletes the RRsets of the zone which are not in the zone file with opts.Prune,
+// and reports them as unmanaged otherwise, releasing their managed records with opts.Managed.
+// The SOA record is never pruned, octoDNS zones do not have one, nor are template records which zone files can not hold.
+func (inst *Instance) importOtherRRsets(ctx context.Context, app core.App, zone string, sets []*zoneFileRRset,
+	opts zoneImportOptions, report *zoneImportReport) error {
+	inFile := make(map[string]bool, len(sets))
+	for _, set := range sets {
+		inFile[set.Name+" "+set.Type] = true
+	}
+	recs, err := app.FindRecordsByFilter(recordCollectionName, "zone = {:zone}", "name,record_type,created", 0, 0,
+		dbx.Params{"zone": zone})
+	if err != nil {
+		return err
+	}
+	others := make(map[string][]*core.Record)
+	keys := make([]string, 0)
+	for _, rec := range recs {
+		key := rec.GetString("name") + " " + rec.GetString("record_type")
+		if inFile[key] {
+			continue
+		}
+		if _, ok := others[key]; !ok {
+			keys = append(keys, key)
+		}
+		others[key] = append(others[key], rec)
+	}
+
+	for _, key := range keys {
+		name, recordType, _ := strings.Cut(key, " ")
+		if opts.Prune && recordType != "SOA" && recordType != m.TemplateRecordType {
+			report.Pruned = append(report.Pruned, &zoneImportIssue{Name: name, Type: recordType,
+				Reason: "the RRset is not in the zone file"})
+			if err = inst.deleteRRSet(ctx, app, zone, name, recordType); err != nil {
+				return fmt.Errorf("%s %s: %w", name, recordType, err)
+			}
+			continue
+		}
+		report.Unmanaged = append(report.Unmanaged, &zoneImportIssue{Name: name, Type: recordType,
+			Reason: "the RRset is not in the zone file, it is kept"})
+		if opts.Managed {
+			if err = releaseManaged(ctx, app, others[key]); err != nil {
+				return fmt.Errorf("%s %s: %w", name, recordType, err)
+			}
+		}
+	}
+	return nil
+}
+
+// importContentFromRR converts a record of a zone file into its content model like contentFromRR,
+// CNAME targets within the zone belong to it even if the zone is only created by the import.
+func (inst *Instance) importContentFromRR(zone string, rr dns.RR) (any, error) {
+	_, content, err := m.ContentFromRR(rr)
+	if err != nil {
+		return nil, err
+	}
+	cname, ok := content.(m.CNAMERecord)
+	if !ok {
+		return content, nil
+	}
+	if cname.Zone, err = inst.MatchZone(cname.Host); err != nil {
+		return nil, err
+	}
+	if dns.IsSubDomain(zone, strings.ToLower(cname.Host)) && !dns.IsSubDomain(zone, cname.Zone) {
+		cname.Zone = zone
+	}
+	return cname, nil
+}
+
+// sameRRsetContents reports whether the existing records of a RRset hold the contents with the TTL, in any order.
+func sameRRsetContents(existing []*core.Record, ttl uint32, contents []any) bool {
+	if len(existing) != len(contents) {
+		return false
+	}
+	have := make([]string, 0, len(existing))
+	for _, rec := range existing {
+		if rec.GetInt("ttl") != int(ttl) || rec.GetBool("disabled") {
+			return false
+		}
+		have = append(have, normalizedJSON(rec.GetString("content")))
+	}
+	want := make([]string, 0, len(contents))
+	for _, content := range contents {
+		data, err := json.Marshal(content)
+		if err != nil {
+			return false
+		}
+		want = append(want, normalizedJSON(string(data)))
+	}
+	slices.Sort(have)
+	slices.Sort(want)
+	return slices.Equal(have, want)
+}
+
+// normalizedJSON returns the JSON document with sorted keys and without insignificant whitespace.
+func normalizedJSON(s string) string {
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return s
+	}
+	return string(b)
+}
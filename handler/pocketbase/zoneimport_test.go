@@ -0,0 +1,56 @@
+package pocketbase
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseZoneFile(t *testing.T) {
+	dir := t.TempDir()
+	include := filepath.Join(dir, "include.zone")
+	require.NoError(t, os.WriteFile(include, []byte("alias IN CNAME www\n"), 0o600))
+	zoneFile := `$TTL 600
+@	IN NS ns1
+ns1	IN A 192.0.2.53
+www	300 IN A 192.0.2.10
+www	300 IN A 192.0.2.11
+$GENERATE 1-3 host$ A 198.51.100.$
+1	IN PTR www
+$ORIGIN sub.example.com.
+api	IN AAAA 2001:db8::1
+other.net. IN A 192.0.2.1
+$INCLUDE ` + include + ` example.com.
+`
+
+	_, err := parseZoneFile(strings.NewReader(zoneFile), "example.com.", "", false)
+	assert.ErrorContains(t, err, "$INCLUDE")
+
+	rrs, err := parseZoneFile(strings.NewReader(zoneFile), "example.com.", filepath.Join(dir, "example.com.zone"), true)
+	require.NoError(t, err)
+	assert.Len(t, rrs, 11)
+
+	report := &zoneImportReport{}
+	sets := groupZoneFileRRsets("example.com.", rrs, report)
+	got := make([]string, 0, len(sets))
+	for _, set := range sets {
+		got = append(got, set.Name+" "+set.Type)
+	}
+	assert.Equal(t, []string{
+		"example.com. NS", "ns1.example.com. A", "www.example.com. A", "host1.example.com. A", "host2.example.com. A",
+		"host3.example.com. A", "api.sub.example.com. AAAA", "alias.example.com. CNAME",
+	}, got)
+	assert.Equal(t, uint32(300), sets[2].Ttl)
+	assert.Len(t, sets[2].RRs, 2)
+	assert.Equal(t, uint32(600), sets[0].Ttl)
+
+	unsupported := make([]string, 0, len(report.Unsupported))
+	for _, issue := range report.Unsupported {
+		unsupported = append(unsupported, issue.Name+" "+issue.Type)
+	}
+	assert.Equal(t, []string{"1.example.com. PTR", "other.net. A"}, unsupported)
+}
@@ -0,0 +1,326 @@
+package pocketbase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/log"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/miekg/dns"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/tinkernels/coredns-pocketbase/handler/pocketbase/model"
+)
+
+const zoneCollectionName = "coredns_zones"
+
+type serialCtxKey struct{}
+
+// withoutSerialBumps returns a context which skips the serial bumps of the saves and deletes made with it,
+// for batches of changes which bump the serial of the zone once.
+func withoutSerialBumps(ctx context.Context) context.Context {
+	return context.WithValue(ctx, serialCtxKey{}, true)
+}
+
+type cacheCtxKey struct{}
+
+// deferCacheInvalidation returns a context which skips the cache invalidations of the saves and deletes made with it,
+// for batches of changes which invalidate the caches once they are applied.
+func deferCacheInvalidation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheCtxKey{}, true)
+}
+
+// cacheInvalidationDeferred reports whether the context skips the cache invalidations.
+func cacheInvalidationDeferred(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	deferred, _ := ctx.Value(cacheCtxKey{}).(bool)
+	return deferred
+}
+
+// serialBumpsDisabled reports whether the context skips the serial bumps.
+func serialBumpsDisabled(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	disabled, _ := ctx.Value(serialCtxKey{}).(bool)
+	return disabled
+}
+
+// bindZoneEvents keeps the zones collection and the records collection consistent:
+//   - records are linked to the row of their zone, which is created on demand,
+//   - the records of frozen zones can not be changed,
+//   - the serial of a zone is bumped on every change of its records,
+//   - renaming a zone renames its records.
+//
+// Deleting a zone deletes its records through the cascading relation.
+func (inst *Instance) bindZoneEvents() {
+	log.Debug("Bind zone events...")
+
+	recordFunc := func(e *core.RecordEvent) error {
+		return e.App.RunInTransaction(func(txApp core.App) error {
+			e.App = txApp
+			if e.Type != core.ModelEventTypeDelete {
+				if err := linkRecordZone(txApp, e.Record); err != nil {
+					return err
+				}
+			}
+			if err := checkZoneWritable(txApp, e.Record.GetString("zone")); err != nil {
+				return err
+			}
+			if original := e.Record.Original(); e.Type == core.ModelEventTypeUpdate && original != nil &&
+				original.GetString("zone") != e.Record.GetString("zone") {
+				if err := checkZoneWritable(txApp, original.GetString("zone")); err != nil {
+					return err
+				}
+			}
+			if err := e.Next(); err != nil {
+				return err
+			}
+			if serialBumpsDisabled(e.Context) {
+				return nil
+			}
+			if err := bumpZoneSerial(txApp, e.Record.GetString("zone")); err != nil {
+				return err
+			}
+			if original := e.Record.Original(); e.Type == core.ModelEventTypeUpdate && original != nil &&
+				original.GetString("zone") != e.Record.GetString("zone") {
+				return bumpZoneSerial(txApp, original.GetString("zone"))
+			}
+			return nil
+		})
+	}
+	inst.pb.OnRecordCreate(recordCollectionName).BindFunc(recordFunc)
+	inst.pb.OnRecordUpdate(recordCollectionName).BindFunc(recordFunc)
+	inst.pb.OnRecordDelete(recordCollectionName).BindFunc(recordFunc)
+
+	inst.pb.OnRecordCreate(zoneCollectionName).BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.GetInt("serial") == 0 {
+			e.Record.Set("serial", nextSerial(0))
+		}
+		return e.Next()
+	})
+	inst.pb.OnRecordUpdate(zoneCollectionName).BindFunc(inst.cascadeZoneRename)
+	inst.pb.OnRecordDelete(zoneCollectionName).BindFunc(func(e *core.RecordEvent) error {
+		// deleting a zone deletes its records
+		if err := checkZoneWritable(e.App, e.Record.GetString("name")); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+
+	validateFunc := func(e *core.RecordRequestEvent) error {
+		if err := m.ValidateZoneName(e.Record.GetString("name")); err != nil {
+			return e.BadRequestError("Invalid DNS zone, "+err.Error()+".", toValidationErrors(err))
+		}
+		return e.Next()
+	}
+	inst.pb.OnRecordCreateRequest(zoneCollectionName).BindFunc(validateFunc)
+	inst.pb.OnRecordUpdateRequest(zoneCollectionName).BindFunc(validateFunc)
+
+	if inst.cacheCapacity > 0 {
+		cachePopFunc := func(e *core.RecordEvent) error {
+			log.Debugf("Deleting zone caches, zone: %s", e.Record.GetString("name"))
+			inst.zonesCache.Delete(ZonesCacheKey)
+			inst.zoneSettingsCache.Delete(e.Record.GetString("name"))
+			if original := e.Record.Original(); original != nil {
+				inst.zoneSettingsCache.Delete(original.GetString("name"))
+			}
+			return e.Next()
+		}
+		inst.pb.OnRecordAfterCreateSuccess(zoneCollectionName).BindFunc(cachePopFunc)
+		inst.pb.OnRecordAfterUpdateSuccess(zoneCollectionName).BindFunc(cachePopFunc)
+		inst.pb.OnRecordAfterDeleteSuccess(zoneCollectionName).BindFunc(cachePopFunc)
+	}
+}
+
+// linkRecordZone links a record to the row of its zone, creating the zone if it does not exist yet.
+// If only the relation of the record was set or changed, the zone name is taken from the related zone instead.
+func linkRecordZone(app core.App, rec *core.Record) error {
+	if ok, err := fillZoneFromRelation(app, rec); ok || err != nil {
+		return err
+	}
+	zoneName := rec.GetString("zone")
+	if zoneName == "" {
+		return nil
+	}
+	zone, err := findOrCreateZone(app, zoneName)
+	if err != nil {
+		return err
+	}
+	rec.Set("zone_id", zone.Id)
+	return nil
+}
+
+// fillZoneFromRelation sets the zone name of a record from its related zone,
+// if the relation was set without a zone name or changed without the zone name.
+// It reports whether the zone name was taken from the relation.
+func fillZoneFromRelation(app core.App, rec *core.Record) (bool, error) {
+	zoneId, zoneName := rec.GetString("zone_id"), rec.GetString("zone")
+	if zoneId == "" {
+		return false, nil
+	}
+	original := rec.Original()
+	relationChanged := zoneName == ""
+	if !rec.IsNew() && original != nil {
+		relationChanged = relationChanged ||
+			zoneId != original.GetString("zone_id") && zoneName == original.GetString("zone")
+	}
+	if !relationChanged {
+		return false, nil
+	}
+	zone, err := app.FindRecordById(zoneCollectionName, zoneId)
+	if err != nil {
+		return false, validation.Errors{"zone_id": validation.NewError("validation_invalid_zone_id", "zone not found")}
+	}
+	rec.Set("zone", zone.GetString("name"))
+	return true, nil
+}
+
+// fillRelationFromZone links a record to the row of its zone if the zone exists, and unlinks it otherwise,
+// so that the API rules of the records collection are checked against the members of the zone of the record.
+func fillRelationFromZone(app core.App, rec *core.Record) error {
+	zoneName := rec.GetString("zone")
+	if zoneName == "" {
+		return nil
+	}
+	zone, err := app.FindFirstRecordByData(zoneCollectionName, "name", zoneName)
+	if errors.Is(err, sql.ErrNoRows) {
+		rec.Set("zone_id", "")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	rec.Set("zone_id", zone.Id)
+	return nil
+}
+
+// findOrCreateZone returns the row of the zone, creating an active zone if it does not exist yet.
+func findOrCreateZone(app core.App, name string) (*core.Record, error) {
+	zone, err := app.FindFirstRecordByData(zoneCollectionName, "name", name)
+	if err == nil {
+		return zone, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	coll, err := app.FindCollectionByNameOrId(zoneCollectionName)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Creating zone for records, zone: %s", name)
+	zone = core.NewRecord(coll)
+	zone.Set("name", name)
+	zone.Set("state", m.ZoneStateActive)
+	if err = app.Save(zone); err != nil {
+		return nil, err
+	}
+	return zone, nil
+}
+
+// checkZoneWritable returns an error if the zone is frozen, zones which do not exist are writable.
+func checkZoneWritable(app core.App, name string) error {
+	if name == "" {
+		return nil
+	}
+	zone, err := app.FindFirstRecordByData(zoneCollectionName, "name", name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if zone.GetString("state") == m.ZoneStateFrozen {
+		return validation.Errors{"zone": validation.NewError("validation_zone_frozen",
+			fmt.Sprintf("zone %s is frozen, its records can not be changed", name))}
+	}
+	return nil
+}
+
+// cascadeZoneRename renames the records of a renamed zone and the CNAME targets pointing into it,
+// within the transaction of the rename.
+func (inst *Instance) cascadeZoneRename(e *core.RecordEvent) error {
+	original := e.Record.Original()
+	if original == nil || original.GetString("name") == e.Record.GetString("name") {
+		return e.Next()
+	}
+	oldName, newName := original.GetString("name"), e.Record.GetString("name")
+
+	return e.App.RunInTransaction(func(txApp core.App) error {
+		e.App = txApp
+		if err := e.Next(); err != nil {
+			return err
+		}
+		log.Infof("Renaming zone, from: %s, to: %s", oldName, newName)
+
+		// the records are consistent before and after the rename, skip the checks of the intermediate states
+		ctx := WithActor(WithoutIntegrityChecks(e.Context), inst.actors.actorOf(e.Context, e.Record))
+		recs, err := txApp.FindAllRecords(recordCollectionName, dbx.HashExp{"zone_id": e.Record.Id})
+		if err != nil {
+			return err
+		}
+		for _, rec := range recs {
+			rec.Set("zone", newName)
+			rec.Set("name", renameWithinZone(rec.GetString("name"), oldName, newName))
+			if err = txApp.SaveWithContext(ctx, rec); err != nil {
+				return err
+			}
+		}
+
+		cnames, err := txApp.FindAllRecords(recordCollectionName,
+			dbx.HashExp{"record_type": "CNAME"},
+			dbx.NewExp("json_extract(content, '$.zone') = {:zone}", dbx.Params{"zone": oldName}))
+		if err != nil {
+			return err
+		}
+		for _, rec := range cnames {
+			var cname m.CNAMERecord
+			if err = rec.UnmarshalJSONField("content", &cname); err != nil {
+				return err
+			}
+			cname.Host = renameWithinZone(cname.Host, oldName, newName)
+			cname.Zone = newName
+			rec.Set("content", cname)
+			if err = txApp.SaveWithContext(ctx, rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// renameWithinZone replaces the zone suffix of name, names outside of the zone are returned unchanged.
+func renameWithinZone(name string, oldZone string, newZone string) string {
+	if !dns.IsSubDomain(strings.ToLower(oldZone), strings.ToLower(name)) {
+		return name
+	}
+	return name[:len(name)-len(oldZone)] + newZone
+}
+
+// bumpZoneSerial increases the serial of the zone to the current Unix timestamp,
+// or by one if the serial is already ahead of it.
+func bumpZoneSerial(app core.App, zone string) error {
+	if zone == "" {
+		return nil
+	}
+	_, err := app.DB().NewQuery("UPDATE {{" + zoneCollectionName + "}} SET [[serial]] = MAX([[serial]] + 1, {:now}) WHERE [[name]] = {:zone}").
+		Bind(dbx.Params{"now": time.Now().Unix(), "zone": zone}).
+		Execute()
+	return err
+}
+
+// nextSerial returns the serial following current, the current Unix timestamp or current + 1 if it is ahead.
+func nextSerial(current uint32) uint32 {
+	now := uint32(time.Now().Unix())
+	if current >= now {
+		return current + 1
+	}
+	return now
+}
@@ -0,0 +1,33 @@
+package pocketbase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenameWithinZone(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "example.com.", expected: "example.org."},
+		{name: "www.example.com.", expected: "www.example.org."},
+		{name: "www.Example.COM.", expected: "www.example.org."},
+		{name: "www.notexample.com.", expected: "www.notexample.com."},
+		{name: "www.example.net.", expected: "www.example.net."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, renameWithinZone(tt.name, "example.com.", "example.org."))
+		})
+	}
+}
+
+func TestNextSerial(t *testing.T) {
+	now := uint32(time.Now().Unix())
+	assert.GreaterOrEqual(t, nextSerial(0), now)
+	assert.Equal(t, now+100, nextSerial(now+99))
+}
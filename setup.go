@@ -2,6 +2,8 @@ package coredns_pocketbase
 
 import (
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
@@ -85,6 +87,78 @@ func parseConfig(c *caddy.Controller) (conf *handler.Config, err error) {
 							0)
 					}
 				}
+			case "min_ttl":
+				if c.NextArg() {
+					intV, err := strconv.Atoi(c.Val())
+					if err != nil {
+						return nil, c.Errf("min_ttl is not an integer: %s", c.Val())
+					}
+					conf = conf.WithMinTtl(intV)
+				}
+			case "max_ttl":
+				if c.NextArg() {
+					intV, err := strconv.Atoi(c.Val())
+					if err != nil {
+						return nil, c.Errf("max_ttl is not an integer: %s", c.Val())
+					}
+					conf = conf.WithMaxTtl(intV)
+				}
+			case "disabled_zones":
+				if c.NextArg() {
+					conf = conf.WithDisabledZones(c.Val())
+				}
+			case "acme_dns_zone":
+				if c.NextArg() {
+					conf = conf.WithAcmeDnsZone(c.Val())
+				}
+			case "external_dns":
+				conf = conf.WithExternalDns(c.RemainingArgs()...)
+			case "integrity_override":
+				conf = conf.WithIntegrityOverride(true)
+			case "claim_resolver":
+				if c.NextArg() {
+					conf = conf.WithClaimResolver(c.Val())
+				}
+			case "gitops_dir":
+				if c.NextArg() {
+					conf = conf.WithGitopsDir(c.Val())
+				}
+			case "gitops_interval":
+				if c.NextArg() {
+					interval, err := time.ParseDuration(c.Val())
+					if err != nil {
+						return nil, c.Errf("gitops_interval is not a duration: %s", c.Val())
+					}
+					conf = conf.WithGitopsInterval(interval)
+				}
+			case "gitops_prune":
+				conf = conf.WithGitopsPrune(true)
+			case "export_dir":
+				if c.NextArg() {
+					conf = conf.WithExportDir(c.Val())
+				}
+			case "export_delay":
+				if c.NextArg() {
+					delay, err := time.ParseDuration(c.Val())
+					if err != nil {
+						return nil, c.Errf("export_delay is not a duration: %s", c.Val())
+					}
+					conf = conf.WithExportDelay(delay)
+				}
+			case "record":
+				args := c.RemainingArgs()
+				if len(args) < 2 {
+					return nil, c.ArgErr()
+				}
+				conf = conf.WithRecord(args[0], seedRecord(args[1:]))
+			case "seed_file":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, c.ArgErr()
+				}
+				conf = conf.WithSeedFile(args[0], args[1])
+			case "seed_overwrite":
+				conf = conf.WithSeedOverwrite(true)
 			default:
 				if c.Val() != "}" {
 					return nil, c.Errf("unknown property '%s'", c.Val())
@@ -98,3 +172,20 @@ func parseConfig(c *caddy.Controller) (conf *handler.Config, err error) {
 	}
 	return
 }
+
+// seedRecord joins the arguments of a record seed into a master file line. The Corefile drops the quotes of the
+// arguments, the arguments which need them, e.g. TXT strings with spaces, are quoted again. A single argument is the
+// whole record.
+func seedRecord(args []string) string {
+	if len(args) == 1 {
+		return args[0]
+	}
+	fields := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t;\"()") {
+			arg = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+		}
+		fields = append(fields, arg)
+	}
+	return strings.Join(fields, " ")
+}
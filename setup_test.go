@@ -5,6 +5,7 @@ import (
 
 	"github.com/coredns/caddy"
 	"github.com/stretchr/testify/assert"
+	"github.com/tinkernels/coredns-pocketbase/handler"
 )
 
 func TestSetup(t *testing.T) {
@@ -52,3 +53,28 @@ func TestSetup(t *testing.T) {
 		})
 	}
 }
+
+func TestParseConfigSeeds(t *testing.T) {
+	c := caddy.NewTestController("dns", `pocketbase {
+		record example.com. @ 3600 IN NS ns1
+		record example.com. www 300 IN A 192.0.2.10
+		record example.com. "txt IN TXT \"v=spf1 -all\""
+		record example.com. spf IN TXT "v=spf1 -all" "" "a;b" "say \"hi\""
+		seed_file example.org. /etc/coredns/example.org.yaml
+		seed_overwrite
+	}`)
+	conf, err := parseConfig(c)
+	assert.NoError(t, err)
+	assert.Equal(t, []handler.Seed{
+		{Zone: "example.com.", Records: []string{"@ 3600 IN NS ns1", "www 300 IN A 192.0.2.10", `txt IN TXT "v=spf1 -all"`,
+			`spf IN TXT "v=spf1 -all" "" "a;b" "say \"hi\""`}},
+		{Zone: "example.org.", File: "/etc/coredns/example.org.yaml"},
+	}, conf.Seeds)
+	assert.True(t, conf.SeedOverwrite)
+
+	c = caddy.NewTestController("dns", `pocketbase {
+		record example.com.
+	}`)
+	_, err = parseConfig(c)
+	assert.Error(t, err)
+}